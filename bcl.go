@@ -1,8 +1,10 @@
 package bcl
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"reflect"
 	"slices"
 
@@ -20,7 +22,12 @@ type Document struct {
 	Source   string
 	TopLevel *Element
 
-	lines []string
+	sourceLines SourceLines
+
+	// Tokens of parsed documents, used to print documents while preserving
+	// their original formatting.
+	tokens         []*Token
+	trailingTrivia string
 }
 
 type ElementReadStatus string
@@ -36,15 +43,28 @@ type Element struct {
 	Content             any // *Block or *Entry
 	FollowedByEmptyLine bool
 
+	// Comments on the lines preceding the element, and comment at the end of
+	// the line of an entry or of the opening line of a block.
+	LeadingComments []*Comment
+	TrailingComment *Comment
+
 	readStatus ElementReadStatus
 
 	validationErrors []error
+
+	cst     *elementCST
+	include *Element // include directive the element was read from
 }
 
 type Block struct {
 	Type     string
 	Name     string
 	Elements []*Element
+
+	// Comments following the last element of the block, and comment at the
+	// end of the line of the closing bracket.
+	EndComments    []*Comment
+	ClosingComment *Comment
 }
 
 type Entry struct {
@@ -52,26 +72,139 @@ type Entry struct {
 	Values []*Value
 }
 
+type Comment struct {
+	Location            Span
+	Text                string // everything after the '#' character
+	FollowedByEmptyLine bool
+}
+
+type ParseOptions struct {
+	// If set, the parser does not stop at the first error: it skips the
+	// invalid part of the document and continues. Parsing functions then
+	// return the partial document along with a ParseErrors value containing
+	// all errors.
+	RecoverErrors bool
+
+	// If set, entries named "include" are include directives: their values
+	// are paths of files in this filesystem whose top-level elements replace
+	// the directive. Paths are relative to the directory of the including
+	// file, or to the root of the filesystem if they start with '/', and can
+	// contain glob patterns as supported by fs.Glob. Spans of included
+	// elements contain the path of their file as source.
+	IncludeFS fs.FS
+
+	// If set, strings whose sigil has a registered handler (see
+	// RegisterSigil) are decoded during parsing, invalid strings being
+	// reported as syntax errors.
+	ValidateSigils bool
+
+	// If set, the "inf" and "nan" symbols and the "+inf" and "-inf" literals
+	// are read as non-finite floats. Otherwise "inf" and "nan" are symbols
+	// and "+inf" and "-inf" are syntax errors.
+	AllowNonFiniteFloats bool
+}
+
 func Parse(data []byte, source string) (*Document, error) {
-	p := newParser(data, source)
+	return ParseWithOptions(data, source, ParseOptions{})
+}
+
+func ParseWithOptions(data []byte, source string, opts ParseOptions) (*Document, error) {
+	p := newParser(data, source, opts)
 
 	doc, err := p.Parse()
 	if err != nil {
-		return nil, err
-	}
+		if doc != nil {
+			doc.ResetReadStatus()
+		}
 
-	doc.lines = p.lines
+		return doc, err
+	}
 
 	doc.ResetReadStatus()
 
 	return doc, nil
 }
 
+// Parse a string containing a single value written as in documents, e.g.
+// `"foo"`, `42`, `true` or `[1 2 3]`.
+func ParseValue(s string) (value *Value, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			if verr, ok := v.(error); ok {
+				err = verr
+				return
+			}
+
+			panic(v)
+		}
+	}()
+
+	p := newParser([]byte(s), "", ParseOptions{})
+	tokenizer := newTokenizer(p.data, p.source)
+
+	for {
+		token := tokenizer.readToken()
+		if token == nil {
+			break
+		}
+
+		p.tokens = append(p.tokens, token)
+	}
+
+	if len(p.tokens) == 0 {
+		return nil, errors.New("empty value")
+	}
+
+	p.endPoint = p.tokens[len(p.tokens)-1].Span.End
+	p.endPoint.Column++
+
+	value = p.parseValue(p.skipToken())
+
+	if token := p.readToken(); token != nil {
+		return nil, p.tokenSyntaxError(token, "unexpected token %q after value",
+			token.Type)
+	}
+
+	return value, nil
+}
+
 func (doc *Document) Print(w io.Writer) error {
 	p := newPrinter(w, doc)
 	return p.Print()
 }
 
+// Print a document while preserving the formatting of the original source
+// (whitespaces, alignment, line continuations and comments). Only elements
+// which were created or modified since the document was parsed are printed
+// as Print would. Documents which were not parsed, for example those created
+// with Marshal, are printed as with Print.
+//
+// Elements read from included files are not printed: the include directives
+// they were read from are printed instead, so that the content of included
+// files stays in these files.
+func (doc *Document) PrintPreserving(w io.Writer) error {
+	p := newPrinter(w, doc)
+
+	if doc.tokens == nil || doc.TopLevel.cst == nil {
+		return p.Print()
+	}
+
+	return p.PrintPreserving()
+}
+
+// Print an element without indentation, ignoring any empty line following
+// it.
+func (elt *Element) Print(w io.Writer) error {
+	p := newPrinter(w, nil)
+	return p.PrintElement(elt)
+}
+
+// Print a value as it would be written in a document.
+func (v *Value) Print(w io.Writer) error {
+	p := newPrinter(w, nil)
+	return p.PrintValue(v)
+}
+
 func (doc *Document) ResetReadStatus() {
 	var reset func(*Element)
 	reset = func(elt *Element) {
@@ -182,7 +315,7 @@ func (block1 *Block) Equal(block2 *Block) bool {
 		}
 	}
 
-	return false
+	return true
 }
 
 func (entry1 *Entry) Equal(entry2 *Entry) bool {
@@ -674,7 +807,25 @@ func (elt *Element) Values(dests ...any) bool {
 	if len(dests) == 1 {
 		v := reflect.ValueOf(dests[0])
 
-		if v.Kind() == reflect.Pointer && v.Elem().Kind() == reflect.Slice {
+		var isListOrNull bool
+		if len(entry.Values) == 1 {
+			switch entry.Values[0].Content.(type) {
+			case List:
+				// A single list is the only element of slices whose
+				// elements are themselves extracted from lists, e.g.
+				// [][]string.
+				isListOrNull = v.Kind() != reflect.Pointer ||
+					v.Elem().Kind() != reflect.Slice ||
+					!isListSliceType(v.Elem().Type().Elem())
+			case nil:
+				isListOrNull = true
+			}
+		}
+
+		// Slices such as []byte or net.IP are extracted from a single value,
+		// and so are slices extracted from a single list or null value.
+		if v.Kind() == reflect.Pointer && v.Elem().Kind() == reflect.Slice &&
+			!isValueSliceType(v.Elem().Type()) && !isListOrNull {
 			t := v.Elem().Type().Elem()
 			slice := reflect.MakeSlice(reflect.SliceOf(t), 0, len(entry.Values))
 
@@ -718,18 +869,55 @@ func (elt *Element) Values(dests ...any) bool {
 	return valid
 }
 
-func (elt *Element) CheckValueOneOf(i int, values ...any) bool {
+// Check that the value at index i of an entry is not null.
+func (elt *Element) CheckValueNotNull(i int) bool {
+	value := elt.checkValueIndex(i)
+	if value == nil {
+		return false
+	}
+
+	if value.IsNull() {
+		elt.AddInvalidValueError(value, &NullValueError{})
+		return false
+	}
+
+	return true
+}
+
+// Check that the value at index i of an entry is null.
+func (elt *Element) CheckValueNull(i int) bool {
+	value := elt.checkValueIndex(i)
+	if value == nil {
+		return false
+	}
+
+	if !value.IsNull() {
+		elt.AddInvalidValueError(value, NewValueTypeError(value, ValueTypeNull))
+		return false
+	}
+
+	return true
+}
+
+func (elt *Element) checkValueIndex(i int) *Value {
 	entry := elt.CheckTypeEntry()
 	if entry == nil {
-		return false
+		return nil
 	}
 
 	if i >= len(entry.Values) {
 		elt.AddInvalidEntryMinNbValuesError(i + 1)
-		return false
+		return nil
 	}
 
-	value := entry.Values[i]
+	return entry.Values[i]
+}
+
+func (elt *Element) CheckValueOneOf(i int, values ...any) bool {
+	value := elt.checkValueIndex(i)
+	if value == nil {
+		return false
+	}
 
 	if err := value.IsOneOf(values...); err != nil {
 		elt.AddInvalidValueError(value, err)
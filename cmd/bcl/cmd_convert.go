@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"go.n16f.net/bcl"
+	"go.n16f.net/program"
+)
+
+func cmdConvert(p *program.Program) {
+	from := p.OptionValue("from")
+	to := p.OptionValue("to")
+
+	source, data := readFileOrStdin(p.OptionalArgumentValue("path"))
+
+	output, warnings, err := convertDocument(data, source, from, to)
+	printConversionWarnings(warnings)
+	if err != nil {
+		p.Fatal("%v", err)
+	}
+
+	if _, err := os.Stdout.Write(output); err != nil {
+		p.Fatal("cannot write stdout: %v", err)
+	}
+}
+
+// Convert a document from a format to another one, returning the warnings
+// of both the import and the export.
+func convertDocument(data []byte, source, from, to string) ([]byte, []bcl.ConversionWarning, error) {
+	var doc *bcl.Document
+	var warnings []bcl.ConversionWarning
+	var err error
+
+	switch from {
+	case "bcl":
+		doc, err = bcl.Parse(data, source)
+	case "json":
+		doc, err = bcl.ImportJSON(data, source)
+	case "yaml":
+		doc, warnings, err = bcl.ImportYAML(data, source)
+	case "toml":
+		doc, warnings, err = bcl.ImportTOML(data, source)
+	default:
+		return nil, nil, fmt.Errorf("invalid input format %q", from)
+	}
+
+	if err != nil {
+		return nil, warnings, fmt.Errorf("cannot parse document:\n%w", err)
+	}
+
+	var output []byte
+	var exportWarnings []bcl.ConversionWarning
+
+	switch to {
+	case "bcl":
+		var buf bytes.Buffer
+		err = doc.Print(&buf)
+		output = buf.Bytes()
+	case "json":
+		output, err = bcl.ExportJSON(doc)
+	case "yaml":
+		output, exportWarnings, err = bcl.ExportYAML(doc)
+	case "toml":
+		output, exportWarnings, err = bcl.ExportTOML(doc)
+	default:
+		return nil, warnings, fmt.Errorf("invalid output format %q", to)
+	}
+
+	warnings = append(warnings, exportWarnings...)
+
+	if err != nil {
+		return nil, warnings, fmt.Errorf("cannot convert document: %w", err)
+	}
+
+	return output, warnings, nil
+}
+
+func printConversionWarnings(warnings []bcl.ConversionWarning) {
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", w)
+	}
+}
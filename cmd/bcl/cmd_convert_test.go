@@ -0,0 +1,103 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestConvertDocument(t *testing.T) {
+	tests := []struct {
+		from     string
+		to       string
+		data     string
+		output   string
+		warnings []string
+	}{
+		{
+			"yaml", "json",
+			"Foo-Bar: 1\n",
+			"[\n  {\n    \"entry\": \"foo_bar\",\n    \"values\": [\n" +
+				"      1\n    ]\n  }\n]\n",
+			[]string{"Foo-Bar"},
+		},
+		{
+			"toml", "json",
+			"Foo-Bar = 1\n",
+			"[\n  {\n    \"entry\": \"foo_bar\",\n    \"values\": [\n" +
+				"      1\n    ]\n  }\n]\n",
+			[]string{"Foo-Bar"},
+		},
+		{
+			"yaml", "bcl",
+			"1a: true\n",
+			"x_1a true\n",
+			[]string{"1a"},
+		},
+		{
+			"yaml", "toml",
+			"Foo-Bar: [1, 2]\n",
+			"foo_bar = [1, 2]\n",
+			[]string{"Foo-Bar"},
+		},
+		{
+			"bcl", "yaml",
+			"# comment\na sym\n",
+			"a: sym\n",
+			[]string{"", "a"},
+		},
+		{
+			"bcl", "json",
+			"a 1\n",
+			"[\n  {\n    \"entry\": \"a\",\n    \"values\": [\n" +
+				"      1\n    ]\n  }\n]\n",
+			nil,
+		},
+	}
+
+	for _, test := range tests {
+		output, warnings, err := convertDocument([]byte(test.data), "test",
+			test.from, test.to)
+		if err != nil {
+			t.Errorf("cannot convert %q from %s to %s: %v", test.data,
+				test.from, test.to, err)
+			continue
+		}
+
+		if string(output) != test.output {
+			t.Errorf("%q was converted from %s to %s as %q instead of %q",
+				test.data, test.from, test.to, output, test.output)
+		}
+
+		var paths []string
+		for _, w := range warnings {
+			paths = append(paths, w.Path)
+		}
+
+		if !slices.Equal(paths, test.warnings) {
+			t.Errorf("converting %q from %s to %s produced warnings %v "+
+				"instead of warnings for paths %v", test.data, test.from,
+				test.to, warnings, test.warnings)
+		}
+	}
+}
+
+func TestConvertDocumentInvalid(t *testing.T) {
+	tests := []struct {
+		from string
+		to   string
+		data string
+	}{
+		{"xml", "json", "a 1\n"},
+		{"bcl", "xml", "a 1\n"},
+		{"yaml", "json", "a: [1"},
+		{"bcl", "json", "a 1x\n"},
+	}
+
+	for _, test := range tests {
+		if _, _, err := convertDocument([]byte(test.data), "test", test.from,
+			test.to); err == nil {
+			t.Errorf("converting %q from %s to %s should have failed",
+				test.data, test.from, test.to)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"os"
+
+	"go.n16f.net/program"
+)
+
+func cmdDelete(p *program.Program) {
+	filePath := p.ArgumentValue("file")
+	path := p.ArgumentValue("query")
+
+	doc := readDocumentFile(filePath)
+
+	n, err := doc.Delete(path)
+	if err != nil {
+		p.Fatal("cannot delete %q: %v", path, err)
+	}
+
+	if n == 0 {
+		p.Error("no element matching %q", path)
+		os.Exit(1)
+	}
+
+	writeDocumentFile(filePath, doc)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.n16f.net/bcl"
+	"go.n16f.net/program"
+)
+
+func cmdDiff(p *program.Program) {
+	doc1 := readDocumentFile(p.ArgumentValue("path1"))
+	doc2 := readDocumentFile(p.ArgumentValue("path2"))
+
+	diffs := bcl.Diff(doc1, doc2)
+
+	// Entries are printed on a single line; added and removed blocks are
+	// printed in full below their path.
+	for _, diff := range diffs {
+		switch diff.Type {
+		case bcl.DifferenceTypeAdded:
+			printDiffElement("+", diff.Path, diff.Element2)
+
+		case bcl.DifferenceTypeRemoved:
+			printDiffElement("-", diff.Path, diff.Element1)
+
+		case bcl.DifferenceTypeChanged:
+			fmt.Printf("~ %s: %s -> %s\n", diff.Path,
+				formatDiffValues(diff.Element1), formatDiffValues(diff.Element2))
+		}
+	}
+
+	if len(diffs) > 0 {
+		os.Exit(1)
+	}
+}
+
+func printDiffElement(prefix, path string, elt *bcl.Element) {
+	if !elt.IsBlock() {
+		fmt.Printf("%s %s: %s\n", prefix, path, formatDiffValues(elt))
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := elt.Print(&buf); err != nil {
+		p.Fatal("cannot print block: %v", err)
+	}
+
+	fmt.Printf("%s %s:\n", prefix, path)
+
+	for _, line := range strings.Split(strings.TrimRight(buf.String(), "\n"), "\n") {
+		fmt.Printf("%s   %s\n", prefix, line)
+	}
+}
+
+func formatDiffValues(elt *bcl.Element) string {
+	entry := elt.Content.(*bcl.Entry)
+
+	values := make([]string, len(entry.Values))
+	for i, value := range entry.Values {
+		var buf bytes.Buffer
+		if err := value.Print(&buf); err != nil {
+			p.Fatal("cannot print value: %v", err)
+		}
+
+		values[i] = buf.String()
+	}
+
+	return strings.Join(values, " ")
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"os"
+
+	"go.n16f.net/bcl"
+	"go.n16f.net/program"
+)
+
+func cmdLSP(p *program.Program) {
+	var schema *bcl.Schema
+	if p.IsOptionSet("schema") {
+		schema = readSchemaFile(p.OptionValue("schema"))
+	}
+
+	server := newLSPServer(os.Stdin, os.Stdout, schema)
+
+	shutdown, err := server.Run()
+	if err != nil {
+		p.Fatal("%v", err)
+	}
+
+	if !shutdown {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"os"
+
+	"go.n16f.net/bcl"
+	"go.n16f.net/program"
+)
+
+func cmdMerge(p *program.Program) {
+	opts := bcl.MergeOptions{
+		EntryPolicy: bcl.MergeEntryPolicy(p.OptionValue("entry-policy")),
+	}
+
+	base := readDocumentFile(p.ArgumentValue("base"))
+
+	var overlays []*bcl.Document
+	for _, filePath := range p.TrailingArgumentValues("overlays") {
+		overlays = append(overlays, readDocumentFile(filePath))
+	}
+
+	doc, err := bcl.MergeWithOptions(opts, base, overlays...)
+	if err != nil {
+		p.Fatal("cannot merge documents: %v", err)
+	}
+
+	if err := doc.Print(os.Stdout); err != nil {
+		p.Fatal("cannot print document: %v", err)
+	}
+}
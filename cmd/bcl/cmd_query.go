@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+
+	"go.n16f.net/bcl"
+	"go.n16f.net/program"
+)
+
+func cmdQuery(p *program.Program) {
+	path, err := bcl.ParsePath(p.ArgumentValue("query"))
+	if err != nil {
+		p.Fatal("%v", err)
+	}
+
+	source, data := readFileOrStdin(p.OptionalArgumentValue("path"))
+
+	doc, err := bcl.Parse(data, source)
+	if err != nil {
+		p.Fatal("cannot parse document:\n%v", err)
+	}
+
+	elts := path.Query(doc.TopLevel)
+	if len(elts) == 0 {
+		os.Exit(1)
+	}
+
+	// Entries are printed as a line containing their values, strings being
+	// printed without quotes so that they can be used directly in shell
+	// scripts. If the path ends with a value index, only the selected value is
+	// printed. Blocks are printed in BCL.
+	if path.HasValueIndex() {
+		for _, value := range path.QueryValues(doc.TopLevel) {
+			fmt.Println(formatQueryValue(value))
+		}
+
+		return
+	}
+
+	for _, elt := range elts {
+		switch content := elt.Content.(type) {
+		case *bcl.Block:
+			if err := elt.Print(os.Stdout); err != nil {
+				p.Fatal("cannot print block: %v", err)
+			}
+
+		case *bcl.Entry:
+			values := make([]string, len(content.Values))
+			for i, value := range content.Values {
+				values[i] = formatQueryValue(value)
+			}
+
+			fmt.Println(strings.Join(values, " "))
+		}
+	}
+}
+
+func formatQueryValue(value *bcl.Value) string {
+	switch v := value.Content.(type) {
+	case nil:
+		return "null"
+	case bcl.Symbol:
+		return string(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case bcl.String:
+		return v.String
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case *big.Int:
+		return v.String()
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bcl.List, bcl.Map:
+		var buf strings.Builder
+		value.Print(&buf)
+		return buf.String()
+	default:
+		panic(fmt.Sprintf("unhandled value %#v (%T)", v, v))
+	}
+}
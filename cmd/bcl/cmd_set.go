@@ -0,0 +1,45 @@
+package main
+
+import (
+	"go.n16f.net/bcl"
+	"go.n16f.net/program"
+)
+
+func cmdSet(p *program.Program) {
+	filePath := p.ArgumentValue("file")
+	path := p.ArgumentValue("query")
+	args := p.TrailingArgumentValues("values")
+
+	doc := readDocumentFile(filePath)
+
+	// Arguments are parsed as BCL values, so that an unquoted word such as
+	// `info` is a symbol and `"info"` a string. Arguments which are not valid
+	// BCL values, for example words containing spaces or uppercase letters,
+	// are used as strings so that most strings do not have to be quoted
+	// twice. With --string, all arguments are used as strings.
+	values := make([]*bcl.Value, len(args))
+	for i, arg := range args {
+		var value *bcl.Value
+
+		if !p.IsOptionSet("string") {
+			value, _ = bcl.ParseValue(arg)
+		}
+
+		if value == nil {
+			var err error
+
+			value, err = bcl.NewValue(bcl.String{String: arg})
+			if err != nil {
+				p.Fatal("invalid value %q: %v", arg, err)
+			}
+		}
+
+		values[i] = value
+	}
+
+	if err := doc.Set(path, values...); err != nil {
+		p.Fatal("cannot set %q: %v", path, err)
+	}
+
+	writeDocumentFile(filePath, doc)
+}
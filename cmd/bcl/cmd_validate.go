@@ -1,6 +1,9 @@
 package main
 
 import (
+	"errors"
+	"os"
+
 	"go.n16f.net/bcl"
 	"go.n16f.net/program"
 )
@@ -8,7 +11,54 @@ import (
 func cmdValidate(p *program.Program) {
 	source, data := readFileOrStdin(p.OptionalArgumentValue("path"))
 
-	if _, err := bcl.Parse(data, source); err != nil {
-		p.Fatal("cannot parse document:\n%v", err)
+	output := p.OptionValue("output")
+	switch output {
+	case "text", "json", "sarif":
+	default:
+		p.Fatal("invalid output format %q", output)
+	}
+
+	var schema *bcl.Schema
+	if p.IsOptionSet("schema") {
+		schema = readSchemaFile(p.OptionValue("schema"))
+	}
+
+	opts := bcl.ParseOptions{RecoverErrors: true}
+
+	var diagnostics []*Diagnostic
+	var errMsg string
+
+	doc, err := bcl.ParseWithOptions(data, source, opts)
+	if err != nil {
+		var parseErrs bcl.ParseErrors
+		if errors.As(err, &parseErrs) {
+			diagnostics = parseErrorsDiagnostics(source, parseErrs)
+		} else {
+			diagnostics = []*Diagnostic{{Source: source, Message: err.Error()}}
+		}
+
+		errMsg = "cannot parse document:\n" + err.Error()
+	} else if schema != nil {
+		if errs := doc.ValidateSchema(schema); errs != nil {
+			diagnostics = validationErrorsDiagnostics(source, errs)
+			errMsg = "invalid document:\n" + errs.Error()
+		}
+	}
+
+	switch output {
+	case "text":
+		if errMsg != "" {
+			p.Fatal("%s", errMsg)
+		}
+
+	case "json":
+		writeJSONDiagnostics(os.Stdout, diagnostics)
+
+	case "sarif":
+		writeSARIFDiagnostics(os.Stdout, diagnostics)
+	}
+
+	if len(diagnostics) > 0 {
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+
+	"go.n16f.net/bcl"
+)
+
+type Diagnostic struct {
+	Source   string
+	Message  string
+	Location *bcl.Span
+}
+
+func parseErrorsDiagnostics(source string, errs bcl.ParseErrors) []*Diagnostic {
+	diagnostics := make([]*Diagnostic, len(errs.Errs))
+
+	for i, err := range errs.Errs {
+		location := bcl.ParseErrorLocation(err)
+
+		diagnostics[i] = &Diagnostic{
+			Source:   diagnosticSource(source, location),
+			Message:  bcl.ParseErrorDescription(err),
+			Location: location,
+		}
+	}
+
+	return diagnostics
+}
+
+func validationErrorsDiagnostics(source string, errs *bcl.ValidationErrors) []*Diagnostic {
+	diagnostics := make([]*Diagnostic, len(errs.Errs))
+
+	for i, err := range errs.Errs {
+		diagnostics[i] = &Diagnostic{
+			Source:   diagnosticSource(source, err.Location),
+			Message:  err.Err.Error(),
+			Location: err.Location,
+		}
+	}
+
+	return diagnostics
+}
+
+// Locations in included files carry the path of the file they come from.
+func diagnosticSource(source string, location *bcl.Span) string {
+	if location != nil && location.Source != "" {
+		return location.Source
+	}
+
+	return source
+}
+
+type jsonDiagnostic struct {
+	Source   string        `json:"source"`
+	Message  string        `json:"message"`
+	Location *jsonLocation `json:"location,omitempty"`
+}
+
+type jsonLocation struct {
+	Start jsonPoint `json:"start"`
+	End   jsonPoint `json:"end"`
+}
+
+type jsonPoint struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+func writeJSONDiagnostics(w io.Writer, diagnostics []*Diagnostic) {
+	jsonDiagnostics := make([]jsonDiagnostic, len(diagnostics))
+
+	for i, d := range diagnostics {
+		jd := jsonDiagnostic{
+			Source:  d.Source,
+			Message: d.Message,
+		}
+
+		if span := d.Location; span != nil {
+			jd.Location = &jsonLocation{
+				Start: jsonPoint{Line: span.Start.Line, Column: span.Start.Column},
+				End:   jsonPoint{Line: span.End.Line, Column: span.End.Column},
+			}
+		}
+
+		jsonDiagnostics[i] = jd
+	}
+
+	writeJSON(w, map[string]any{"errors": jsonDiagnostics})
+}
+
+// See https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html.
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name string `json:"name"`
+}
+
+type sarifResult struct {
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine"`
+	EndColumn   int `json:"endColumn"`
+}
+
+func writeSARIFDiagnostics(w io.Writer, diagnostics []*Diagnostic) {
+	results := make([]sarifResult, len(diagnostics))
+
+	for i, d := range diagnostics {
+		location := sarifLocation{
+			PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: d.Source},
+			},
+		}
+
+		if span := d.Location; span != nil {
+			// SARIF end columns are exclusive while span end points are
+			// inclusive.
+			location.PhysicalLocation.Region = &sarifRegion{
+				StartLine:   span.Start.Line,
+				StartColumn: span.Start.Column,
+				EndLine:     span.End.Line,
+				EndColumn:   span.End.Column + 1,
+			}
+		}
+
+		results[i] = sarifResult{
+			Level:     "error",
+			Message:   sarifMessage{Text: d.Message},
+			Locations: []sarifLocation{location},
+		}
+	}
+
+	log := sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool:    sarifTool{Driver: sarifDriver{Name: "bcl"}},
+			Results: results,
+		}},
+	}
+
+	writeJSON(w, log)
+}
+
+func writeJSON(w io.Writer, v any) {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(v); err != nil {
+		p.Fatal("cannot encode JSON data: %v", err)
+	}
+}
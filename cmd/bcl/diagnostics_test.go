@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"go.n16f.net/bcl"
+)
+
+func testDiagnostics(t *testing.T, s string) []*Diagnostic {
+	t.Helper()
+
+	opts := bcl.ParseOptions{RecoverErrors: true}
+
+	_, err := bcl.ParseWithOptions([]byte(s), "test.bcl", opts)
+	if err == nil {
+		t.Fatalf("parsing %q should have failed", s)
+	}
+
+	var errs bcl.ParseErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("error %v is not a ParseErrors value", err)
+	}
+
+	return parseErrorsDiagnostics("test.bcl", errs)
+}
+
+func TestJSONDiagnostics(t *testing.T) {
+	diagnostics := testDiagnostics(t, "a 1x\nb 2\nc 3 }\n")
+
+	var buf bytes.Buffer
+	writeJSONDiagnostics(&buf, diagnostics)
+
+	var output struct {
+		Errors []jsonDiagnostic `json:"errors"`
+	}
+
+	if err := json.Unmarshal(buf.Bytes(), &output); err != nil {
+		t.Fatalf("cannot decode JSON output: %v", err)
+	}
+
+	if len(output.Errors) != 2 {
+		t.Fatalf("output contains %d errors instead of 2:\n%s",
+			len(output.Errors), buf.String())
+	}
+
+	for i, line := range []int{1, 3} {
+		d := output.Errors[i]
+
+		if d.Source != "test.bcl" {
+			t.Errorf("error %d has source %q", i, d.Source)
+		}
+
+		if d.Message == "" {
+			t.Errorf("error %d has an empty message", i)
+		}
+
+		if d.Location == nil || d.Location.Start.Line != line {
+			t.Errorf("error %d has location %#v instead of line %d",
+				i, d.Location, line)
+		}
+	}
+}
+
+func TestSARIFDiagnostics(t *testing.T) {
+	diagnostics := testDiagnostics(t, "a 1\nb {\n")
+
+	var buf bytes.Buffer
+	writeSARIFDiagnostics(&buf, diagnostics)
+
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatalf("cannot decode SARIF output: %v", err)
+	}
+
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("invalid SARIF log:\n%s", buf.String())
+	}
+
+	results := log.Runs[0].Results
+	if len(results) != 1 {
+		t.Fatalf("output contains %d results instead of 1:\n%s",
+			len(results), buf.String())
+	}
+
+	result := results[0]
+
+	if result.Level != "error" || result.Message.Text == "" {
+		t.Errorf("invalid result %#v", result)
+	}
+
+	location := result.Locations[0].PhysicalLocation
+	if location.ArtifactLocation.URI != "test.bcl" {
+		t.Errorf("result has URI %q", location.ArtifactLocation.URI)
+	}
+
+	// SARIF end columns are exclusive
+	region := location.Region
+	if region == nil || region.StartLine != 2 ||
+		region.EndColumn != region.StartColumn+1 {
+		t.Errorf("invalid result region %#v", region)
+	}
+}
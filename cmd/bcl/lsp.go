@@ -0,0 +1,702 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"go.n16f.net/bcl"
+)
+
+type lspServer struct {
+	r io.Reader
+	w io.Writer
+
+	schema *bcl.Schema
+
+	documents map[string]*lspDocument
+
+	shutdown bool
+}
+
+type lspDocument struct {
+	URI    string
+	Source string
+	Text   string
+	Lines  []string
+
+	// The document is nil if the text could not be parsed at all; it is
+	// partial if the text contains syntax errors.
+	Document    *bcl.Document
+	Diagnostics []*Diagnostic
+}
+
+func newLSPServer(r io.Reader, w io.Writer, schema *bcl.Schema) *lspServer {
+	return &lspServer{
+		r: r,
+		w: w,
+
+		schema: schema,
+
+		documents: make(map[string]*lspDocument),
+	}
+}
+
+// Process messages until the client sends an "exit" notification. Return
+// false if the server exits without having received a "shutdown" request
+// first.
+func (s *lspServer) Run() (bool, error) {
+	r := textproto.NewReader(bufio.NewReader(s.r))
+
+	for {
+		data, err := s.readMessage(r)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return false, nil
+			}
+
+			return false, err
+		}
+
+		var msg lspMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			s.sendError(nil, &lspError{
+				Code:    lspErrorCodeParseError,
+				Message: fmt.Sprintf("invalid message: %v", err),
+			})
+			continue
+		}
+
+		if msg.Method == "exit" {
+			return s.shutdown, nil
+		}
+
+		if msg.Id == nil {
+			s.handleNotification(msg.Method, msg.Params)
+			continue
+		}
+
+		result, err := s.handleRequest(msg.Method, msg.Params)
+		if err != nil {
+			var lspErr *lspError
+			if !errors.As(err, &lspErr) {
+				lspErr = &lspError{
+					Code:    lspErrorCodeInternalError,
+					Message: err.Error(),
+				}
+			}
+
+			s.sendError(msg.Id, lspErr)
+			continue
+		}
+
+		s.send(lspResponse{JSONRPC: "2.0", Id: msg.Id, Result: result})
+	}
+}
+
+func (s *lspServer) readMessage(r *textproto.Reader) ([]byte, error) {
+	header, err := r.ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	lengthString := header.Get("Content-Length")
+	if lengthString == "" {
+		return nil, errors.New("missing Content-Length header field")
+	}
+
+	length, err := strconv.Atoi(lengthString)
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid Content-Length header field %q",
+			lengthString)
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r.R, data); err != nil {
+		return nil, fmt.Errorf("cannot read message: %w", err)
+	}
+
+	return data, nil
+}
+
+func (s *lspServer) send(msg any) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		p.Fatal("cannot encode message: %v", err)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Content-Length: %d\r\n\r\n", len(data))
+	buf.Write(data)
+
+	if _, err := s.w.Write(buf.Bytes()); err != nil {
+		p.Fatal("cannot write message: %v", err)
+	}
+}
+
+func (s *lspServer) sendError(id *json.RawMessage, err *lspError) {
+	s.send(lspErrorResponse{JSONRPC: "2.0", Id: id, Error: err})
+}
+
+func (s *lspServer) sendNotification(method string, params any) {
+	s.send(lspNotification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+func (s *lspServer) handleRequest(method string, params json.RawMessage) (any, error) {
+	switch method {
+	case "initialize":
+		return s.initialize()
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/documentSymbol":
+		return s.documentSymbol(params)
+	case "textDocument/hover":
+		return s.hover(params)
+	case "textDocument/definition":
+		return s.definition(params)
+	case "textDocument/formatting":
+		return s.formatting(params)
+	}
+
+	return nil, &lspError{
+		Code:    lspErrorCodeMethodNotFound,
+		Message: fmt.Sprintf("unknown method %q", method),
+	}
+}
+
+func (s *lspServer) handleNotification(method string, params json.RawMessage) {
+	var err error
+
+	switch method {
+	case "textDocument/didOpen":
+		err = s.didOpen(params)
+	case "textDocument/didChange":
+		err = s.didChange(params)
+	case "textDocument/didClose":
+		err = s.didClose(params)
+	}
+
+	// Notifications cannot be answered
+	if err != nil {
+		p.Error("cannot process %q notification: %v", method, err)
+	}
+}
+
+func decodeLSPParams(data json.RawMessage, params any) error {
+	if err := json.Unmarshal(data, params); err != nil {
+		return &lspError{
+			Code:    lspErrorCodeInvalidParams,
+			Message: fmt.Sprintf("invalid parameters: %v", err),
+		}
+	}
+
+	return nil
+}
+
+func (s *lspServer) document(uri string) (*lspDocument, error) {
+	doc, found := s.documents[uri]
+	if !found {
+		return nil, &lspError{
+			Code:    lspErrorCodeInvalidParams,
+			Message: fmt.Sprintf("unknown document %q", uri),
+		}
+	}
+
+	return doc, nil
+}
+
+func (s *lspServer) initialize() (any, error) {
+	result := lspInitializeResult{
+		Capabilities: lspServerCapabilities{
+			TextDocumentSync:           lspTextDocumentSyncFull,
+			DocumentSymbolProvider:     true,
+			HoverProvider:              true,
+			DefinitionProvider:         true,
+			DocumentFormattingProvider: true,
+		},
+		ServerInfo: lspServerInfo{Name: "bcl"},
+	}
+
+	return &result, nil
+}
+
+func (s *lspServer) didOpen(data json.RawMessage) error {
+	var params lspDidOpenParams
+	if err := decodeLSPParams(data, &params); err != nil {
+		return err
+	}
+
+	s.updateDocument(params.TextDocument.URI, params.TextDocument.Text)
+	return nil
+}
+
+func (s *lspServer) didChange(data json.RawMessage) error {
+	var params lspDidChangeParams
+	if err := decodeLSPParams(data, &params); err != nil {
+		return err
+	}
+
+	// We only support full synchronization, so the last change contains the
+	// entire content of the document.
+	if n := len(params.ContentChanges); n > 0 {
+		s.updateDocument(params.TextDocument.URI,
+			params.ContentChanges[n-1].Text)
+	}
+
+	return nil
+}
+
+func (s *lspServer) didClose(data json.RawMessage) error {
+	var params lspDidCloseParams
+	if err := decodeLSPParams(data, &params); err != nil {
+		return err
+	}
+
+	uri := params.TextDocument.URI
+	delete(s.documents, uri)
+
+	s.sendNotification("textDocument/publishDiagnostics",
+		lspPublishDiagnosticsParams{
+			URI:         uri,
+			Diagnostics: []lspDiagnostic{},
+		})
+
+	return nil
+}
+
+func (s *lspServer) updateDocument(uri, text string) {
+	doc := newLSPDocument(uri, text, s.schema)
+	s.documents[uri] = doc
+
+	diagnostics := make([]lspDiagnostic, len(doc.Diagnostics))
+	for i, d := range doc.Diagnostics {
+		var lrange lspRange
+		if d.Location != nil {
+			lrange = doc.lspRange(*d.Location)
+		}
+
+		diagnostics[i] = lspDiagnostic{
+			Range:    lrange,
+			Severity: lspDiagnosticSeverityError,
+			Source:   "bcl",
+			Message:  d.Message,
+		}
+	}
+
+	s.sendNotification("textDocument/publishDiagnostics",
+		lspPublishDiagnosticsParams{
+			URI:         uri,
+			Diagnostics: diagnostics,
+		})
+}
+
+func newLSPDocument(uri, text string, schema *bcl.Schema) *lspDocument {
+	source := uri
+	if u, err := url.Parse(uri); err == nil && u.Scheme == "file" {
+		source = u.Path
+	}
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+
+	doc := lspDocument{
+		URI:    uri,
+		Source: source,
+		Text:   text,
+		Lines:  lines,
+	}
+
+	opts := bcl.ParseOptions{RecoverErrors: true}
+
+	bdoc, err := bcl.ParseWithOptions([]byte(text), source, opts)
+	if err != nil {
+		var parseErrs bcl.ParseErrors
+		if errors.As(err, &parseErrs) {
+			doc.Diagnostics = parseErrorsDiagnostics(source, parseErrs)
+		} else {
+			doc.Diagnostics = []*Diagnostic{{Source: source, Message: err.Error()}}
+		}
+	} else if schema != nil {
+		if errs := bdoc.ValidateSchema(schema); errs != nil {
+			doc.Diagnostics = validationErrorsDiagnostics(source, errs)
+		}
+	}
+
+	doc.Document = bdoc
+
+	return &doc
+}
+
+// Spans use 1-based lines and columns counted in characters, while LSP
+// positions use 0-based lines and characters counted in UTF-16 code units.
+func (d *lspDocument) lspPosition(point bcl.Point) lspPosition {
+	pos := lspPosition{Line: point.Line - 1}
+
+	if pos.Line >= 0 && pos.Line < len(d.Lines) {
+		column := 1
+		for _, c := range d.Lines[pos.Line] {
+			if column >= point.Column {
+				break
+			}
+
+			pos.Character += utf16.RuneLen(c)
+			column++
+		}
+	}
+
+	return pos
+}
+
+func (d *lspDocument) lspRange(span bcl.Span) lspRange {
+	// Span end points are inclusive while LSP range ends are exclusive
+	end := span.End
+	end.Column++
+
+	return lspRange{
+		Start: d.lspPosition(span.Start),
+		End:   d.lspPosition(end),
+	}
+}
+
+func (d *lspDocument) point(pos lspPosition) bcl.Point {
+	point := bcl.Point{Line: pos.Line + 1, Column: 1}
+
+	if pos.Line >= 0 && pos.Line < len(d.Lines) {
+		character := 0
+		for _, c := range d.Lines[pos.Line] {
+			character += utf16.RuneLen(c)
+			if character > pos.Character {
+				break
+			}
+
+			point.Column++
+		}
+	}
+
+	return point
+}
+
+func spanContains(span bcl.Span, point bcl.Point) bool {
+	before := func(p1, p2 bcl.Point) bool {
+		return p1.Line < p2.Line || (p1.Line == p2.Line && p1.Column <= p2.Column)
+	}
+
+	return before(span.Start, point) && before(point, span.End)
+}
+
+// Return the span covering an element and all its children. Closing brackets
+// of blocks are not part of the span.
+func elementSpan(elt *bcl.Element) bcl.Span {
+	span := elt.Location
+
+	switch content := elt.Content.(type) {
+	case *bcl.Block:
+		for _, child := range content.Elements {
+			span = span.Union(elementSpan(child))
+		}
+
+	case *bcl.Entry:
+		for _, value := range content.Values {
+			span = span.Union(value.Location)
+		}
+	}
+
+	return span
+}
+
+func (s *lspServer) documentSymbol(data json.RawMessage) (any, error) {
+	var params lspDocumentSymbolParams
+	if err := decodeLSPParams(data, &params); err != nil {
+		return nil, err
+	}
+
+	doc, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	symbols := []lspDocumentSymbol{}
+
+	if doc.Document != nil {
+		block := doc.Document.TopLevel.Content.(*bcl.Block)
+		symbols = doc.documentSymbols(block.Elements)
+	}
+
+	return symbols, nil
+}
+
+func (d *lspDocument) documentSymbols(elts []*bcl.Element) []lspDocumentSymbol {
+	symbols := make([]lspDocumentSymbol, len(elts))
+
+	for i, elt := range elts {
+		symbol := lspDocumentSymbol{
+			Range:          d.lspRange(elementSpan(elt)),
+			SelectionRange: d.lspRange(elt.Location),
+		}
+
+		switch content := elt.Content.(type) {
+		case *bcl.Block:
+			symbol.Name = content.Type
+			symbol.Kind = lspSymbolKindObject
+
+			if content.Name != "" {
+				symbol.Name += " " + strconv.Quote(content.Name)
+				symbol.Kind = lspSymbolKindNamespace
+			}
+
+			symbol.Children = d.documentSymbols(content.Elements)
+
+		case *bcl.Entry:
+			symbol.Name = content.Name
+			symbol.Kind = lspSymbolKindProperty
+
+			values := make([]string, len(content.Values))
+			for i, value := range content.Values {
+				values[i] = formatQueryValue(value)
+			}
+
+			symbol.Detail = strings.Join(values, " ")
+		}
+
+		symbols[i] = symbol
+	}
+
+	return symbols
+}
+
+// Return the element at a position, and the value at this position if there
+// is one.
+func (d *lspDocument) find(point bcl.Point) (*bcl.Element, *bcl.Value) {
+	if d.Document == nil {
+		return nil, nil
+	}
+
+	var find func(*bcl.Element) (*bcl.Element, *bcl.Value)
+	find = func(elt *bcl.Element) (*bcl.Element, *bcl.Value) {
+		switch content := elt.Content.(type) {
+		case *bcl.Block:
+			for _, child := range content.Elements {
+				if foundElt, value := find(child); foundElt != nil {
+					return foundElt, value
+				}
+			}
+
+		case *bcl.Entry:
+			for _, value := range content.Values {
+				if spanContains(value.Location, point) {
+					return elt, value
+				}
+			}
+		}
+
+		if spanContains(elt.Location, point) {
+			return elt, nil
+		}
+
+		return nil, nil
+	}
+
+	block := d.Document.TopLevel.Content.(*bcl.Block)
+	for _, elt := range block.Elements {
+		if foundElt, value := find(elt); foundElt != nil {
+			return foundElt, value
+		}
+	}
+
+	return nil, nil
+}
+
+func (s *lspServer) hover(data json.RawMessage) (any, error) {
+	var params lspTextDocumentPositionParams
+	if err := decodeLSPParams(data, &params); err != nil {
+		return nil, err
+	}
+
+	doc, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	elt, value := doc.find(doc.point(params.Position))
+	if elt == nil {
+		return nil, nil
+	}
+
+	var text string
+	var span bcl.Span
+
+	if value != nil {
+		entry := elt.Content.(*bcl.Entry)
+
+		var i int
+		for i = range entry.Values {
+			if entry.Values[i] == value {
+				break
+			}
+		}
+
+		text = fmt.Sprintf("Value %d of entry `%s`: %s", i, entry.Name,
+			describeValueType(value))
+		span = value.Location
+	} else {
+		switch content := elt.Content.(type) {
+		case *bcl.Block:
+			text = fmt.Sprintf("Block `%s`", content.Type)
+			if content.Name != "" {
+				text += fmt.Sprintf(" named `%s`", content.Name)
+			}
+
+			text += fmt.Sprintf(" containing %d element(s)",
+				len(content.Elements))
+
+		case *bcl.Entry:
+			types := make([]string, len(content.Values))
+			for i, value := range content.Values {
+				types[i] = describeValueType(value)
+			}
+
+			text = fmt.Sprintf("Entry `%s` with %d value(s)", content.Name,
+				len(content.Values))
+			if len(types) > 0 {
+				text += ": " + strings.Join(types, ", ")
+			}
+		}
+
+		span = elt.Location
+	}
+
+	lrange := doc.lspRange(span)
+
+	hover := lspHover{
+		Contents: lspMarkupContent{Kind: "markdown", Value: text},
+		Range:    &lrange,
+	}
+
+	return &hover, nil
+}
+
+func describeValueType(value *bcl.Value) string {
+	switch v := value.Content.(type) {
+	case nil:
+		return "null"
+	case bcl.String:
+		if v.Sigil != "" {
+			return fmt.Sprintf("string with sigil `%s`", v.Sigil)
+		}
+	case bcl.List:
+		return fmt.Sprintf("list of %d value(s)", len(v))
+	case bcl.Map:
+		return fmt.Sprintf("map with %d key(s)", len(v))
+	}
+
+	return string(value.Type())
+}
+
+func (s *lspServer) definition(data json.RawMessage) (any, error) {
+	var params lspTextDocumentPositionParams
+	if err := decodeLSPParams(data, &params); err != nil {
+		return nil, err
+	}
+
+	doc, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	_, value := doc.find(doc.point(params.Position))
+	if value == nil {
+		return nil, nil
+	}
+
+	// Strings and symbols are references to all named blocks with the same
+	// name, whatever their type and depth.
+	var name string
+
+	switch v := value.Content.(type) {
+	case bcl.String:
+		name = v.String
+	case bcl.Symbol:
+		name = string(v)
+	default:
+		return nil, nil
+	}
+
+	if name == "" {
+		return nil, nil
+	}
+
+	locations := []lspLocation{}
+
+	var find func(*bcl.Element)
+	find = func(elt *bcl.Element) {
+		block, ok := elt.Content.(*bcl.Block)
+		if !ok {
+			return
+		}
+
+		if block.Name == name {
+			locations = append(locations, lspLocation{
+				URI:   doc.URI,
+				Range: doc.lspRange(elt.Location),
+			})
+		}
+
+		for _, child := range block.Elements {
+			find(child)
+		}
+	}
+
+	for _, elt := range doc.Document.TopLevel.Content.(*bcl.Block).Elements {
+		find(elt)
+	}
+
+	return locations, nil
+}
+
+func (s *lspServer) formatting(data json.RawMessage) (any, error) {
+	var params lspDocumentFormattingParams
+	if err := decodeLSPParams(data, &params); err != nil {
+		return nil, err
+	}
+
+	doc, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	// Documents with syntax errors are not formatted since part of their
+	// content would be lost.
+	bdoc, err := bcl.Parse([]byte(doc.Text), doc.Source)
+	if err != nil {
+		return nil, nil
+	}
+
+	var buf bytes.Buffer
+	if err := bdoc.Print(&buf); err != nil {
+		return nil, err
+	}
+
+	edits := []lspTextEdit{}
+
+	if text := buf.String(); text != doc.Text {
+		end := lspPosition{Line: len(doc.Lines)}
+
+		edits = append(edits, lspTextEdit{
+			Range:   lspRange{End: end},
+			NewText: text,
+		})
+	}
+
+	return edits, nil
+}
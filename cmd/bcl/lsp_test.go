@@ -0,0 +1,384 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"testing"
+
+	"go.n16f.net/bcl"
+)
+
+const testLSPURI = "file:///tmp/test.bcl"
+
+type testLSPMessage struct {
+	Id     *int            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *lspError       `json:"error"`
+}
+
+// Run the server on a sequence of messages followed by a "shutdown" request
+// and an "exit" notification, and return all messages sent by the server.
+// Messages with an "id" member are requests, the others are notifications.
+func testLSPSession(t *testing.T, msgs ...map[string]any) []testLSPMessage {
+	t.Helper()
+
+	msgs = append(msgs,
+		map[string]any{"id": 1000, "method": "shutdown"},
+		map[string]any{"method": "exit"})
+
+	var input bytes.Buffer
+	for _, msg := range msgs {
+		msg["jsonrpc"] = "2.0"
+
+		data, err := json.Marshal(msg)
+		if err != nil {
+			t.Fatalf("cannot encode message: %v", err)
+		}
+
+		fmt.Fprintf(&input, "Content-Length: %d\r\n\r\n", len(data))
+		input.Write(data)
+	}
+
+	var output bytes.Buffer
+
+	server := newLSPServer(&input, &output, nil)
+
+	shutdown, err := server.Run()
+	if err != nil {
+		t.Fatalf("server error: %v", err)
+	}
+
+	if !shutdown {
+		t.Errorf("server exited without being shut down")
+	}
+
+	var responses []testLSPMessage
+
+	r := textproto.NewReader(bufio.NewReader(&output))
+	for {
+		header, err := r.ReadMIMEHeader()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("cannot read header: %v", err)
+		}
+
+		length, err := strconv.Atoi(header.Get("Content-Length"))
+		if err != nil {
+			t.Fatalf("invalid Content-Length header field: %v", err)
+		}
+
+		data := make([]byte, length)
+		if _, err := io.ReadFull(r.R, data); err != nil {
+			t.Fatalf("cannot read message: %v", err)
+		}
+
+		var msg testLSPMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatalf("cannot decode message %q: %v", data, err)
+		}
+
+		responses = append(responses, msg)
+	}
+
+	// The last response is always the answer to the "shutdown" request
+	if n := len(responses); n == 0 || responses[n-1].Id == nil ||
+		*responses[n-1].Id != 1000 {
+		t.Fatalf("missing response to the shutdown request")
+	}
+
+	return responses[:len(responses)-1]
+}
+
+func testLSPDidOpen(text string) map[string]any {
+	return map[string]any{
+		"method": "textDocument/didOpen",
+		"params": map[string]any{
+			"textDocument": map[string]any{
+				"uri":     testLSPURI,
+				"version": 1,
+				"text":    text,
+			},
+		},
+	}
+}
+
+func testLSPRequest(id int, method string, line, character int) map[string]any {
+	return map[string]any{
+		"id":     id,
+		"method": method,
+		"params": map[string]any{
+			"textDocument": map[string]any{"uri": testLSPURI},
+			"position":     map[string]any{"line": line, "character": character},
+		},
+	}
+}
+
+func testLSPResult(t *testing.T, msg testLSPMessage, result any) {
+	t.Helper()
+
+	if msg.Error != nil {
+		t.Fatalf("request %d failed: %s", *msg.Id, msg.Error.Message)
+	}
+
+	if err := json.Unmarshal(msg.Result, result); err != nil {
+		t.Fatalf("cannot decode result %q: %v", msg.Result, err)
+	}
+}
+
+func TestLSPInitialize(t *testing.T) {
+	msgs := testLSPSession(t,
+		map[string]any{"id": 1, "method": "initialize", "params": struct{}{}},
+		map[string]any{"id": 2, "method": "foo"})
+
+	if len(msgs) != 2 {
+		t.Fatalf("server sent %d messages instead of 2", len(msgs))
+	}
+
+	var result lspInitializeResult
+	testLSPResult(t, msgs[0], &result)
+
+	if result.ServerInfo.Name != "bcl" {
+		t.Errorf("server is named %q", result.ServerInfo.Name)
+	}
+
+	if result.Capabilities.TextDocumentSync != lspTextDocumentSyncFull {
+		t.Errorf("server uses synchronization mode %d",
+			result.Capabilities.TextDocumentSync)
+	}
+
+	if err := msgs[1].Error; err == nil ||
+		err.Code != lspErrorCodeMethodNotFound {
+		t.Errorf("unknown method did not fail with a method not found error")
+	}
+}
+
+func TestLSPDiagnostics(t *testing.T) {
+	tests := []struct {
+		text  string
+		lines []int
+	}{
+		{"a 1\nb 2\n", nil},
+		{"a 1x\nb 2\nc 3 }\n", []int{0, 2}},
+		{"x {\n  a 1\n", []int{1}},
+	}
+
+	for _, test := range tests {
+		msgs := testLSPSession(t, testLSPDidOpen(test.text))
+
+		if len(msgs) != 1 || msgs[0].Method != "textDocument/publishDiagnostics" {
+			t.Errorf("server did not publish diagnostics for %q", test.text)
+			continue
+		}
+
+		var params lspPublishDiagnosticsParams
+		if err := json.Unmarshal(msgs[0].Params, &params); err != nil {
+			t.Fatalf("cannot decode diagnostics: %v", err)
+		}
+
+		if params.URI != testLSPURI {
+			t.Errorf("diagnostics were published for %q", params.URI)
+		}
+
+		var lines []int
+		for _, d := range params.Diagnostics {
+			lines = append(lines, d.Range.Start.Line)
+		}
+
+		if fmt.Sprint(lines) != fmt.Sprint(test.lines) {
+			t.Errorf("diagnostics for %q are located on lines %v instead of %v",
+				test.text, lines, test.lines)
+		}
+	}
+}
+
+func TestLSPDocumentSymbol(t *testing.T) {
+	text := "a 1 2\nx \"n\" {\n  b true\n}\n"
+
+	msgs := testLSPSession(t, testLSPDidOpen(text),
+		testLSPRequest(1, "textDocument/documentSymbol", 0, 0))
+
+	var symbols []lspDocumentSymbol
+	testLSPResult(t, msgs[1], &symbols)
+
+	if len(symbols) != 2 {
+		t.Fatalf("server returned %d symbols instead of 2", len(symbols))
+	}
+
+	tests := []struct {
+		symbol lspDocumentSymbol
+		name   string
+		kind   int
+		detail string
+		line   int
+	}{
+		{symbols[0], "a", lspSymbolKindProperty, "1 2", 0},
+		{symbols[1], `x "n"`, lspSymbolKindNamespace, "", 1},
+	}
+
+	for _, test := range tests {
+		s := test.symbol
+
+		if s.Name != test.name || s.Kind != test.kind ||
+			s.Detail != test.detail || s.Range.Start.Line != test.line {
+			t.Errorf("invalid symbol %+v", s)
+		}
+	}
+
+	if children := symbols[1].Children; len(children) != 1 ||
+		children[0].Name != "b" || children[0].Range.Start.Line != 2 {
+		t.Errorf("invalid children %+v", children)
+	}
+
+	if end := symbols[1].Range.End.Line; end != 2 {
+		t.Errorf("block symbol ends on line %d", end)
+	}
+}
+
+func TestLSPHover(t *testing.T) {
+	text := "a 1 [2 3]\nx \"n\" {\n  b\n}\n"
+
+	tests := []struct {
+		line, character int
+		text            string
+	}{
+		{0, 0, "Entry `a` with 2 value(s): integer, list of 2 value(s)"},
+		{0, 2, "Value 0 of entry `a`: integer"},
+		{0, 5, "Value 1 of entry `a`: list of 2 value(s)"},
+		{1, 0, "Block `x` named `n` containing 1 element(s)"},
+		{2, 2, "Entry `b` with 0 value(s)"},
+		{3, 0, ""},
+	}
+
+	for _, test := range tests {
+		msgs := testLSPSession(t, testLSPDidOpen(text),
+			testLSPRequest(1, "textDocument/hover", test.line, test.character))
+
+		var hover *lspHover
+		testLSPResult(t, msgs[1], &hover)
+
+		var s string
+		if hover != nil {
+			s = hover.Contents.Value
+		}
+
+		if s != test.text {
+			t.Errorf("hover at %d:%d returned %q instead of %q",
+				test.line, test.character, s, test.text)
+		}
+	}
+}
+
+func TestLSPDefinition(t *testing.T) {
+	text := "a b c\nb {}\nx {\n  b \"n\" {}\n}\n"
+
+	msgs := testLSPSession(t, testLSPDidOpen(text),
+		testLSPRequest(1, "textDocument/definition", 0, 2),
+		testLSPRequest(2, "textDocument/definition", 0, 4))
+
+	var locations []lspLocation
+
+	testLSPResult(t, msgs[1], &locations)
+	if len(locations) != 0 {
+		t.Errorf("server returned locations %v for %q", locations, "b")
+	}
+
+	testLSPResult(t, msgs[2], &locations)
+	if len(locations) != 0 {
+		t.Errorf("server returned locations %v for %q", locations, "c")
+	}
+
+	text = "a n\nb {}\nx {\n  b \"n\" {}\n}\n"
+
+	msgs = testLSPSession(t, testLSPDidOpen(text),
+		testLSPRequest(1, "textDocument/definition", 0, 2))
+
+	testLSPResult(t, msgs[1], &locations)
+	if len(locations) != 1 || locations[0].Range.Start.Line != 3 {
+		t.Errorf("server returned locations %v for %q", locations, "n")
+	}
+}
+
+func TestLSPFormatting(t *testing.T) {
+	tests := []struct {
+		text  string
+		edits []lspTextEdit
+	}{
+		{"a 1\n", []lspTextEdit{}},
+		{"a   1\n", []lspTextEdit{{
+			Range:   lspRange{End: lspPosition{Line: 2}},
+			NewText: "a 1\n",
+		}}},
+		{"a 1x\n", nil},
+	}
+
+	for _, test := range tests {
+		msgs := testLSPSession(t, testLSPDidOpen(test.text),
+			testLSPRequest(1, "textDocument/formatting", 0, 0))
+
+		var edits []lspTextEdit
+		testLSPResult(t, msgs[1], &edits)
+
+		if fmt.Sprint(edits) != fmt.Sprint(test.edits) ||
+			(edits == nil) != (test.edits == nil) {
+			t.Errorf("formatting %q returned edits %v instead of %v",
+				test.text, edits, test.edits)
+		}
+	}
+}
+
+func TestLSPUnknownDocument(t *testing.T) {
+	msgs := testLSPSession(t,
+		testLSPRequest(1, "textDocument/hover", 0, 0))
+
+	if len(msgs) != 1 || msgs[0].Error == nil ||
+		msgs[0].Error.Code != lspErrorCodeInvalidParams {
+		t.Errorf("request on an unknown document did not fail")
+	}
+}
+
+func TestLSPPositions(t *testing.T) {
+	doc := newLSPDocument(testLSPURI, "a \"é𝄞\" b\n", nil)
+
+	tests := []struct {
+		point bcl.Point
+		pos   lspPosition
+	}{
+		{bcl.Point{Line: 1, Column: 1}, lspPosition{0, 0}},
+		{bcl.Point{Line: 1, Column: 4}, lspPosition{0, 3}},
+		{bcl.Point{Line: 1, Column: 5}, lspPosition{0, 4}},
+		{bcl.Point{Line: 1, Column: 7}, lspPosition{0, 7}},
+		{bcl.Point{Line: 2, Column: 1}, lspPosition{1, 0}},
+	}
+
+	for _, test := range tests {
+		if pos := doc.lspPosition(test.point); pos != test.pos {
+			t.Errorf("point %v was converted to position %v instead of %v",
+				test.point, pos, test.pos)
+		}
+
+		if point := doc.point(test.pos); point != test.point {
+			t.Errorf("position %v was converted to point %v instead of %v",
+				test.pos, point, test.point)
+		}
+	}
+
+	span := bcl.Span{
+		Start: bcl.Point{Line: 1, Column: 3},
+		End:   bcl.Point{Line: 1, Column: 6},
+	}
+
+	expected := lspRange{Start: lspPosition{0, 2}, End: lspPosition{0, 7}}
+
+	if lrange := doc.lspRange(span); lrange != expected {
+		t.Errorf("span %v was converted to range %v instead of %v",
+			span, lrange, expected)
+	}
+}
@@ -11,10 +11,58 @@ func main() {
 
 	p = program.NewProgram("bcl", "utilities for the BCL language")
 
+	c = p.AddCommand("convert", "convert a document to another format",
+		cmdConvert)
+	c.AddOption("f", "from", "format", "bcl",
+		"the format of the input document (bcl, json, yaml or toml)")
+	c.AddOption("t", "to", "format", "bcl",
+		"the format of the output document (bcl, json, yaml or toml)")
+	c.AddOptionalArgument("path", "the path of the file")
+
+	c = p.AddCommand("delete", "delete elements selected by a path",
+		cmdDelete)
+	c.AddArgument("file", "the path of the file or \"-\" for stdin and stdout")
+	c.AddArgument("query", "the path selecting elements")
+
+	c = p.AddCommand("diff", "print the differences between two BCL files",
+		cmdDiff)
+	c.AddArgument("path1", "the path of the first file")
+	c.AddArgument("path2", "the path of the second file")
+
 	c = p.AddCommand("format", "parse a BCL file and print it", cmdFormat)
 	c.AddOptionalArgument("path", "the path of the file")
 
-	c = p.AddCommand("validate", "parse a BCL file", cmdValidate)
+	c = p.AddCommand("lsp", "run a language server over stdin and stdout",
+		cmdLSP)
+	c.AddOption("s", "schema", "path", "",
+		"the path of a schema file used to validate documents")
+
+	c = p.AddCommand("merge", "merge overlay files into a base file and "+
+		"print the result", cmdMerge)
+	c.AddOption("p", "entry-policy", "policy", "replace",
+		"how overlay entries are merged (replace or append)")
+	c.AddArgument("base", "the path of the base file")
+	c.AddTrailingArgument("overlays", "the paths of the overlay files")
+
+	c = p.AddCommand("query", "print elements or values selected by a path",
+		cmdQuery)
+	c.AddArgument("query", "the path selecting elements")
+	c.AddOptionalArgument("path", "the path of the file")
+
+	c = p.AddCommand("set", "set the values of entries selected by a path",
+		cmdSet)
+	c.AddFlag("s", "string",
+		"use values as strings instead of parsing them as BCL values")
+	c.AddArgument("file", "the path of the file or \"-\" for stdin and stdout")
+	c.AddArgument("query", "the path selecting entries")
+	c.AddTrailingArgument("values", "the values of the entries, written as "+
+		"in documents (unquoted words are symbols)")
+
+	c = p.AddCommand("validate", "parse and validate a BCL file", cmdValidate)
+	c.AddOption("s", "schema", "path", "",
+		"the path of a schema file used to validate the document")
+	c.AddOption("o", "output", "format", "text",
+		"the output format (text, json or sarif)")
 	c.AddOptionalArgument("path", "the path of the file")
 
 	p.ParseCommandLine()
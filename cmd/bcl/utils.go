@@ -1,8 +1,11 @@
 package main
 
 import (
+	"bytes"
 	"io"
 	"os"
+
+	"go.n16f.net/bcl"
 )
 
 func readFileOrStdin(filePath *string) (string, []byte) {
@@ -28,3 +31,51 @@ func readFileOrStdin(filePath *string) (string, []byte) {
 
 	return source, data
 }
+
+func readDocumentFile(filePath string) *bcl.Document {
+	source, data := readFileOrStdin(&filePath)
+
+	doc, err := bcl.Parse(data, source)
+	if err != nil {
+		p.Fatal("cannot parse document:\n%v", err)
+	}
+
+	return doc
+}
+
+// Write a document to a file, or to stdout if the path is "-", preserving the
+// formatting of unmodified elements. The file is only modified once the
+// document has been printed successfully.
+func writeDocumentFile(filePath string, doc *bcl.Document) {
+	var buf bytes.Buffer
+
+	if err := doc.PrintPreserving(&buf); err != nil {
+		p.Fatal("cannot print document: %v", err)
+	}
+
+	if filePath == "-" {
+		if _, err := os.Stdout.Write(buf.Bytes()); err != nil {
+			p.Fatal("cannot write stdout: %v", err)
+		}
+
+		return
+	}
+
+	if err := os.WriteFile(filePath, buf.Bytes(), 0644); err != nil {
+		p.Fatal("cannot write %q: %v", filePath, err)
+	}
+}
+
+func readSchemaFile(filePath string) *bcl.Schema {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		p.Fatal("cannot read %q: %v", filePath, err)
+	}
+
+	schema, err := bcl.ParseSchema(data, filePath)
+	if err != nil {
+		p.Fatal("cannot parse schema:\n%v", err)
+	}
+
+	return schema
+}
@@ -0,0 +1,458 @@
+package bcl
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ConversionWarning struct {
+	Path        string
+	Description string
+}
+
+func (w ConversionWarning) String() string {
+	if w.Path == "" {
+		return w.Description
+	}
+
+	return w.Path + ": " + w.Description
+}
+
+// Conversion from and to YAML and TOML uses an intermediate representation
+// made of ordered mappings (convMap), sequences ([]any) and scalars (nil,
+// bool, int64, float64 and string).
+//
+// Block content is mapped to a mapping where each key is either an entry name
+// or a block type:
+//
+//   - An entry with a single value is mapped to a scalar.
+//   - An entry with zero or multiple values is mapped to a sequence of scalars.
+//   - Repeated entries are mapped to a sequence of sequences of scalars.
+//   - An unnamed block is mapped to a mapping.
+//   - Repeated unnamed blocks are mapped to a sequence of mappings.
+//   - A named block is mapped to a mapping whose key is the block type followed
+//     by the quoted block name, e.g. `server "api"`.
+//   - A list value is mapped to a sequence and a map value to a mapping. When
+//     importing, sequences of sequences are read as repeated entries, and
+//     other sequences and mappings nested in sequences as lists and maps, so
+//     entries whose values are all lists or all maps cannot be imported back.
+//
+// Symbols, strings with a sigil and integers which do not fit in 64 bits are
+// exported as plain strings, and comments are not preserved; these
+// conversions are reported as warnings.
+type convMap []convMapItem
+
+type convMapItem struct {
+	Key   string
+	Value any
+}
+
+type converter struct {
+	Warnings []ConversionWarning
+}
+
+func (c *converter) warn(path []string, format string, args ...any) {
+	c.Warnings = append(c.Warnings, ConversionWarning{
+		Path:        strings.Join(path, "."),
+		Description: fmt.Sprintf(format, args...),
+	})
+}
+
+var namedBlockKeyRE = regexp.MustCompile(`^([a-z][a-z0-9_]*) ("(?:[^"\\]|\\.)*")$`)
+
+func namedBlockKey(btype, name string) string {
+	return btype + " " + strconv.Quote(name)
+}
+
+func (c *converter) exportDocument(doc *Document) convMap {
+	if documentHasComments(doc) {
+		c.warn(nil, "comments are not preserved")
+	}
+
+	return c.exportBlockContent(doc.TopLevel.Content.(*Block), nil)
+}
+
+func (c *converter) exportBlockContent(block *Block, path []string) convMap {
+	var m convMap
+
+	keyIndexes := make(map[string]int)
+	keyTypes := make(map[string]ElementType)
+
+	for _, elt := range block.Elements {
+		key := elt.Name()
+
+		if block, ok := elt.Content.(*Block); ok && block.Name != "" {
+			key = namedBlockKey(block.Type, block.Name)
+		}
+
+		eltPath := childPath(path, elt.Id())
+
+		if eltType, found := keyTypes[key]; found && eltType != elt.Type() {
+			c.warn(eltPath, "%s ignored because of conflicting %s %q",
+				elt.Type(), eltType, key)
+			continue
+		}
+
+		var value any
+
+		switch content := elt.Content.(type) {
+		case *Block:
+			value = c.exportBlockContent(content, eltPath)
+
+		case *Entry:
+			c.checkEntryValues(content, eltPath)
+
+			values := make([]any, len(content.Values))
+			for i, v := range content.Values {
+				values[i] = c.exportValue(v, eltPath)
+			}
+
+			if len(values) == 1 {
+				value = values[0]
+			} else {
+				value = values
+			}
+		}
+
+		i, found := keyIndexes[key]
+		if !found {
+			keyIndexes[key] = len(m)
+			keyTypes[key] = elt.Type()
+			m = append(m, convMapItem{Key: key, Value: value})
+			continue
+		}
+
+		// Repeated element
+		prevValue := m[i].Value
+
+		if elt.IsBlock() {
+			if seq, ok := prevValue.([]any); ok {
+				m[i].Value = append(seq, value)
+			} else {
+				m[i].Value = []any{prevValue, value}
+			}
+		} else {
+			if seq, ok := prevValue.([][]any); ok {
+				m[i].Value = append(seq, entryValues(value))
+			} else {
+				m[i].Value = [][]any{entryValues(prevValue), entryValues(value)}
+			}
+		}
+	}
+
+	// Repeated entries are stored as [][]any during construction to be
+	// distinguishable from entries with multiple values.
+	for i, item := range m {
+		if seqs, ok := item.Value.([][]any); ok {
+			values := make([]any, len(seqs))
+			for j, seq := range seqs {
+				values[j] = seq
+			}
+
+			m[i].Value = values
+		}
+	}
+
+	return m
+}
+
+// Report entries whose values are read back differently when importing the
+// document: sequences of values are read as entry values or as repeated
+// entries, and mappings as blocks.
+func (c *converter) checkEntryValues(entry *Entry, path []string) {
+	switch {
+	case allValuesOfType(entry.Values, ValueTypeList):
+		if len(entry.Values) == 1 {
+			c.warn(path, "list converted to entry values")
+		} else {
+			c.warn(path, "lists converted to repeated entries")
+		}
+
+	case allValuesOfType(entry.Values, ValueTypeMap):
+		if len(entry.Values) == 1 {
+			c.warn(path, "map converted to a block")
+		} else {
+			c.warn(path, "maps converted to repeated blocks")
+		}
+	}
+}
+
+func allValuesOfType(values []*Value, t ValueType) bool {
+	for _, v := range values {
+		if v.Type() != t {
+			return false
+		}
+	}
+
+	return len(values) > 0
+}
+
+func entryValues(value any) []any {
+	if values, ok := value.([]any); ok {
+		return values
+	}
+
+	return []any{value}
+}
+
+func (c *converter) exportValue(v *Value, path []string) any {
+	switch content := v.Content.(type) {
+	case nil:
+		return nil
+
+	case Symbol:
+		c.warn(path, "symbol %q converted to a string", content)
+		return string(content)
+
+	case String:
+		if content.Sigil != "" {
+			c.warn(path, "sigil %q of string %q ignored", content.Sigil,
+				content.String)
+		}
+
+		return content.String
+
+	case bool, int64, float64:
+		return content
+
+	case *big.Int:
+		c.warn(path, "integer %v converted to a string", content)
+		return content.String()
+
+	case List:
+		values := make([]any, len(content))
+		for i, child := range content {
+			values[i] = c.exportValue(child, path)
+		}
+
+		return values
+
+	case Map:
+		m := make(convMap, len(content))
+		for i, entry := range content {
+			m[i] = convMapItem{
+				Key:   entry.Key,
+				Value: c.exportValue(entry.Value, childPath(path, entry.Key)),
+			}
+		}
+
+		return m
+
+	default:
+		panic(fmt.Sprintf("unhandled value %#v (%T)", v, v))
+	}
+}
+
+func (c *converter) importDocument(m convMap, source string) *Document {
+	topLevel := Element{
+		Location: NewSpanAt(Point{0, 1, 1}, 0),
+		Content:  &Block{Elements: c.importBlockContent(m, nil)},
+	}
+
+	doc := Document{
+		Source:   source,
+		TopLevel: &topLevel,
+	}
+
+	doc.ResetReadStatus()
+
+	return &doc
+}
+
+func (c *converter) importBlockContent(m convMap, path []string) []*Element {
+	var elts []*Element
+
+	for _, item := range m {
+		itemPath := childPath(path, item.Key)
+
+		if subm, ok := item.Value.(convMap); ok {
+			if matches := namedBlockKeyRE.FindStringSubmatch(item.Key); matches != nil {
+				name, err := strconv.Unquote(matches[2])
+				if err == nil {
+					elts = append(elts, c.importBlock(matches[1], name, subm,
+						itemPath))
+					continue
+				}
+			}
+		}
+
+		name := c.importName(item.Key, itemPath)
+
+		switch v := item.Value.(type) {
+		case convMap:
+			elts = append(elts, c.importBlock(name, "", v, itemPath))
+
+		case []any:
+			elts = append(elts, c.importSequence(name, v, itemPath)...)
+
+		default:
+			value := c.importValue(v, itemPath)
+			elts = append(elts, newImportedEntry(name, []*Value{value}))
+		}
+	}
+
+	return elts
+}
+
+func (c *converter) importBlock(btype, name string, m convMap, path []string) *Element {
+	block := Block{
+		Type:     btype,
+		Name:     name,
+		Elements: c.importBlockContent(m, path),
+	}
+
+	return &Element{Content: &block}
+}
+
+func (c *converter) importSequence(name string, seq []any, path []string) []*Element {
+	var nbMaps, nbSeqs int
+	for _, v := range seq {
+		switch v.(type) {
+		case convMap:
+			nbMaps++
+		case []any:
+			nbSeqs++
+		}
+	}
+
+	var elts []*Element
+
+	switch {
+	case nbMaps == len(seq) && len(seq) > 0:
+		for _, v := range seq {
+			elts = append(elts, c.importBlock(name, "", v.(convMap), path))
+		}
+
+	case nbSeqs == len(seq) && len(seq) > 0:
+		for _, v := range seq {
+			elts = append(elts, newImportedEntry(name,
+				c.importValues(v.([]any), path)))
+		}
+
+	default:
+		elts = append(elts, newImportedEntry(name, c.importValues(seq, path)))
+	}
+
+	return elts
+}
+
+func (c *converter) importValues(seq []any, path []string) []*Value {
+	values := make([]*Value, len(seq))
+	for i, v := range seq {
+		values[i] = c.importValue(v, path)
+	}
+
+	return values
+}
+
+func (c *converter) importValue(v any, path []string) *Value {
+	var content any
+
+	switch v := v.(type) {
+	case nil, bool, int64, *big.Int, float64:
+		content = v
+
+	case string:
+		content = String{String: v}
+
+	case []any:
+		content = List(c.importValues(v, path))
+
+	case convMap:
+		m := make(Map, len(v))
+		for i, item := range v {
+			m[i] = &MapEntry{
+				Key:   item.Key,
+				Value: c.importValue(item.Value, childPath(path, item.Key)),
+			}
+		}
+
+		content = m
+
+	case time.Time:
+		s := v.Format(time.RFC3339Nano)
+		c.warn(path, "date converted to string %q", s)
+		content = String{String: s}
+
+	case fmt.Stringer:
+		s := v.String()
+		c.warn(path, "value converted to string %q", s)
+		content = String{String: s}
+
+	default:
+		panic(fmt.Sprintf("unhandled value %#v (%T)", v, v))
+	}
+
+	return &Value{Content: content}
+}
+
+func (c *converter) importName(key string, path []string) string {
+	name := []rune(strings.ToLower(key))
+
+	for i, c := range name {
+		if !isSymbolChar(c) {
+			name[i] = '_'
+		}
+	}
+
+	if len(name) == 0 || !isSymbolFirstChar(name[0]) {
+		name = append([]rune{'x', '_'}, name...)
+	}
+
+	if string(name) != key {
+		c.warn(path, "key %q converted to %q", key, string(name))
+	}
+
+	return string(name)
+}
+
+func childPath(path []string, name string) []string {
+	return append(slices.Clone(path), name)
+}
+
+func newImportedEntry(name string, values []*Value) *Element {
+	entry := Entry{
+		Name:   name,
+		Values: values,
+	}
+
+	return &Element{Content: &entry}
+}
+
+func documentHasComments(doc *Document) bool {
+	var hasComments func(*Element) bool
+	hasComments = func(elt *Element) bool {
+		if len(elt.LeadingComments) > 0 || elt.TrailingComment != nil {
+			return true
+		}
+
+		if block, ok := elt.Content.(*Block); ok {
+			if len(block.EndComments) > 0 || block.ClosingComment != nil {
+				return true
+			}
+
+			for _, child := range block.Elements {
+				if hasComments(child) {
+					return true
+				}
+			}
+		}
+
+		if entry, ok := elt.Content.(*Entry); ok {
+			for _, value := range entry.Values {
+				if value.containsComments() {
+					return true
+				}
+			}
+		}
+
+		return false
+	}
+
+	return hasComments(doc.TopLevel)
+}
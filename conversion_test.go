@@ -0,0 +1,175 @@
+package bcl
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+)
+
+type conversionFuncs struct {
+	name       string
+	exportFunc func(*Document) ([]byte, []ConversionWarning, error)
+	importFunc func([]byte, string) (*Document, []ConversionWarning, error)
+}
+
+var testConversions = []conversionFuncs{
+	{"YAML", ExportYAML, ImportYAML},
+	{"TOML", ExportTOML, ImportTOML},
+}
+
+func TestConversionRoundTrip(t *testing.T) {
+	tests := []string{
+		"a 1\nb \"x\"\nc true\nd 1.5\n",
+		"a \"x\" 2\nb\n",
+		"a 1\na 2 3\n",
+		"d {\n  e true\n}\n",
+		"server \"api\" {\n  port 80\n}\n\nserver \"web\" {\n  port 81\n}\n",
+		"d {\n  e 1\n}\n\nd {\n  e 2\n}\n",
+		"a [1 2] {x = 1, y = \"z\"}\n",
+	}
+
+	for _, conv := range testConversions {
+		for _, s := range tests {
+			doc, err := Parse([]byte(s), "test")
+			if err != nil {
+				t.Errorf("cannot parse %q: %v", s, err)
+				continue
+			}
+
+			data, warnings, err := conv.exportFunc(doc)
+			if err != nil {
+				t.Errorf("cannot export %q to %s: %v", s, conv.name, err)
+				continue
+			}
+
+			if len(warnings) > 0 {
+				t.Errorf("exporting %q to %s produced warnings %v",
+					s, conv.name, warnings)
+			}
+
+			doc2, warnings, err := conv.importFunc(data, "test")
+			if err != nil {
+				t.Errorf("cannot import %s document %q: %v",
+					conv.name, data, err)
+				continue
+			}
+
+			if len(warnings) > 0 {
+				t.Errorf("importing %s document %q produced warnings %v",
+					conv.name, data, warnings)
+			}
+
+			if !doc.TopLevel.Equal(doc2.TopLevel) {
+				var buf bytes.Buffer
+				doc2.Print(&buf)
+
+				t.Errorf("%q was converted to %s and back as %q",
+					s, conv.name, buf.String())
+			}
+		}
+	}
+}
+
+func TestConversionExportWarnings(t *testing.T) {
+	tests := []struct {
+		s     string
+		paths []string
+	}{
+		{"a sym", []string{"a"}},
+		{"a ~re\"x\"", []string{"a"}},
+		{"a 99999999999999999999", []string{"a"}},
+		{"# comment\na 1", []string{""}},
+		{"a [1 2]", []string{"a"}},
+		{"a [\n  1 # comment\n]", []string{"", "a"}},
+		{"a {\n  x = 1 # comment\n}", []string{"", "a"}},
+		{"a {x = 1}", []string{"a"}},
+		{"b {\n  c {x = sym}\n}", []string{"b.c", "b.c.x"}},
+		{"a 1\na {}", []string{"a"}},
+	}
+
+	for _, conv := range testConversions {
+		for _, test := range tests {
+			doc, err := Parse([]byte(test.s), "test")
+			if err != nil {
+				t.Errorf("cannot parse %q: %v", test.s, err)
+				continue
+			}
+
+			_, warnings, err := conv.exportFunc(doc)
+			if err != nil {
+				t.Errorf("cannot export %q to %s: %v", test.s, conv.name, err)
+				continue
+			}
+
+			var paths []string
+			for _, w := range warnings {
+				paths = append(paths, w.Path)
+			}
+
+			if !slices.Equal(paths, test.paths) {
+				t.Errorf("exporting %q to %s produced warnings %v instead of "+
+					"warnings for paths %v", test.s, conv.name, warnings,
+					test.paths)
+			}
+		}
+	}
+}
+
+func TestImportYAML(t *testing.T) {
+	tests := []struct {
+		yaml     string
+		s        string
+		warnings int
+	}{
+		{"a: 1\nb: [x, 2]\n", "a 1\nb \"x\" 2\n", 0},
+		{"Foo-Bar: 1\n", "foo_bar 1\n", 1},
+		{"1a: true\n", "x_1a true\n", 1},
+		{"d: 2024-01-02\n", "d \"2024-01-02T00:00:00Z\"\n", 1},
+		{"a: [[1], [2, 3]]\n", "a 1\na 2 3\n", 0},
+		{"a: [{b: 1}, {b: 2}]\n", "a {\n  b 1\n}\na {\n  b 2\n}\n", 0},
+		{"a: null\n", "a null\n", 0},
+	}
+
+	for _, test := range tests {
+		doc, warnings, err := ImportYAML([]byte(test.yaml), "test")
+		if err != nil {
+			t.Errorf("cannot import %q: %v", test.yaml, err)
+			continue
+		}
+
+		if len(warnings) != test.warnings {
+			t.Errorf("importing %q produced warnings %v instead of %d "+
+				"warnings", test.yaml, warnings, test.warnings)
+		}
+
+		var buf bytes.Buffer
+		if err := doc.Print(&buf); err != nil {
+			t.Errorf("cannot print document: %v", err)
+			continue
+		}
+
+		if buf.String() != test.s {
+			t.Errorf("%q was imported as %q but should have been imported "+
+				"as %q", test.yaml, buf.String(), test.s)
+		}
+	}
+}
+
+func TestImportInvalid(t *testing.T) {
+	tests := []struct {
+		conv conversionFuncs
+		data string
+	}{
+		{testConversions[0], "a: [1"},
+		{testConversions[0], "- 1\n- 2\n"},
+		{testConversions[1], "a = "},
+		{testConversions[1], "a = 1\na = 2\n"},
+	}
+
+	for _, test := range tests {
+		if _, _, err := test.conv.importFunc([]byte(test.data), "test"); err == nil {
+			t.Errorf("importing %s document %q should have failed",
+				test.conv.name, test.data)
+		}
+	}
+}
@@ -0,0 +1,426 @@
+package bcl
+
+import (
+	"slices"
+	"strings"
+)
+
+// Parsed documents keep the list of tokens they were parsed from, each token
+// carrying the trivia preceding it. Each element refers to the range of
+// tokens it was parsed from:
+//
+//   - For entries, the range contains leading comments, the entry itself and
+//     all following EOL sequences.
+//   - For blocks, the range is split into the header (leading comments, the
+//     opening line and following EOL sequences), the ranges of child elements,
+//     and the footer (end comments, the closing line and following EOL
+//     sequences).
+//
+// Include directives are replaced by the elements of the files they include;
+// they are kept in the syntax tree information of the enclosing block so that
+// they can be printed in place of these elements.
+//
+// A snapshot of each element is recorded after parsing. When a document is
+// printed with PrintPreserving, elements identical to their snapshot are
+// printed using the original tokens; other elements are printed as Print
+// would.
+type elementCST struct {
+	Start       int
+	HeaderEnd   int
+	FooterStart int
+	End         int
+
+	Includes []*Element // include directives of blocks
+
+	Snapshot *elementSnapshot
+}
+
+type elementSnapshot struct {
+	Type                ElementType
+	FollowedByEmptyLine bool
+	LeadingComments     []Comment
+	TrailingComment     *Comment
+
+	// Entries
+	Name     string
+	Values   []*Value
+	Contents []any
+
+	// Blocks
+	BlockType      string
+	BlockName      string
+	EndComments    []Comment
+	ClosingComment *Comment
+}
+
+func (elt *Element) snapshotCST() {
+	if elt.cst == nil {
+		return
+	}
+
+	snapshot := elementSnapshot{
+		Type:                elt.Type(),
+		FollowedByEmptyLine: elt.FollowedByEmptyLine,
+		LeadingComments:     snapshotComments(elt.LeadingComments),
+		TrailingComment:     snapshotComment(elt.TrailingComment),
+	}
+
+	switch content := elt.Content.(type) {
+	case *Block:
+		snapshot.BlockType = content.Type
+		snapshot.BlockName = content.Name
+		snapshot.EndComments = snapshotComments(content.EndComments)
+		snapshot.ClosingComment = snapshotComment(content.ClosingComment)
+
+		for _, child := range content.Elements {
+			child.snapshotCST()
+		}
+
+	case *Entry:
+		snapshot.Name = content.Name
+		snapshot.Values = slices.Clone(content.Values)
+
+		snapshot.Contents = make([]any, len(content.Values))
+		for i, value := range content.Values {
+			snapshot.Contents[i] = snapshotValueContent(value)
+		}
+	}
+
+	elt.cst.Snapshot = &snapshot
+}
+
+// Lists and maps are not comparable and their elements can be modified in
+// place, so they are recorded as a copy of their elements, of their contents
+// and of their comments.
+type compositeSnapshot struct {
+	Type     ValueType
+	Keys     []string
+	Values   []*Value
+	Contents []any
+
+	LeadingComments  [][]Comment
+	TrailingComments []*Comment
+	EndComments      []Comment
+}
+
+func snapshotValueContent(value *Value) any {
+	var snapshot compositeSnapshot
+
+	switch content := value.RawContent().(type) {
+	case List:
+		snapshot.Type = ValueTypeList
+		snapshot.Values = slices.Clone(content)
+
+		for _, child := range content {
+			snapshot.LeadingComments = append(snapshot.LeadingComments,
+				snapshotComments(child.LeadingComments))
+			snapshot.TrailingComments = append(snapshot.TrailingComments,
+				snapshotComment(child.TrailingComment))
+		}
+
+	case Map:
+		snapshot.Type = ValueTypeMap
+		for _, entry := range content {
+			snapshot.Keys = append(snapshot.Keys, entry.Key)
+			snapshot.Values = append(snapshot.Values, entry.Value)
+
+			snapshot.LeadingComments = append(snapshot.LeadingComments,
+				snapshotComments(entry.LeadingComments))
+			snapshot.TrailingComments = append(snapshot.TrailingComments,
+				snapshotComment(entry.TrailingComment))
+		}
+
+	default:
+		return content
+	}
+
+	snapshot.EndComments = snapshotComments(value.EndComments)
+
+	snapshot.Contents = make([]any, len(snapshot.Values))
+	for i, child := range snapshot.Values {
+		snapshot.Contents[i] = snapshotValueContent(child)
+	}
+
+	return snapshot
+}
+
+func valueContentUnchanged(value *Value, snapshot any) bool {
+	var keys []string
+	var values []*Value
+	var leadingComments [][]*Comment
+	var trailingComments []*Comment
+
+	switch content := value.RawContent().(type) {
+	case List:
+		values = content
+
+		for _, child := range content {
+			leadingComments = append(leadingComments, child.LeadingComments)
+			trailingComments = append(trailingComments, child.TrailingComment)
+		}
+
+	case Map:
+		for _, entry := range content {
+			keys = append(keys, entry.Key)
+			values = append(values, entry.Value)
+
+			leadingComments = append(leadingComments, entry.LeadingComments)
+			trailingComments = append(trailingComments, entry.TrailingComment)
+		}
+
+	default:
+		_, isComposite := snapshot.(compositeSnapshot)
+		return !isComposite && content == snapshot
+	}
+
+	composite, ok := snapshot.(compositeSnapshot)
+	if !ok || composite.Type != value.Type() ||
+		!slices.Equal(keys, composite.Keys) ||
+		len(values) != len(composite.Values) {
+		return false
+	}
+
+	for i, child := range values {
+		if child != composite.Values[i] ||
+			!valueContentUnchanged(child, composite.Contents[i]) ||
+			!commentsUnchanged(leadingComments[i],
+				composite.LeadingComments[i]) ||
+			!commentUnchanged(trailingComments[i],
+				composite.TrailingComments[i]) {
+			return false
+		}
+	}
+
+	return commentsUnchanged(value.EndComments, composite.EndComments)
+}
+
+func snapshotComments(comments []*Comment) []Comment {
+	snapshots := make([]Comment, len(comments))
+	for i, comment := range comments {
+		snapshots[i] = *comment
+	}
+
+	return snapshots
+}
+
+func snapshotComment(comment *Comment) *Comment {
+	if comment == nil {
+		return nil
+	}
+
+	snapshot := *comment
+	return &snapshot
+}
+
+// Return whether the element can be printed using its original tokens: for
+// entries, the whole element; for blocks, the header.
+func (elt *Element) cstHeaderUnchanged() bool {
+	snapshot := elt.cst.Snapshot
+
+	if !commentsUnchanged(elt.LeadingComments, snapshot.LeadingComments) ||
+		!commentUnchanged(elt.TrailingComment, snapshot.TrailingComment) {
+		return false
+	}
+
+	switch content := elt.Content.(type) {
+	case *Block:
+		return content.Type == snapshot.BlockType &&
+			content.Name == snapshot.BlockName
+
+	case *Entry:
+		if elt.FollowedByEmptyLine != snapshot.FollowedByEmptyLine ||
+			content.Name != snapshot.Name ||
+			len(content.Values) != len(snapshot.Values) {
+			return false
+		}
+
+		for i, value := range content.Values {
+			if value != snapshot.Values[i] ||
+				!valueContentUnchanged(value, snapshot.Contents[i]) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// Return whether the footer of a block can be printed using its original
+// tokens.
+func (elt *Element) cstFooterUnchanged() bool {
+	snapshot := elt.cst.Snapshot
+	block := elt.Content.(*Block)
+
+	return elt.FollowedByEmptyLine == snapshot.FollowedByEmptyLine &&
+		commentsUnchanged(block.EndComments, snapshot.EndComments) &&
+		commentUnchanged(block.ClosingComment, snapshot.ClosingComment)
+}
+
+func commentsUnchanged(comments []*Comment, snapshots []Comment) bool {
+	if len(comments) != len(snapshots) {
+		return false
+	}
+
+	for i, comment := range comments {
+		if !commentUnchanged(comment, &snapshots[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func commentUnchanged(comment, snapshot *Comment) bool {
+	if comment == nil || snapshot == nil {
+		return comment == nil && snapshot == nil
+	}
+
+	return comment.Text == snapshot.Text &&
+		comment.FollowedByEmptyLine == snapshot.FollowedByEmptyLine
+}
+
+func (p *printer) printDocumentPreserving() {
+	top := p.doc.TopLevel
+	block := top.Content.(*Block)
+	cst := top.cst
+
+	if indent := p.doc.cstIndent(); indent != "" {
+		p.indent = indent
+	}
+
+	p.printTokens(cst.Start, cst.HeaderEnd)
+	p.printChildrenPreserving(block.Elements, cst.Includes)
+
+	if commentsUnchanged(block.EndComments, cst.Snapshot.EndComments) {
+		p.printTokens(cst.FooterStart, cst.End)
+		p.print(p.doc.trailingTrivia)
+	} else {
+		p.startLine()
+		p.printComments(block.EndComments)
+	}
+}
+
+func (p *printer) printElementPreserving(elt *Element) {
+	cst := elt.cst
+
+	if cst == nil || cst.Snapshot == nil || elt.Type() != cst.Snapshot.Type {
+		p.startLine()
+		p.printElement(elt)
+		return
+	}
+
+	switch content := elt.Content.(type) {
+	case *Block:
+		if elt.cstHeaderUnchanged() {
+			p.printTokens(cst.Start, cst.HeaderEnd)
+		} else {
+			p.startLine()
+			p.printComments(elt.LeadingComments)
+			p.printBlockStart(content, elt.TrailingComment)
+		}
+
+		p.level++
+		p.printChildrenPreserving(content.Elements, cst.Includes)
+
+		if elt.cstFooterUnchanged() {
+			p.level--
+			p.printTokens(cst.FooterStart, cst.End)
+		} else {
+			p.startLine()
+			p.printComments(content.EndComments)
+			p.level--
+			p.printBlockEnd(content)
+
+			if elt.FollowedByEmptyLine {
+				p.print("\n")
+			}
+		}
+
+	case *Entry:
+		if elt.cstHeaderUnchanged() {
+			p.printTokens(cst.Start, cst.End)
+		} else {
+			p.startLine()
+			p.printElement(elt)
+		}
+	}
+}
+
+// Print the elements of a block, replacing included elements by the include
+// directives they were read from. Each directive is printed once, including
+// directives whose elements were all removed, at its original position
+// relative to the other elements of the block.
+func (p *printer) printChildrenPreserving(elts []*Element, includes []*Element) {
+	printIncludes := func(pos int) {
+		for len(includes) > 0 && includes[0].cst.Start <= pos {
+			p.startLine()
+			p.printTokens(includes[0].cst.Start, includes[0].cst.End)
+			includes = includes[1:]
+		}
+	}
+
+	for _, elt := range elts {
+		if elt.include != nil {
+			if slices.Contains(includes, elt.include) {
+				printIncludes(elt.include.cst.Start)
+			}
+
+			continue
+		}
+
+		if elt.cst != nil {
+			printIncludes(elt.cst.Start - 1)
+		}
+
+		p.printElementPreserving(elt)
+	}
+
+	printIncludes(len(p.doc.tokens))
+}
+
+func (p *printer) printTokens(start, end int) {
+	for _, token := range p.doc.tokens[start:end] {
+		p.print(token.Trivia)
+		p.print(token.Data)
+	}
+}
+
+// Make sure that the next element printed starts on its own line, which is
+// not the case when the previous element is the last line of a document
+// without final EOL sequence.
+func (p *printer) startLine() {
+	if !p.atLineStart {
+		p.print("\n")
+	}
+}
+
+// Return the indentation used for the first element of the first top-level
+// block containing elements, or an empty string if there is none. New and
+// modified elements are indented the same way.
+func (doc *Document) cstIndent() string {
+	for _, elt := range doc.TopLevel.Content.(*Block).Elements {
+		block, ok := elt.Content.(*Block)
+		if !ok || elt.cst == nil {
+			continue
+		}
+
+		for _, child := range block.Elements {
+			if child.cst == nil || child.cst.Start >= len(doc.tokens) {
+				continue
+			}
+
+			trivia := doc.tokens[child.cst.Start].Trivia
+			if i := strings.LastIndexAny(trivia, "\n\\"); i >= 0 {
+				trivia = trivia[i+1:]
+			}
+
+			if trivia != "" && strings.Trim(trivia, " \t") == "" {
+				return trivia
+			}
+
+			break
+		}
+	}
+
+	return ""
+}
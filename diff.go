@@ -0,0 +1,161 @@
+package bcl
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type DifferenceType string
+
+const (
+	DifferenceTypeAdded   DifferenceType = "added"
+	DifferenceTypeRemoved DifferenceType = "removed"
+	DifferenceTypeChanged DifferenceType = "changed"
+)
+
+type Difference struct {
+	Type DifferenceType
+
+	// The path of the element (see Path), e.g. `server."api".port`. Paths are
+	// not unique when a block contains several entries with the same name or
+	// several unnamed blocks with the same type.
+	Path string
+
+	// The element in the first document, nil for added elements, and the
+	// element in the second document, nil for removed elements. Changed
+	// elements are always entries whose values are different.
+	Element1 *Element
+	Element2 *Element
+}
+
+// Compute the differences between two documents, ignoring formatting and
+// comments.
+//
+// Named blocks are matched by type and name and compared recursively.
+// Entries with the same name and unnamed blocks with the same type are first
+// matched with identical elements, then in order: entries are reported as
+// changed and blocks are compared recursively. Remaining elements are reported
+// as added or removed.
+func Diff(doc1, doc2 *Document) []*Difference {
+	var diffs []*Difference
+
+	diffBlocks(doc1.TopLevel.Content.(*Block), doc2.TopLevel.Content.(*Block),
+		nil, &diffs)
+
+	return diffs
+}
+
+func diffBlocks(block1, block2 *Block, path []string, diffs *[]*Difference) {
+	type group struct {
+		elts1 []*Element
+		elts2 []*Element
+	}
+
+	var keys []string
+	groups := make(map[string]*group)
+
+	addElements := func(elts []*Element, second bool) {
+		for _, elt := range elts {
+			key := string(elt.Type()) + ":" + elt.Id()
+
+			g := groups[key]
+			if g == nil {
+				g = &group{}
+				groups[key] = g
+				keys = append(keys, key)
+			}
+
+			if second {
+				g.elts2 = append(g.elts2, elt)
+			} else {
+				g.elts1 = append(g.elts1, elt)
+			}
+		}
+	}
+
+	addElements(block1.Elements, false)
+	addElements(block2.Elements, true)
+
+	for _, key := range keys {
+		g := groups[key]
+
+		elts1, elts2 := diffRemoveIdentical(g.elts1, g.elts2)
+
+		n := min(len(elts1), len(elts2))
+
+		for i := range n {
+			elt1, elt2 := elts1[i], elts2[i]
+			eltPath := append(slices.Clone(path), diffPathStep(elt1))
+
+			if block1, ok := elt1.Content.(*Block); ok {
+				diffBlocks(block1, elt2.Content.(*Block), eltPath, diffs)
+				continue
+			}
+
+			*diffs = append(*diffs, &Difference{
+				Type:     DifferenceTypeChanged,
+				Path:     strings.Join(eltPath, "."),
+				Element1: elt1,
+				Element2: elt2,
+			})
+		}
+
+		for _, elt := range elts1[n:] {
+			*diffs = append(*diffs, &Difference{
+				Type:     DifferenceTypeRemoved,
+				Path:     strings.Join(append(path, diffPathStep(elt)), "."),
+				Element1: elt,
+			})
+		}
+
+		for _, elt := range elts2[n:] {
+			*diffs = append(*diffs, &Difference{
+				Type:     DifferenceTypeAdded,
+				Path:     strings.Join(append(path, diffPathStep(elt)), "."),
+				Element2: elt,
+			})
+		}
+	}
+}
+
+// Remove pairs of identical elements from two lists of elements and return
+// the remaining elements.
+func diffRemoveIdentical(elts1, elts2 []*Element) ([]*Element, []*Element) {
+	var rest1 []*Element
+	matched := make([]bool, len(elts2))
+
+	for _, elt1 := range elts1 {
+		found := false
+
+		for j, elt2 := range elts2 {
+			if !matched[j] && elt1.Equal(elt2) {
+				matched[j] = true
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			rest1 = append(rest1, elt1)
+		}
+	}
+
+	var rest2 []*Element
+
+	for j, elt2 := range elts2 {
+		if !matched[j] {
+			rest2 = append(rest2, elt2)
+		}
+	}
+
+	return rest1, rest2
+}
+
+func diffPathStep(elt *Element) string {
+	if block, ok := elt.Content.(*Block); ok && block.Name != "" {
+		return block.Type + "." + strconv.Quote(block.Name)
+	}
+
+	return elt.Name()
+}
@@ -0,0 +1,68 @@
+package bcl
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		s1    string
+		s2    string
+		diffs []string // difference types and paths
+	}{
+		{"", "", nil},
+		{"a 1\n# foo\nb   2\n", "b 2 # bar\n\na 1", nil},
+		{"a 1\nb 2\n", "a 1\nb 3\n", []string{"changed b"}},
+		{"a 1\n", "a 1\nb 2\n", []string{"added b"}},
+		{"a 1\nb 2\n", "b 2\n", []string{"removed a"}},
+		{"a 1\na 2\n", "a 2\na 1\n", nil},
+		{"a 1\na 2\n", "a 2\na 3\na 4\n", []string{"changed a", "added a"}},
+		{"a 1\n", "a {}\n", []string{"removed a", "added a"}},
+		{
+			"x \"n\" {\n  a 1\n}\nx \"m\" {}\n",
+			"x \"n\" {\n  a 2\n}\nx \"o\" {}\n",
+			[]string{`changed x."n".a`, `removed x."m"`, `added x."o"`},
+		},
+		{
+			"x {\n  y {\n    a 1\n  }\n}\n",
+			"x {\n  y {\n    a 1\n    b [2]\n  }\n}\n",
+			[]string{"added x.y.b"},
+		},
+		{
+			"x {\n  a 1\n}\nx {\n  a 2\n}\n",
+			"x {\n  a 2\n}\nx {\n  a 3\n}\n",
+			[]string{"changed x.a"},
+		},
+		{"a 1", "a 1.0", []string{"changed a"}},
+		{"a \"x\"", "a x", []string{"changed a"}},
+		{"a {x = 1, y = 2}", "a {y = 2, x = 1}", nil},
+	}
+
+	for _, test := range tests {
+		doc1, err := Parse([]byte(test.s1), "test1")
+		if err != nil {
+			t.Fatalf("cannot parse %q: %v", test.s1, err)
+		}
+
+		doc2, err := Parse([]byte(test.s2), "test2")
+		if err != nil {
+			t.Fatalf("cannot parse %q: %v", test.s2, err)
+		}
+
+		var diffs []string
+		for _, diff := range Diff(doc1, doc2) {
+			diffs = append(diffs, string(diff.Type)+" "+diff.Path)
+
+			if (diff.Element1 == nil) != (diff.Type == DifferenceTypeAdded) ||
+				(diff.Element2 == nil) != (diff.Type == DifferenceTypeRemoved) {
+				t.Errorf("invalid elements in difference %+v", diff)
+			}
+		}
+
+		if !slices.Equal(diffs, test.diffs) {
+			t.Errorf("differences between %q and %q are %v instead of %v",
+				test.s1, test.s2, diffs, test.diffs)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package bcl
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
 )
@@ -9,6 +10,12 @@ type ElementReader interface {
 	ReadBCLElement(*Element) error
 }
 
+// ErrInvalidElement is returned by Element.Extract when a structure or a map
+// cannot be decoded. The errors found while decoding have already been added
+// to the element or to its children, so Element.AddValidationError ignores
+// this error.
+var ErrInvalidElement = errors.New("invalid element")
+
 func (elt *Element) Elements(name string, dest any) bool {
 	return extractElements(elt.FindElements(name), dest)
 }
@@ -84,6 +91,34 @@ func (elt *Element) Extract(dest any) error {
 	}
 
 	dv := reflect.ValueOf(dest)
+
+	if value := elt.inlineMap(); value != nil && dv.Kind() == reflect.Pointer &&
+		(dv.Elem().Kind() == reflect.Struct || dv.Elem().Kind() == reflect.Map) {
+		if err := value.Extract(dest); err != nil {
+			return &InvalidValueError{Value: value, Err: err}
+		}
+
+		return nil
+	}
+
+	if dv.Kind() == reflect.Pointer && dv.Elem().Kind() == reflect.Struct {
+		// Errors are added to the elements themselves while decoding
+		if !elt.Decode(dest) {
+			return ErrInvalidElement
+		}
+
+		return nil
+	}
+
+	if dv.Kind() == reflect.Pointer && dv.Elem().Kind() == reflect.Map {
+		// Same as for structures
+		if !elt.Map(dest) {
+			return ErrInvalidElement
+		}
+
+		return nil
+	}
+
 	if dv.Kind() == reflect.Pointer && dv.Elem().Kind() == reflect.Pointer {
 		dest2 := reflect.New(dv.Elem().Type().Elem())
 
@@ -98,6 +133,130 @@ func (elt *Element) Extract(dest any) error {
 	panic(fmt.Sprintf("cannot extract element to destination of type %T", dest))
 }
 
+// Fill a map[string]T with the children of a block. If T is a block type
+// (a structure, or a type implementing ElementReader), the map is filled with
+// named blocks, keys being block names, and with entries containing a single
+// inline map, keys being entry names; otherwise it is filled with entries,
+// keys being entry names and values being extracted as with Element.Values.
+// Other children are not read. Children with the same key are reported as
+// validation errors.
+func (elt *Element) Map(dest any) bool {
+	dv := reflect.ValueOf(dest)
+	if dv.Kind() != reflect.Pointer || dv.Elem().Kind() != reflect.Map ||
+		dv.Elem().Type().Key().Kind() != reflect.String {
+		panic(fmt.Sprintf("cannot extract elements to a value of type %T",
+			dest))
+	}
+
+	block := elt.CheckTypeBlock()
+	if block == nil {
+		return false
+	}
+
+	mapType := dv.Elem().Type()
+	valueType := mapType.Elem()
+	blocks := isBlockType(valueType)
+
+	m := reflect.MakeMap(mapType)
+	keyElts := make(map[string]*Element)
+
+	valid := true
+
+	for _, child := range block.Elements {
+		var key string
+
+		switch content := child.Content.(type) {
+		case *Block:
+			if !blocks {
+				continue
+			}
+
+			key = content.Name
+
+		case *Entry:
+			if blocks && child.inlineMap() == nil {
+				continue
+			}
+
+			key = content.Name
+		}
+
+		child.readStatus = ElementReadStatusRead
+
+		if key == "" {
+			child.AddMissingBlockNameError()
+			valid = false
+			continue
+		}
+
+		if prevElt := keyElts[key]; prevElt != nil {
+			child.AddDuplicateKeyError(key, prevElt)
+			valid = false
+			continue
+		}
+
+		keyElts[key] = child
+
+		value := reflect.New(valueType)
+
+		if blocks {
+			if err := child.Extract(value.Interface()); err != nil {
+				child.AddValidationError(err)
+				valid = false
+				continue
+			}
+		} else if !child.Values(value.Interface()) {
+			valid = false
+			continue
+		}
+
+		m.SetMapIndex(reflect.ValueOf(key).Convert(mapType.Key()), value.Elem())
+	}
+
+	if !valid {
+		return false
+	}
+
+	dv.Elem().Set(m)
+	return true
+}
+
+// Return the value of an entry containing a single inline map, or nil if the
+// element is not such an entry. These entries can be extracted to structures
+// and maps in the same way as blocks.
+func (elt *Element) inlineMap() *Value {
+	entry, ok := elt.Content.(*Entry)
+	if !ok || len(entry.Values) != 1 {
+		return nil
+	}
+
+	if _, ok := entry.Values[0].Content.(Map); !ok {
+		return nil
+	}
+
+	return entry.Values[0]
+}
+
+// Return the blocks of a specific type and the entries with the same name
+// containing a single inline map.
+func (elt *Element) findBlocksOrInlineMaps(name string) []*Element {
+	block := elt.CheckTypeBlock()
+	if block == nil {
+		return nil
+	}
+
+	var elts []*Element
+
+	for _, child := range block.Elements {
+		if child.Name() == name && (child.IsBlock() || child.inlineMap() != nil) {
+			child.readStatus = ElementReadStatusRead
+			elts = append(elts, child)
+		}
+	}
+
+	return elts
+}
+
 func extractElements(elts []*Element, dest any) bool {
 	dv := reflect.ValueOf(dest)
 	if dv.Kind() != reflect.Pointer || dv.Elem().Kind() != reflect.Slice {
@@ -115,6 +274,7 @@ func extractElements(elts []*Element, dest any) bool {
 
 		if err := child.Extract(value.Interface()); err != nil {
 			child.AddValidationError(err)
+			valid = false
 		}
 
 		slice = reflect.Append(slice, value.Elem())
@@ -0,0 +1,309 @@
+package bcl
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type mapTestServer struct {
+	Port int    `bcl:"port"`
+	Host string `bcl:"host"`
+}
+
+func TestElementMap(t *testing.T) {
+	tests := []struct {
+		s        string
+		dest     any
+		expected any
+	}{
+		{
+			"x {\n  a 1\n  b 2\n}",
+			new(map[string]int),
+			map[string]int{"a": 1, "b": 2},
+		},
+		{
+			"x {}",
+			new(map[string]int),
+			map[string]int{},
+		},
+		{
+			"x {\n  a 1 2\n  b\n  y {\n    c 3\n  }\n}",
+			new(map[string][]int),
+			map[string][]int{"a": {1, 2}, "b": {}},
+		},
+		{
+			"x {\n  a \"b\"\n  c d\n}",
+			new(map[string]string),
+			map[string]string{"a": "b", "c": "d"},
+		},
+		{
+			"x {\n  y \"a\" {\n    port 80\n  }\n  y \"b\" {\n    host \"h\"\n  }\n" +
+				"  z 1\n}",
+			new(map[string]mapTestServer),
+			map[string]mapTestServer{"a": {Port: 80}, "b": {Host: "h"}},
+		},
+		{
+			"x {\n  y \"a\" {\n    port 80\n  }\n}",
+			new(map[string]*mapTestServer),
+			map[string]*mapTestServer{"a": {Port: 80}},
+		},
+	}
+
+	for _, test := range tests {
+		doc, err := Parse([]byte(test.s), "test")
+		if err != nil {
+			t.Fatalf("cannot parse %q: %v", test.s, err)
+		}
+
+		elt := doc.TopLevel.FindBlock("x")
+
+		if !elt.Map(test.dest) {
+			t.Errorf("cannot read %q as a map: %v", test.s,
+				doc.ValidationErrors())
+			continue
+		}
+
+		dest := reflect.ValueOf(test.dest).Elem().Interface()
+
+		if !reflect.DeepEqual(dest, test.expected) {
+			t.Errorf("%q was read as %#v instead of %#v", test.s, dest,
+				test.expected)
+		}
+	}
+}
+
+func TestElementMapInvalid(t *testing.T) {
+	tests := []struct {
+		s    string
+		dest any
+		err  string
+	}{
+		{"x {\n  a 1\n  a 2\n}", new(map[string]int),
+			`duplicate key "a", previous element found line 2`},
+		{"x {\n  a \"b\"\n}", new(map[string]int), "value is a string"},
+		{"x {\n  a 1 2\n}", new(map[string]int), "1 value"},
+		{"x {\n  y {}\n}", new(map[string]mapTestServer), "empty block name"},
+	}
+
+	for _, test := range tests {
+		doc, err := Parse([]byte(test.s), "test")
+		if err != nil {
+			t.Fatalf("cannot parse %q: %v", test.s, err)
+		}
+
+		elt := doc.TopLevel.FindBlock("x")
+
+		if elt.Map(test.dest) {
+			t.Errorf("reading %q as a map should have failed", test.s)
+			continue
+		}
+
+		if reflect.ValueOf(test.dest).Elem().Len() != 0 {
+			t.Errorf("destination was modified after reading %q", test.s)
+		}
+
+		verrs := doc.ValidationErrors()
+		if verrs == nil {
+			t.Errorf("reading %q did not add any validation error", test.s)
+		} else if !strings.Contains(verrs.Error(), test.err) {
+			t.Errorf("reading %q failed with error %q which does not "+
+				"contain %q", test.s, verrs, test.err)
+		}
+	}
+
+	// As for slices of blocks, errors in blocks are added to their elements
+	doc, err := Parse([]byte("x {\n  y \"a\" {\n    port \"p\"\n  }\n}"),
+		"test")
+	if err != nil {
+		t.Fatalf("cannot parse document: %v", err)
+	}
+
+	var servers map[string]mapTestServer
+	if doc.TopLevel.FindBlock("x").Map(&servers) {
+		t.Errorf("reading an invalid block as a map should have failed")
+	}
+
+	if servers != nil {
+		t.Errorf("destination was modified after reading an invalid block")
+	}
+
+	if verrs := doc.ValidationErrors(); verrs == nil || len(verrs.Errs) != 1 {
+		t.Errorf("reading an invalid block added validation errors %v "+
+			"instead of a single error", verrs)
+	}
+
+	// Entries cannot be read as maps
+	doc, err = Parse([]byte("x 1"), "test")
+	if err != nil {
+		t.Fatalf("cannot parse document: %v", err)
+	}
+
+	var m map[string]int
+	if doc.TopLevel.FindEntry("x").Map(&m) {
+		t.Errorf("reading an entry as a map should have failed")
+	}
+}
+
+func TestUnmarshalMaps(t *testing.T) {
+	var config struct {
+		Limits  map[string]int            `bcl:"limits"`
+		Servers map[string]*mapTestServer `bcl:"servers"`
+		Groups  map[string][]string       `bcl:"groups"`
+	}
+
+	s := `
+limits {
+  cpu 4
+  memory 1024
+}
+
+servers {
+  server "a" {
+    port 80
+  }
+
+  server "b" {
+    port 81
+  }
+}
+
+groups {
+  dev "bob" "alice"
+  ops
+}
+`
+
+	if err := Unmarshal([]byte(s), "test", &config); err != nil {
+		t.Fatalf("cannot unmarshal document: %v", err)
+	}
+
+	limits := map[string]int{"cpu": 4, "memory": 1024}
+	if !reflect.DeepEqual(config.Limits, limits) {
+		t.Errorf("limits were unmarshaled as %v", config.Limits)
+	}
+
+	if len(config.Servers) != 2 || config.Servers["b"].Port != 81 {
+		t.Errorf("servers were unmarshaled as %v", config.Servers)
+	}
+
+	groups := map[string][]string{"dev": {"bob", "alice"}, "ops": {}}
+	if !reflect.DeepEqual(config.Groups, groups) {
+		t.Errorf("groups were unmarshaled as %v", config.Groups)
+	}
+
+	err := Unmarshal([]byte("limits {\n  cpu 1\n  cpu 2\n}"), "test", &config)
+
+	var verrs *ValidationErrors
+	if !errors.As(err, &verrs) {
+		t.Errorf("unmarshaling duplicate keys failed with error %v", err)
+	}
+}
+
+func TestElementCheckValueNull(t *testing.T) {
+	tests := []struct {
+		i       int
+		null    bool
+		notNull bool
+	}{
+		{0, true, false},
+		{1, false, true},
+		{2, false, false},
+	}
+
+	for _, test := range tests {
+		doc, err := Parse([]byte("a null 1"), "test")
+		if err != nil {
+			t.Fatalf("cannot parse document: %v", err)
+		}
+
+		elt := doc.TopLevel.FindEntry("a")
+
+		if elt.CheckValueNull(test.i) != test.null {
+			t.Errorf("CheckValueNull(%d) did not return %v", test.i, test.null)
+		}
+
+		if elt.CheckValueNotNull(test.i) != test.notNull {
+			t.Errorf("CheckValueNotNull(%d) did not return %v", test.i,
+				test.notNull)
+		}
+
+		if verrs := doc.ValidationErrors(); verrs == nil {
+			t.Errorf("checking value %d did not add any validation error",
+				test.i)
+		}
+	}
+
+	doc, err := Parse([]byte("a null 1"), "test")
+	if err != nil {
+		t.Fatalf("cannot parse document: %v", err)
+	}
+
+	elt := doc.TopLevel.FindEntry("a")
+	elt.CheckValueNotNull(0)
+	elt.CheckValueNull(1)
+
+	verrs := doc.ValidationErrors()
+	if verrs == nil {
+		t.Fatalf("checking values did not add any validation error")
+	}
+
+	for _, s := range []string{"value must not be null",
+		"value is an integer but should be null"} {
+		if !strings.Contains(verrs.Error(), s) {
+			t.Errorf("validation errors %q do not contain %q", verrs, s)
+		}
+	}
+}
+
+func TestElementExtractInvalid(t *testing.T) {
+	s := "x {\n  y \"a\" {\n    port 1\n  }\n  y \"b\" {\n    port \"p\"\n  }\n}"
+
+	tests := []struct {
+		name string
+		read func(*Element) bool
+	}{
+		{"Blocks", func(elt *Element) bool {
+			var servers []mapTestServer
+			return elt.Blocks("y", &servers) || servers != nil
+		}},
+		{"Map", func(elt *Element) bool {
+			var servers map[string]*mapTestServer
+			return elt.Map(&servers) || servers != nil
+		}},
+		{"Decode", func(elt *Element) bool {
+			var config struct {
+				Servers []mapTestServer `bcl:"y"`
+			}
+
+			return elt.Decode(&config) || config.Servers != nil
+		}},
+	}
+
+	for _, test := range tests {
+		doc, err := Parse([]byte(s), "test")
+		if err != nil {
+			t.Fatalf("cannot parse document: %v", err)
+		}
+
+		if test.read(doc.TopLevel.FindBlock("x")) {
+			t.Errorf("%s: reading invalid blocks should have failed",
+				test.name)
+		}
+
+		verrs := doc.ValidationErrors()
+		if verrs == nil {
+			t.Errorf("%s: reading invalid blocks did not add any validation "+
+				"error", test.name)
+			continue
+		}
+
+		for _, err := range verrs.Errs {
+			if errors.Is(err.Err, ErrInvalidElement) {
+				t.Errorf("%s: %v was added as a validation error", test.name,
+					err)
+			}
+		}
+	}
+}
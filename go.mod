@@ -3,8 +3,10 @@ module go.n16f.net/bcl
 go 1.23.2
 
 require (
+	github.com/BurntSushi/toml v1.5.0
 	go.n16f.net/pp v0.0.0-20241111134914-47a11939e3c4
 	go.n16f.net/program v0.0.0-20241208190041-4d0013a2857b
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
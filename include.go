@@ -0,0 +1,194 @@
+package bcl
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+)
+
+// State shared by the parser of a document and the parsers of all the files
+// it includes, directly or not.
+type includeState struct {
+	fsys fs.FS
+
+	// The chain of files being parsed, used to detect include cycles
+	stack []string
+
+	// Lines of all included files, indexed by path
+	lines map[string][]string
+}
+
+// Parse a file read from a filesystem. Include directives are resolved in
+// the same filesystem, paths being relative to the directory of the including
+// file.
+func ParseFile(fsys fs.FS, filePath string, opts ParseOptions) (*Document, error) {
+	data, err := fs.ReadFile(fsys, filePath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %q: %w", filePath, err)
+	}
+
+	opts.IncludeFS = fsys
+
+	return ParseWithOptions(data, filePath, opts)
+}
+
+// Return the lines of the document and of the files it includes.
+func (p *parser) sourceLines() SourceLines {
+	sourceLines := SourceLines{Lines: p.lines}
+
+	if p.includes != nil && len(p.includes.lines) > 0 {
+		sourceLines.Sources = p.includes.lines
+	}
+
+	return sourceLines
+}
+
+func isIncludeDirective(elt *Element) bool {
+	entry, ok := elt.Content.(*Entry)
+	return ok && entry.Name == "include"
+}
+
+// Parse the files referenced by an include directive and return their
+// top-level elements. Errors are signaled and the files concerned ignored.
+func (p *parser) resolveInclude(elt *Element) []*Element {
+	entry := elt.Content.(*Entry)
+
+	if len(entry.Values) == 0 {
+		p.signalError(p.syntaxErrorAt(elt.Location,
+			"invalid include directive: missing path"))
+		return nil
+	}
+
+	var elts []*Element
+
+	for _, value := range entry.Values {
+		s, ok := value.Content.(String)
+		if !ok || s.Sigil != "" {
+			p.signalError(p.syntaxErrorAt(value.Location,
+				"invalid include path: value must be a string"))
+			continue
+		}
+
+		filePaths, err := p.includePaths(s.String)
+		if err != nil {
+			p.signalError(p.syntaxErrorAt(value.Location,
+				"invalid include path %q: %v", s.String, err))
+			continue
+		}
+
+		for _, filePath := range filePaths {
+			elts = append(elts, p.includeFile(filePath, value.Location)...)
+		}
+	}
+
+	return elts
+}
+
+// Return the paths of the files matched by an include pattern in lexical
+// order. Patterns without any meta character must match an existing file;
+// other patterns may not match anything.
+func (p *parser) includePaths(pattern string) ([]string, error) {
+	var filePath string
+
+	if strings.HasPrefix(pattern, "/") {
+		filePath = path.Clean(pattern[1:])
+	} else {
+		dir := path.Dir(p.source)
+		if !fs.ValidPath(dir) {
+			// The source of the document is not a path in the filesystem,
+			// e.g. "<stdin>".
+			dir = "."
+		}
+
+		filePath = path.Join(dir, pattern)
+	}
+
+	if !fs.ValidPath(filePath) {
+		return nil, errors.New("path is outside of the filesystem")
+	}
+
+	if !strings.ContainsAny(filePath, `*?[\`) {
+		return []string{filePath}, nil
+	}
+
+	filePaths, err := fs.Glob(p.includes.fsys, filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	slices.Sort(filePaths)
+
+	return filePaths, nil
+}
+
+func (p *parser) includeFile(filePath string, location Span) []*Element {
+	includes := p.includes
+
+	if i := slices.Index(includes.stack, filePath); i >= 0 {
+		cycle := append(slices.Clone(includes.stack[i:]), filePath)
+		p.signalError(p.syntaxErrorAt(location, "include cycle: %s",
+			strings.Join(cycle, " -> ")))
+		return nil
+	}
+
+	data, err := fs.ReadFile(includes.fsys, filePath)
+	if err != nil {
+		p.signalError(p.syntaxErrorAt(location, "cannot read %q: %v",
+			filePath, err))
+		return nil
+	}
+
+	opts := ParseOptions{
+		RecoverErrors:        p.recoverErrors,
+		ValidateSigils:       p.validateSigils,
+		AllowNonFiniteFloats: p.allowNonFiniteFloats,
+	}
+
+	p2 := newParser(data, filePath, opts)
+	p2.includes = includes
+
+	includes.lines[filePath] = p2.lines
+
+	includes.stack = append(includes.stack, filePath)
+	doc, err := p2.Parse()
+	includes.stack = includes.stack[:len(includes.stack)-1]
+
+	if err != nil {
+		var parseErrs ParseErrors
+		var parseErr ParseError
+
+		if errors.As(err, &parseErrs) {
+			p.errs = append(p.errs, parseErrs.Errs...)
+		} else if errors.As(err, &parseErr) {
+			p.signalError(parseErr.Err)
+		} else {
+			p.signalError(err)
+		}
+	}
+
+	if doc == nil {
+		return nil
+	}
+
+	// Included elements are not part of the token stream of the document, so
+	// they cannot be printed using their original formatting.
+	elts := doc.TopLevel.Content.(*Block).Elements
+	for _, elt := range elts {
+		elt.clearCST()
+	}
+
+	return elts
+}
+
+func (elt *Element) clearCST() {
+	elt.cst = nil
+
+	if block, ok := elt.Content.(*Block); ok {
+		for _, child := range block.Elements {
+			child.clearCST()
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package bcl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+var testIncludeFS = fstest.MapFS{
+	"main.bcl":     {Data: []byte("a 1\ninclude \"conf.d/*.bcl\"\nb 2\n")},
+	"conf.d/x.bcl": {Data: []byte("x {\n  c 3\n}\n")},
+	"conf.d/y.bcl": {Data: []byte("include \"../lib/z.bcl\"\ny 4\n")},
+	"lib/z.bcl":    {Data: []byte("z 5\n")},
+
+	"cycle/a.bcl": {Data: []byte("include \"b.bcl\"\n")},
+	"cycle/b.bcl": {Data: []byte("include \"a.bcl\"\n")},
+
+	"dup/main.bcl":  {Data: []byte("x \"n\" {}\ninclude \"other.bcl\"\n")},
+	"dup/other.bcl": {Data: []byte("x \"n\" {}\n")},
+
+	"invalid.bcl": {Data: []byte("a 1x\n")},
+	"floats.bcl":  {Data: []byte("f +inf\n")},
+	"sigils.bcl":  {Data: []byte("s ~dur\"x\"\n")},
+}
+
+func TestParseFileInclude(t *testing.T) {
+	tests := []struct {
+		source   string
+		s        string
+		expected string
+	}{
+		{
+			"main.bcl",
+			"",
+			"a 1\nx {\n  c 3\n}\nz 5\ny 4\nb 2\n",
+		},
+		{
+			"test.bcl",
+			"include \"lib/z.bcl\" \"/lib/z.bcl\"\n",
+			"z 5\nz 5\n",
+		},
+		{
+			"<stdin>",
+			"include \"none/*.bcl\"\n",
+			"",
+		},
+		{
+			"lib/test.bcl",
+			"b {\n  include \"z.bcl\"\n}\n",
+			"b {\n  z 5\n}\n",
+		},
+	}
+
+	for _, test := range tests {
+		var doc *Document
+		var err error
+
+		opts := ParseOptions{IncludeFS: testIncludeFS}
+
+		if test.s == "" {
+			doc, err = ParseFile(testIncludeFS, test.source, opts)
+		} else {
+			doc, err = ParseWithOptions([]byte(test.s), test.source, opts)
+		}
+
+		if err != nil {
+			t.Errorf("cannot parse %q: %v", test.source, err)
+			continue
+		}
+
+		var buf bytes.Buffer
+		if err := doc.Print(&buf); err != nil {
+			t.Errorf("cannot print %q: %v", test.source, err)
+			continue
+		}
+
+		if s := buf.String(); s != test.expected {
+			t.Errorf("%q was parsed as %q instead of %q",
+				test.source, s, test.expected)
+		}
+	}
+}
+
+func TestParseFileIncludeLocation(t *testing.T) {
+	doc, err := ParseFile(testIncludeFS, "main.bcl", ParseOptions{})
+	if err != nil {
+		t.Fatalf("cannot parse file: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		source string
+		line   int
+	}{
+		{"a", "main.bcl", 1},
+		{"z", "lib/z.bcl", 1},
+		{"y", "conf.d/y.bcl", 2},
+		{"b", "main.bcl", 3},
+	}
+
+	for _, test := range tests {
+		elt := doc.TopLevel.FindEntry(test.name)
+		if elt == nil {
+			t.Errorf("missing entry %q", test.name)
+			continue
+		}
+
+		if elt.Location.Source != test.source ||
+			elt.Location.Start.Line != test.line {
+			t.Errorf("entry %q is located at %s:%d instead of %s:%d",
+				test.name, elt.Location.Source, elt.Location.Start.Line,
+				test.source, test.line)
+		}
+	}
+}
+
+func TestParseFileIncludeInvalid(t *testing.T) {
+	tests := []struct {
+		source string
+		s      string
+		err    string
+	}{
+		{"test.bcl", "include\n", "missing path"},
+		{"test.bcl", "include 42\n", "value must be a string"},
+		{"test.bcl", "include ~re\"x\"\n", "value must be a string"},
+		{"test.bcl", "include \"unknown.bcl\"\n", "cannot read"},
+		{"test.bcl", "include \"../x.bcl\"\n", "outside of the filesystem"},
+		{"test.bcl", "include \"[\"\n", "invalid include path"},
+		{"cycle/a.bcl", "", "cycle/a.bcl -> cycle/b.bcl -> cycle/a.bcl"},
+		{"dup/main.bcl", "", "duplicate block"},
+		{"test.bcl", "include \"invalid.bcl\"\n", "invalid.bcl:1:"},
+	}
+
+	for _, test := range tests {
+		var err error
+
+		opts := ParseOptions{IncludeFS: testIncludeFS}
+
+		if test.s == "" {
+			_, err = ParseFile(testIncludeFS, test.source, opts)
+		} else {
+			_, err = ParseWithOptions([]byte(test.s), test.source, opts)
+		}
+
+		if err == nil {
+			t.Errorf("parsing %q should have failed", test.source)
+			continue
+		}
+
+		if !strings.Contains(err.Error(), test.err) {
+			t.Errorf("parsing %q failed with error %q which does not "+
+				"contain %q", test.source, err, test.err)
+		}
+	}
+}
+
+func TestParseFileIncludeOptions(t *testing.T) {
+	tests := []struct {
+		path  string
+		opts  ParseOptions
+		valid bool
+	}{
+		{"floats.bcl", ParseOptions{}, false},
+		{"floats.bcl", ParseOptions{AllowNonFiniteFloats: true}, true},
+		{"sigils.bcl", ParseOptions{}, true},
+		{"sigils.bcl", ParseOptions{ValidateSigils: true}, false},
+	}
+
+	for _, test := range tests {
+		s := "include \"" + test.path + "\"\n"
+
+		opts := test.opts
+		opts.IncludeFS = testIncludeFS
+
+		_, err := ParseWithOptions([]byte(s), "test.bcl", opts)
+
+		if test.valid && err != nil {
+			t.Errorf("cannot parse %q with options %#v: %v", test.path,
+				test.opts, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("parsing %q with options %#v should have failed",
+				test.path, test.opts)
+		}
+	}
+}
+
+func TestParseIncludeDisabled(t *testing.T) {
+	doc, err := Parse([]byte("include \"main.bcl\"\n"), "test")
+	if err != nil {
+		t.Fatalf("cannot parse document: %v", err)
+	}
+
+	if doc.TopLevel.FindEntry("include") == nil {
+		t.Errorf("include directive was not kept as an entry")
+	}
+}
+
+func TestPrintPreservingInclude(t *testing.T) {
+	doc, err := ParseFile(testIncludeFS, "main.bcl", ParseOptions{})
+	if err != nil {
+		t.Fatalf("cannot parse file: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := doc.PrintPreserving(&buf); err != nil {
+		t.Fatalf("cannot print document: %v", err)
+	}
+
+	expected := string(testIncludeFS["main.bcl"].Data)
+
+	if s := buf.String(); s != expected {
+		t.Errorf("document was printed as %q instead of %q", s, expected)
+	}
+
+	// Removing all included elements keeps the include directive
+	block := doc.TopLevel.Content.(*Block)
+	block.Elements = []*Element{block.Elements[0], block.Elements[4]}
+
+	buf.Reset()
+	if err := doc.PrintPreserving(&buf); err != nil {
+		t.Fatalf("cannot print document: %v", err)
+	}
+
+	if s := buf.String(); s != expected {
+		t.Errorf("document was printed as %q instead of %q", s, expected)
+	}
+}
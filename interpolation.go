@@ -0,0 +1,240 @@
+package bcl
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// A function returning the value of a variable referenced in a string, e.g.
+// "env.HOME" for "${env.HOME}".
+type InterpolationResolver func(name string) (string, error)
+
+// Resolve "env.<name>" variables using environment variables.
+func EnvResolver(name string) (string, error) {
+	envName, found := strings.CutPrefix(name, "env.")
+	if !found {
+		return "", fmt.Errorf("unknown variable %q", name)
+	}
+
+	value, found := os.LookupEnv(envName)
+	if !found {
+		return "", fmt.Errorf("environment variable %q is not set", envName)
+	}
+
+	return value, nil
+}
+
+type InterpolationError struct {
+	Location Span
+	Err      error
+}
+
+func (err *InterpolationError) Error() string {
+	msg := err.Location.String() + ": " + err.Err.Error()
+	if err.Location.Source != "" {
+		msg = err.Location.Source + ":" + msg
+	}
+
+	return msg
+}
+
+func (err *InterpolationError) Unwrap() error {
+	return err.Err
+}
+
+type InterpolationErrors struct {
+	Errs []*InterpolationError
+	SourceLines
+}
+
+func (errs *InterpolationErrors) Error() string {
+	var buf bytes.Buffer
+
+	for _, err := range errs.Errs {
+		buf.WriteString("  - ")
+		buf.WriteString(err.Error())
+		buf.WriteByte('\n')
+
+		lines := errs.spanLines(&err.Location)
+		err.Location.PrintSource(&buf, lines, "      ")
+	}
+
+	return strings.TrimRight(buf.String(), "\n")
+}
+
+func (errs *InterpolationErrors) Unwrap() []error {
+	uerrs := make([]error, len(errs.Errs))
+	for i, err := range errs.Errs {
+		uerrs[i] = err
+	}
+
+	return uerrs
+}
+
+type valueInterpolation struct {
+	raw    any
+	result any
+}
+
+// Replace variable references in string values of all entries, including
+// strings in lists and maps, by the values returned by the resolver:
+//
+//   - "${<name>}" in strings without sigil is replaced by the value of the
+//     variable; "$${" is replaced by "${".
+//   - Strings with the "env" sigil, e.g. `~env"PORT"`, are replaced by a
+//     string without sigil containing the value of the "env.<string>"
+//     variable.
+//
+// Other strings are left unchanged. If some references cannot be resolved,
+// an *InterpolationErrors value is returned and all other strings are still
+// interpolated.
+//
+// Interpolated values are printed as they were before interpolation, unless
+// they are modified afterward. Interpolating a document again starts from the
+// values as they were before interpolation, so that the result of a previous
+// interpolation is never interpolated.
+func (doc *Document) Interpolate(resolver InterpolationResolver) error {
+	var errs []*InterpolationError
+
+	var walk func(*Element)
+	walk = func(elt *Element) {
+		switch content := elt.Content.(type) {
+		case *Block:
+			for _, child := range content.Elements {
+				walk(child)
+			}
+
+		case *Entry:
+			for _, value := range content.Values {
+				errs = append(errs, value.interpolate(resolver)...)
+			}
+		}
+	}
+
+	walk(doc.TopLevel)
+
+	if len(errs) > 0 {
+		return &InterpolationErrors{
+			Errs:        errs,
+			SourceLines: doc.sourceLines,
+		}
+	}
+
+	return nil
+}
+
+func (v *Value) interpolate(resolver InterpolationResolver) []*InterpolationError {
+	switch content := v.Content.(type) {
+	case List:
+		var errs []*InterpolationError
+		for _, child := range content {
+			errs = append(errs, child.interpolate(resolver)...)
+		}
+
+		return errs
+
+	case Map:
+		var errs []*InterpolationError
+		for _, entry := range content {
+			errs = append(errs, entry.Value.interpolate(resolver)...)
+		}
+
+		return errs
+	}
+
+	// Values interpolated by a previous call are interpolated again from
+	// their original content.
+	raw := v.RawContent()
+
+	s, ok := raw.(String)
+	if !ok {
+		return nil
+	}
+
+	var result string
+	var err error
+
+	switch s.Sigil {
+	case "":
+		result, err = interpolateString(s.String, resolver)
+	case "env":
+		result, err = resolver("env." + s.String)
+	default:
+		return nil
+	}
+
+	if err != nil {
+		return []*InterpolationError{{Location: v.Location, Err: err}}
+	}
+
+	content := String{String: result}
+
+	if content != s {
+		v.interpolation = &valueInterpolation{
+			raw:    raw,
+			result: content,
+		}
+	} else {
+		v.interpolation = nil
+	}
+
+	v.Content = content
+
+	return nil
+}
+
+func interpolateString(s string, resolver InterpolationResolver) (string, error) {
+	var buf strings.Builder
+
+	for {
+		i := strings.Index(s, "${")
+		if i == -1 {
+			buf.WriteString(s)
+			break
+		}
+
+		if i > 0 && s[i-1] == '$' {
+			buf.WriteString(s[:i-1])
+			buf.WriteString("${")
+			s = s[i+2:]
+			continue
+		}
+
+		buf.WriteString(s[:i])
+		s = s[i+2:]
+
+		end := strings.IndexByte(s, '}')
+		if end == -1 {
+			return "", errors.New("unterminated variable reference")
+		}
+
+		name := s[:end]
+		if name == "" {
+			return "", errors.New("empty variable reference")
+		}
+
+		value, err := resolver(name)
+		if err != nil {
+			return "", err
+		}
+
+		buf.WriteString(value)
+		s = s[end+1:]
+	}
+
+	return buf.String(), nil
+}
+
+// Return the content of the value as it was before interpolation, or the
+// content of the value if it was not interpolated or if it was modified after
+// interpolation.
+func (v *Value) RawContent() any {
+	if v.interpolation != nil && v.Content == v.interpolation.result {
+		return v.interpolation.raw
+	}
+
+	return v.Content
+}
@@ -0,0 +1,190 @@
+package bcl
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func testInterpolationResolver(name string) (string, error) {
+	switch name {
+	case "a":
+		return "foo", nil
+	case "b.c":
+		return "bar", nil
+	case "env.PORT":
+		return "8080", nil
+	case "empty":
+		return "", nil
+	case "ref":
+		return "${a}", nil
+	}
+
+	return "", fmt.Errorf("unknown variable %q", name)
+}
+
+func TestInterpolate(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected string
+	}{
+		{`x "${a}"`, `x "foo"`},
+		{`x "<${a}-${b.c}>"`, `x "<foo-bar>"`},
+		{`x "$${a}" "$$${a}"`, `x "${a}" "$${a}"`},
+		{`x "$a" "a}" "${empty}"`, `x "$a" "a}" ""`},
+		{`x ~env"PORT"`, `x "8080"`},
+		{`x ~re"${a}" sym 42`, `x ~re"${a}" sym 42`},
+		{`x ["${a}" ["${a}"]] {k = "${a}"}`, `x ["foo" ["foo"]] {k = "foo"}`},
+		{"y {\n  x \"${a}\"\n}", "y {\n  x \"foo\"\n}"},
+		{`x "${ref}" "$${ref}"`, `x "${a}" "${ref}"`},
+	}
+
+	for _, test := range tests {
+		doc, err := Parse([]byte(test.s), "test")
+		if err != nil {
+			t.Fatalf("cannot parse %q: %v", test.s, err)
+		}
+
+		expectedDoc, err := Parse([]byte(test.expected), "test")
+		if err != nil {
+			t.Fatalf("cannot parse %q: %v", test.expected, err)
+		}
+
+		if err := doc.Interpolate(testInterpolationResolver); err != nil {
+			t.Errorf("cannot interpolate %q: %v", test.s, err)
+			continue
+		}
+
+		// Interpolating a document again must not interpolate the result of
+		// the previous interpolation.
+		if err := doc.Interpolate(testInterpolationResolver); err != nil {
+			t.Errorf("cannot interpolate %q again: %v", test.s, err)
+			continue
+		}
+
+		if !doc.TopLevel.Equal(expectedDoc.TopLevel) {
+			var buf bytes.Buffer
+			doc.TopLevel.Print(&buf)
+
+			t.Errorf("%q was interpolated as %q instead of %q",
+				test.s, buf.String(), test.expected)
+		}
+	}
+}
+
+func TestInterpolateInvalid(t *testing.T) {
+	tests := []struct {
+		s    string
+		errs []string
+	}{
+		{`x "${unknown}"`, []string{`test:1:3-1:14: unknown variable "unknown"`}},
+		{`x "${a"`, []string{"test:1:3-1:7: unterminated variable reference"}},
+		{`x "${}"`, []string{"empty variable reference"}},
+		{
+			"x ~env\"HOME\"\ny [\"${a}\" \"${z}\"]",
+			[]string{`test:1:3-1:12: unknown variable "env.HOME"`,
+				`test:2:11-2:16: unknown variable "z"`},
+		},
+	}
+
+	for _, test := range tests {
+		doc, err := Parse([]byte(test.s), "test")
+		if err != nil {
+			t.Fatalf("cannot parse %q: %v", test.s, err)
+		}
+
+		err = doc.Interpolate(testInterpolationResolver)
+		if err == nil {
+			t.Errorf("interpolating %q should have failed", test.s)
+			continue
+		}
+
+		var ierrs *InterpolationErrors
+		if !errors.As(err, &ierrs) {
+			t.Errorf("interpolating %q failed with error %v", test.s, err)
+			continue
+		}
+
+		if len(ierrs.Errs) != len(test.errs) {
+			t.Errorf("interpolating %q failed with %d errors instead of %d: %v",
+				test.s, len(ierrs.Errs), len(test.errs), err)
+			continue
+		}
+
+		for i, ierr := range ierrs.Errs {
+			if !strings.Contains(ierr.Error(), test.errs[i]) {
+				t.Errorf("interpolating %q failed with error %q which does "+
+					"not contain %q", test.s, ierr, test.errs[i])
+			}
+		}
+	}
+}
+
+func TestInterpolatePrint(t *testing.T) {
+	s := "x \"${a}\"  ~env\"PORT\"\ny \"${a}\"\n"
+
+	doc, err := Parse([]byte(s), "test")
+	if err != nil {
+		t.Fatalf("cannot parse document: %v", err)
+	}
+
+	if err := doc.Interpolate(testInterpolationResolver); err != nil {
+		t.Fatalf("cannot interpolate document: %v", err)
+	}
+
+	// Modified values are printed with their new content
+	y := doc.TopLevel.FindEntry("y").Content.(*Entry)
+	y.Values[0].Content = String{String: "z"}
+
+	tests := []struct {
+		print    func(*bytes.Buffer) error
+		expected string
+	}{
+		{
+			func(buf *bytes.Buffer) error { return doc.Print(buf) },
+			"x \"${a}\" ~env\"PORT\"\ny \"z\"\n",
+		},
+		{
+			func(buf *bytes.Buffer) error { return doc.PrintPreserving(buf) },
+			"x \"${a}\"  ~env\"PORT\"\ny \"z\"\n",
+		},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		if err := test.print(&buf); err != nil {
+			t.Fatalf("cannot print document: %v", err)
+		}
+
+		if s := buf.String(); s != test.expected {
+			t.Errorf("document was printed as %q instead of %q",
+				s, test.expected)
+		}
+	}
+
+	x := doc.TopLevel.FindEntry("x").Content.(*Entry)
+
+	if content := x.Values[0].RawContent(); content != (String{String: "${a}"}) {
+		t.Errorf("raw content is %#v", content)
+	}
+
+	if content := x.Values[1].Content; content != (String{String: "8080"}) {
+		t.Errorf("content is %#v", content)
+	}
+}
+
+func TestEnvResolver(t *testing.T) {
+	t.Setenv("BCL_TEST", "foo")
+
+	if value, err := EnvResolver("env.BCL_TEST"); err != nil || value != "foo" {
+		t.Errorf("resolver returned %q, %v", value, err)
+	}
+
+	for _, name := range []string{"BCL_TEST", "env.BCL_TEST_UNKNOWN"} {
+		if _, err := EnvResolver(name); err == nil {
+			t.Errorf("resolving %q should have failed", name)
+		}
+	}
+}
@@ -0,0 +1,511 @@
+package bcl
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+type jsonElement struct {
+	Entry    *string           `json:"entry,omitempty"`
+	Values   []json.RawMessage `json:"values,omitempty"`
+	Block    *string           `json:"block,omitempty"`
+	Name     string            `json:"name,omitempty"`
+	Elements []*Element        `json:"elements,omitempty"`
+}
+
+type jsonString struct {
+	String string `json:"string"`
+	Sigil  string `json:"sigil,omitempty"`
+}
+
+type jsonSymbol struct {
+	Symbol string `json:"symbol"`
+}
+
+// Documents are represented in JSON as an array containing the top-level
+// elements of the document. Elements are represented as objects:
+//
+//   - Entries: {"entry": "<name>", "values": [<value>, ...]}
+//   - Blocks: {"block": "<type>", "name": "<name>", "elements": [...]}, the
+//     "name" member being omitted for unnamed blocks.
+//
+// Values are represented as follows:
+//
+//   - Booleans: JSON booleans.
+//   - Integers: JSON numbers without fractional part or exponent.
+//   - Floats: JSON numbers with a fractional part or an exponent (e.g. 1.0).
+//   - Strings without sigil: JSON strings.
+//   - Strings with a sigil: {"string": "<string>", "sigil": "<sigil>"}.
+//   - Symbols: {"symbol": "<symbol>"}.
+//   - Lists: JSON arrays.
+//   - Maps: {"map": {"<key>": <value>, ...}}, keys being in map order.
+//   - Null: JSON null.
+//
+// Element order and repeated entries are preserved, so converting a document
+// to JSON and back yields an equal document. Comments and formatting are not
+// represented.
+func ExportJSON(doc *Document) ([]byte, error) {
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(data, '\n'), nil
+}
+
+func ImportJSON(data []byte, source string) (*Document, error) {
+	var doc Document
+
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	doc.Source = source
+
+	return &doc, nil
+}
+
+func (doc *Document) MarshalJSON() ([]byte, error) {
+	elts := doc.TopLevel.Content.(*Block).Elements
+	if elts == nil {
+		elts = []*Element{}
+	}
+
+	return json.Marshal(elts)
+}
+
+func (doc *Document) UnmarshalJSON(data []byte) error {
+	var eltsData []json.RawMessage
+
+	if err := json.Unmarshal(data, &eltsData); err != nil {
+		return err
+	}
+
+	elts, err := unmarshalJSONBlockContent(eltsData)
+	if err != nil {
+		return err
+	}
+
+	doc.TopLevel = &Element{
+		Location: NewSpanAt(Point{0, 1, 1}, 0),
+		Content:  &Block{Elements: elts},
+	}
+
+	doc.ResetReadStatus()
+
+	return nil
+}
+
+// Decode elements one by one so that errors can be reported with the
+// position of the invalid element.
+func unmarshalJSONBlockContent(eltsData []json.RawMessage) ([]*Element, error) {
+	if eltsData == nil {
+		return nil, nil
+	}
+
+	elts := make([]*Element, len(eltsData))
+	blockTable := make(map[string]struct{})
+
+	for i, data := range eltsData {
+		pos := strconv.Itoa(i)
+
+		if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+			return nil, jsonErrorAt(errors.New("invalid null element"), pos)
+		}
+
+		var elt Element
+		if err := elt.UnmarshalJSON(data); err != nil {
+			return nil, jsonErrorAt(err, pos)
+		}
+
+		if block, ok := elt.Content.(*Block); ok && block.Name != "" {
+			id := elt.Id()
+
+			if _, found := blockTable[id]; found {
+				return nil, jsonErrorAt(fmt.Errorf("duplicate block %q", id),
+					pos)
+			}
+
+			blockTable[id] = struct{}{}
+		}
+
+		elts[i] = &elt
+	}
+
+	return elts, nil
+}
+
+// An error in a JSON document, located with the JSON pointer (RFC 6901) of
+// the invalid member, e.g. "/0/elements/2/entry".
+type jsonPathError struct {
+	Pointer string
+	Err     error
+}
+
+func (err *jsonPathError) Error() string {
+	return fmt.Sprintf("%s: %v", err.Pointer, err.Err)
+}
+
+func (err *jsonPathError) Unwrap() error {
+	return err.Err
+}
+
+// Prefix the location of an error with the reference tokens of the member
+// containing the invalid content.
+func jsonErrorAt(err error, tokens ...string) error {
+	var buf strings.Builder
+
+	for _, token := range tokens {
+		token = strings.ReplaceAll(token, "~", "~0")
+		token = strings.ReplaceAll(token, "/", "~1")
+
+		buf.WriteByte('/')
+		buf.WriteString(token)
+	}
+
+	if perr, ok := err.(*jsonPathError); ok {
+		return &jsonPathError{
+			Pointer: buf.String() + perr.Pointer,
+			Err:     perr.Err,
+		}
+	}
+
+	return &jsonPathError{Pointer: buf.String(), Err: err}
+}
+
+func (elt *Element) MarshalJSON() ([]byte, error) {
+	var je jsonElement
+
+	switch content := elt.Content.(type) {
+	case *Block:
+		je.Block = &content.Type
+		je.Name = content.Name
+		je.Elements = content.Elements
+
+		if je.Elements == nil {
+			je.Elements = []*Element{}
+		}
+
+	case *Entry:
+		je.Entry = &content.Name
+		je.Values = make([]json.RawMessage, len(content.Values))
+
+		for i, value := range content.Values {
+			data, err := value.MarshalJSON()
+			if err != nil {
+				return nil, err
+			}
+
+			je.Values[i] = data
+		}
+
+	default:
+		panic(fmt.Sprintf("unhandled element content %#v (%T)", elt, elt))
+	}
+
+	return json.Marshal(je)
+}
+
+func (elt *Element) UnmarshalJSON(data []byte) error {
+	// Child elements are decoded explicitly to report errors with their
+	// position.
+	var je struct {
+		jsonElement
+		Elements []json.RawMessage `json:"elements,omitempty"`
+	}
+
+	// Unknown members are rejected so that misspelled members are not
+	// silently ignored.
+	if err := unmarshalJSONStrict(data, &je); err != nil {
+		return err
+	}
+
+	switch {
+	case je.Entry != nil && je.Block != nil:
+		return errors.New("element cannot be both an entry and a block")
+
+	case je.Entry != nil:
+		if !isValidSymbol(*je.Entry) {
+			return jsonErrorAt(fmt.Errorf("invalid entry name %q", *je.Entry),
+				"entry")
+		}
+
+		if je.Name != "" || je.Elements != nil {
+			return fmt.Errorf("invalid members for entry %q", *je.Entry)
+		}
+
+		// Values are decoded explicitly since the JSON decoder would
+		// otherwise decode null values to nil pointers.
+		values := make([]*Value, len(je.Values))
+
+		for i, data := range je.Values {
+			var value Value
+			if err := value.UnmarshalJSON(data); err != nil {
+				return jsonErrorAt(err, "values", strconv.Itoa(i))
+			}
+
+			values[i] = &value
+		}
+
+		elt.Content = &Entry{
+			Name:   *je.Entry,
+			Values: values,
+		}
+
+	case je.Block != nil:
+		if !isValidSymbol(*je.Block) {
+			return jsonErrorAt(fmt.Errorf("invalid block type %q", *je.Block),
+				"block")
+		}
+
+		if je.Values != nil {
+			return fmt.Errorf("invalid member \"values\" for block %q",
+				*je.Block)
+		}
+
+		elts, err := unmarshalJSONBlockContent(je.Elements)
+		if err != nil {
+			return jsonErrorAt(err, "elements")
+		}
+
+		elt.Content = &Block{
+			Type:     *je.Block,
+			Name:     je.Name,
+			Elements: elts,
+		}
+
+	default:
+		return errors.New("missing \"entry\" or \"block\" member")
+	}
+
+	return nil
+}
+
+func (v *Value) MarshalJSON() ([]byte, error) {
+	switch content := v.Content.(type) {
+	case nil:
+		return []byte("null"), nil
+
+	case Symbol:
+		return json.Marshal(jsonSymbol{Symbol: string(content)})
+
+	case bool:
+		return json.Marshal(content)
+
+	case String:
+		if content.Sigil == "" {
+			return json.Marshal(content.String)
+		}
+
+		return json.Marshal(jsonString{
+			String: content.String,
+			Sigil:  content.Sigil,
+		})
+
+	case int64:
+		return []byte(strconv.FormatInt(content, 10)), nil
+
+	case *big.Int:
+		return []byte(content.String()), nil
+
+	case float64:
+		if math.IsInf(content, 0) || math.IsNaN(content) {
+			return nil, fmt.Errorf("cannot represent float %v in JSON", content)
+		}
+
+		// Floats must always be distinguishable from integers
+		s := strconv.FormatFloat(content, 'g', -1, 64)
+		if !bytes.ContainsAny([]byte(s), ".eE") {
+			s += ".0"
+		}
+
+		return []byte(s), nil
+
+	case List:
+		return json.Marshal([]*Value(content))
+
+	case Map:
+		// Keys are written in order, which encoding/json cannot do with Go
+		// maps.
+		var buf bytes.Buffer
+
+		buf.WriteString(`{"map":{`)
+
+		for i, entry := range content {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+
+			key, err := json.Marshal(entry.Key)
+			if err != nil {
+				return nil, err
+			}
+
+			value, err := entry.Value.MarshalJSON()
+			if err != nil {
+				return nil, err
+			}
+
+			buf.Write(key)
+			buf.WriteByte(':')
+			buf.Write(value)
+		}
+
+		buf.WriteString("}}")
+
+		return buf.Bytes(), nil
+
+	default:
+		panic(fmt.Sprintf("unhandled value %#v (%T)", v, v))
+	}
+}
+
+func (v *Value) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return errors.New("empty value")
+	}
+
+	switch c := data[0]; {
+	case c == 'n':
+		v.Content = nil
+
+	case c == 't' || c == 'f':
+		var b bool
+		if err := json.Unmarshal(data, &b); err != nil {
+			return err
+		}
+
+		v.Content = b
+
+	case c == '"':
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+
+		v.Content = String{String: s}
+
+	case c == '[':
+		// Elements are decoded one by one since decoding null to a *Value
+		// would yield a nil pointer.
+		var elts []json.RawMessage
+		if err := json.Unmarshal(data, &elts); err != nil {
+			return err
+		}
+
+		list := make(List, len(elts))
+		for i, elt := range elts {
+			list[i] = &Value{}
+			if err := list[i].UnmarshalJSON(elt); err != nil {
+				return jsonErrorAt(err, strconv.Itoa(i))
+			}
+		}
+
+		v.Content = list
+
+	case c == '{':
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(data, &obj); err != nil {
+			return err
+		}
+
+		if _, found := obj["symbol"]; found {
+			var js jsonSymbol
+			if err := unmarshalJSONStrict(data, &js); err != nil {
+				return fmt.Errorf("invalid symbol: %w", err)
+			}
+
+			v.Content = Symbol(js.Symbol)
+		} else if _, found := obj["string"]; found {
+			var js jsonString
+			if err := unmarshalJSONStrict(data, &js); err != nil {
+				return fmt.Errorf("invalid string: %w", err)
+			}
+
+			v.Content = String{String: js.String, Sigil: js.Sigil}
+		} else if mapData, found := obj["map"]; found && len(obj) == 1 {
+			m, err := unmarshalJSONMap(mapData)
+			if err != nil {
+				return fmt.Errorf("invalid map: %w", err)
+			}
+
+			v.Content = m
+		} else {
+			return errors.New("invalid value object: missing \"symbol\", " +
+				"\"string\" or \"map\" member")
+		}
+
+	case c == '-' || (c >= '0' && c <= '9'):
+		s := string(data)
+
+		if bytes.ContainsAny(data, ".eE") {
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return fmt.Errorf("invalid float: %w", err)
+			}
+
+			v.Content = f
+		} else {
+			i, err := strconv.ParseInt(s, 10, 64)
+			if err == nil {
+				v.Content = i
+			} else if bi, ok := new(big.Int).SetString(s, 10); ok {
+				v.Content = bi
+			} else {
+				return fmt.Errorf("invalid integer: %w", err)
+			}
+		}
+
+	default:
+		return fmt.Errorf("invalid value %s", data)
+	}
+
+	return nil
+}
+
+func unmarshalJSONStrict(data []byte, dest any) error {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.DisallowUnknownFields()
+	return d.Decode(dest)
+}
+
+func unmarshalJSONMap(data []byte) (Map, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+
+	if token, err := d.Token(); err != nil {
+		return nil, err
+	} else if token != json.Delim('{') {
+		return nil, errors.New("value is not an object")
+	}
+
+	m := Map{}
+
+	for d.More() {
+		token, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		key := token.(string)
+
+		if m.Get(key) != nil {
+			return nil, fmt.Errorf("duplicate key %q", key)
+		}
+
+		var value Value
+		if err := d.Decode(&value); err != nil {
+			return nil, fmt.Errorf("invalid value for key %q: %w", key, err)
+		}
+
+		m = append(m, &MapEntry{Key: key, Value: &value})
+	}
+
+	return m, nil
+}
@@ -0,0 +1,149 @@
+package bcl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestExportJSON(t *testing.T) {
+	tests := []struct {
+		s    string
+		json string
+	}{
+		{"", `[]`},
+		{
+			`a 1 2.0 true null`,
+			`[{"entry":"a","values":[1,2.0,true,null]}]`,
+		},
+		{
+			`a "x" ~re"y" z`,
+			`[{"entry":"a","values":["x",{"string":"y","sigil":"re"},` +
+				`{"symbol":"z"}]}]`,
+		},
+		{
+			`a [1 [2]] {b = 1, a = 2}`,
+			`[{"entry":"a","values":[[1,[2]],{"map":{"b":1,"a":2}}]}]`,
+		},
+		{
+			"b \"n\" {\n  c 1\n}\nd {}",
+			`[{"block":"b","name":"n","elements":[{"entry":"c","values":[1]}]},` +
+				`{"block":"d"}]`,
+		},
+	}
+
+	for _, test := range tests {
+		doc, err := Parse([]byte(test.s), "test")
+		if err != nil {
+			t.Errorf("cannot parse %q: %v", test.s, err)
+			continue
+		}
+
+		data, err := doc.MarshalJSON()
+		if err != nil {
+			t.Errorf("cannot export %q: %v", test.s, err)
+			continue
+		}
+
+		if string(data) != test.json {
+			t.Errorf("%q was exported as %s but should have been exported "+
+				"as %s", test.s, data, test.json)
+		}
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	tests := []string{
+		"a 1 -2 3.5 1e100 99999999999999999999 true false null",
+		`a "x" ~dur"1s" sym`,
+		"a [] [1 [2 null]] {x = {y = [true]}, \"a b\" = 1}",
+		"a\na 1\na 2 3",
+		"b \"n\" {\n  b \"m\" {\n    c 1\n  }\n}\nb \"o\" {}\nd {}",
+	}
+
+	for _, s := range tests {
+		doc, err := Parse([]byte(s), "test")
+		if err != nil {
+			t.Errorf("cannot parse %q: %v", s, err)
+			continue
+		}
+
+		data, err := ExportJSON(doc)
+		if err != nil {
+			t.Errorf("cannot export %q: %v", s, err)
+			continue
+		}
+
+		doc2, err := ImportJSON(data, "test")
+		if err != nil {
+			t.Errorf("cannot import %s: %v", data, err)
+			continue
+		}
+
+		if !doc.TopLevel.Equal(doc2.TopLevel) {
+			var buf bytes.Buffer
+			doc2.Print(&buf)
+
+			t.Errorf("%q was imported back as %q", s, buf.String())
+		}
+	}
+}
+
+func TestExportJSONInvalid(t *testing.T) {
+	doc, err := ParseWithOptions([]byte("a inf"), "test",
+		ParseOptions{AllowNonFiniteFloats: true})
+	if err != nil {
+		t.Fatalf("cannot parse document: %v", err)
+	}
+
+	if _, err := ExportJSON(doc); err == nil {
+		t.Errorf("exporting a non-finite float should have failed")
+	}
+}
+
+func TestImportJSONInvalid(t *testing.T) {
+	tests := []struct {
+		json string
+		err  string
+	}{
+		{`{}`, "cannot unmarshal"},
+		{`[null]`, "/0: invalid null element"},
+		{`[{}]`, `/0: missing "entry" or "block" member`},
+		{`[{"entry":"a","block":"b"}]`, "/0: element cannot be both"},
+		{`[{"entry":""}]`, `/0/entry: invalid entry name ""`},
+		{`[{"entry":"Foo"}]`, `/0/entry: invalid entry name "Foo"`},
+		{`[{"entry":"a b"}]`, `/0/entry: invalid entry name "a b"`},
+		{`[{"block":"1x"}]`, `/0/block: invalid block type "1x"`},
+		{
+			`[{"block":"a","elements":[{"entry":"b"},{"entry":"c-d"}]}]`,
+			`/0/elements/1/entry: invalid entry name "c-d"`,
+		},
+		{
+			`[{"block":"a","name":"x"},{"block":"a","name":"x"}]`,
+			`/1: duplicate block "a.x"`,
+		},
+		{`[{"entry":"a","values":[{"foo":1}]}]`, "/0/values/0: invalid value"},
+		{`[{"entry":"a","values":[[1,[{}]]]}]`, "/0/values/0/1/0: invalid value"},
+		{`[{"entry":"a","name":"x"}]`, `invalid members for entry "a"`},
+		{`[{"block":"a","values":[]}]`, `invalid member "values"`},
+		{`[{"entry":"a","value":[1]}]`, `/0: json: unknown field "value"`},
+		{
+			`[{"block":"a","elements":[{"entry":"b","values":[],"x":1}]}]`,
+			`/0/elements/0: json: unknown field "x"`,
+		},
+		{`[{"entry":"a","values":[{"map":{"a":1,"a":2}}]}]`, "duplicate key"},
+	}
+
+	for _, test := range tests {
+		_, err := ImportJSON([]byte(test.json), "test")
+		if err == nil {
+			t.Errorf("importing %s should have failed", test.json)
+			continue
+		}
+
+		if !strings.Contains(err.Error(), test.err) {
+			t.Errorf("importing %s failed with error %q which does not "+
+				"contain %q", test.json, err, test.err)
+		}
+	}
+}
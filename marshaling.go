@@ -0,0 +1,455 @@
+package bcl
+
+import (
+	"encoding"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"regexp"
+	"slices"
+	"time"
+)
+
+type ValueWriter interface {
+	WriteBCLValue() (*Value, error)
+}
+
+var valueWriterType = reflect.TypeFor[ValueWriter]()
+
+// Marshal builds a document from a Go value. The value must be a structure, a
+// pointer to a structure or a map indexed by strings. Struct fields are
+// mapped to elements using the same "bcl" tags as Unmarshal.
+//
+// Maps whose values are structures are written as named blocks, the map key
+// being the block name. Other maps are written as a block containing one
+// entry for each key. Slices are written as entries with one value per
+// element; nested slices and maps are written as lists and inline maps. Nil
+// pointers are written as entries containing a null value unless the field
+// is tagged with "omitempty".
+func Marshal(v any) (*Document, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil, fmt.Errorf("cannot marshal nil pointer of type %T", v)
+		}
+
+		rv = rv.Elem()
+	}
+
+	elts, err := encodeBlockContent(rv)
+	if err != nil {
+		return nil, err
+	}
+
+	topLevel := Element{
+		Location: NewSpanAt(Point{0, 1, 1}, 0),
+		Content:  &Block{Elements: elts},
+	}
+
+	doc := Document{
+		TopLevel: &topLevel,
+	}
+
+	doc.ResetReadStatus()
+
+	return &doc, nil
+}
+
+func encodeBlockContent(rv reflect.Value) ([]*Element, error) {
+	var elts []*Element
+	var err error
+
+	switch rv.Kind() {
+	case reflect.Struct:
+		elts, err = encodeStruct(rv)
+
+	case reflect.Map:
+		elts, err = encodeMap(rv)
+
+	default:
+		return nil, fmt.Errorf("cannot encode value of type %v as a block",
+			rv.Type())
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Separate blocks from the elements following them to keep generated
+	// documents readable.
+	for i, elt := range elts {
+		if elt.IsBlock() && i < len(elts)-1 {
+			elt.FollowedByEmptyLine = true
+		}
+	}
+
+	return elts, nil
+}
+
+func encodeStruct(rv reflect.Value) ([]*Element, error) {
+	var elts []*Element
+
+	for _, field := range structFields(rv.Type()) {
+		if field.Kind == fieldKindName {
+			continue
+		}
+
+		fv := rv.FieldByIndex(field.Index)
+		if field.OmitEmpty && fv.IsZero() {
+			continue
+		}
+
+		var fieldElts []*Element
+		var err error
+
+		switch field.Kind {
+		case fieldKindEntry:
+			fieldElts, err = encodeEntryField(field.Name, fv)
+		case fieldKindBlock:
+			fieldElts, err = encodeBlockField(field.Name, fv)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("cannot encode field %q: %w", field.Name, err)
+		}
+
+		elts = append(elts, fieldElts...)
+	}
+
+	return elts, nil
+}
+
+func encodeMap(rv reflect.Value) ([]*Element, error) {
+	if rv.Type().Key().Kind() != reflect.String {
+		return nil, fmt.Errorf("cannot encode map with keys of type %v",
+			rv.Type().Key())
+	}
+
+	var elts []*Element
+
+	for _, key := range sortedMapKeys(rv) {
+		name := key.String()
+		value := rv.MapIndex(key)
+
+		var keyElts []*Element
+		var err error
+
+		if isBlockType(value.Type()) {
+			keyElts, err = encodeBlockField(name, value)
+		} else {
+			keyElts, err = encodeEntryField(name, value)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("cannot encode map key %q: %w", name, err)
+		}
+
+		elts = append(elts, keyElts...)
+	}
+
+	return elts, nil
+}
+
+func encodeEntryField(name string, fv reflect.Value) ([]*Element, error) {
+	var values []*Value
+
+	// Nil pointers and interfaces are written as null values; fields are
+	// only skipped when they are tagged with "omitempty".
+	isNil := (fv.Kind() == reflect.Pointer || fv.Kind() == reflect.Interface) &&
+		fv.IsNil()
+
+	if isNil {
+		values = []*Value{{Content: nil}}
+	} else if isValueSlice(fv) {
+		values = make([]*Value, fv.Len())
+
+		for i := range fv.Len() {
+			value, err := encodeValue(fv.Index(i))
+			if err != nil {
+				return nil, err
+			}
+
+			values[i] = value
+		}
+	} else {
+		value, err := encodeValue(fv)
+		if err != nil {
+			return nil, err
+		}
+
+		values = []*Value{value}
+	}
+
+	elt := Element{
+		Content: &Entry{
+			Name:   name,
+			Values: values,
+		},
+	}
+
+	return []*Element{&elt}, nil
+}
+
+func encodeBlockField(btype string, fv reflect.Value) ([]*Element, error) {
+	switch fv.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if fv.IsNil() {
+			return nil, nil
+		}
+
+		return encodeBlockField(btype, fv.Elem())
+
+	case reflect.Slice, reflect.Array:
+		var elts []*Element
+
+		for i := range fv.Len() {
+			blockElts, err := encodeBlockField(btype, fv.Index(i))
+			if err != nil {
+				return nil, err
+			}
+
+			elts = append(elts, blockElts...)
+		}
+
+		return elts, nil
+
+	case reflect.Map:
+		if !isBlockType(fv.Type().Elem()) {
+			elt, err := encodeBlock(btype, "", fv)
+			if err != nil {
+				return nil, err
+			}
+
+			return []*Element{elt}, nil
+		}
+
+		if fv.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("cannot encode map with keys of type %v",
+				fv.Type().Key())
+		}
+
+		var elts []*Element
+
+		for _, key := range sortedMapKeys(fv) {
+			value := fv.MapIndex(key)
+			for value.Kind() == reflect.Pointer {
+				if value.IsNil() {
+					break
+				}
+
+				value = value.Elem()
+			}
+
+			if value.Kind() == reflect.Pointer {
+				continue
+			}
+
+			elt, err := encodeBlock(btype, key.String(), value)
+			if err != nil {
+				return nil, err
+			}
+
+			elts = append(elts, elt)
+		}
+
+		return elts, nil
+
+	case reflect.Struct:
+		var name string
+		for _, field := range structFields(fv.Type()) {
+			if field.Kind == fieldKindName {
+				name = fv.FieldByIndex(field.Index).String()
+			}
+		}
+
+		elt, err := encodeBlock(btype, name, fv)
+		if err != nil {
+			return nil, err
+		}
+
+		return []*Element{elt}, nil
+
+	default:
+		return nil, fmt.Errorf("cannot encode value of type %v as a block",
+			fv.Type())
+	}
+}
+
+func encodeBlock(btype, name string, rv reflect.Value) (*Element, error) {
+	elts, err := encodeBlockContent(rv)
+	if err != nil {
+		return nil, err
+	}
+
+	elt := Element{
+		Content: &Block{
+			Type:     btype,
+			Name:     name,
+			Elements: elts,
+		},
+	}
+
+	return &elt, nil
+}
+
+func encodeValue(rv reflect.Value) (*Value, error) {
+	if rv.Type().Implements(valueWriterType) {
+		if rv.Kind() == reflect.Pointer && rv.IsNil() {
+			return nil, fmt.Errorf("cannot encode nil value of type %v",
+				rv.Type())
+		}
+
+		return rv.Interface().(ValueWriter).WriteBCLValue()
+	}
+
+	if rv.CanAddr() && rv.Addr().Type().Implements(valueWriterType) {
+		return rv.Addr().Interface().(ValueWriter).WriteBCLValue()
+	}
+
+	var content any
+
+	switch v := rv.Interface().(type) {
+	case Symbol:
+		content = v
+
+	case String:
+		content = v
+
+	case []byte:
+		content = String{String: string(v)}
+
+	case time.Duration:
+		if v%time.Second == 0 {
+			content = int64(v / time.Second)
+		} else {
+			content = v.Seconds()
+		}
+
+	case *regexp.Regexp:
+		if v == nil {
+			return nil, fmt.Errorf("cannot encode nil regexp")
+		}
+
+		content = String{String: v.String()}
+
+	case *big.Int:
+		if v == nil {
+			return &Value{Content: nil}, nil
+		}
+
+		if v.IsInt64() {
+			content = v.Int64()
+		} else {
+			content = new(big.Int).Set(v)
+		}
+
+	case encoding.TextMarshaler:
+		data, err := v.MarshalText()
+		if err != nil {
+			return nil, err
+		}
+
+		content = String{String: string(data)}
+	}
+
+	if content == nil {
+		switch rv.Kind() {
+		case reflect.Bool:
+			content = rv.Bool()
+
+		case reflect.String:
+			content = String{String: rv.String()}
+
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+			reflect.Int64:
+			content = rv.Int()
+
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+			reflect.Uint64, reflect.Uintptr:
+			u := rv.Uint()
+			if u > math.MaxInt64 {
+				content = new(big.Int).SetUint64(u)
+			} else {
+				content = int64(u)
+			}
+
+		case reflect.Float32, reflect.Float64:
+			content = rv.Float()
+
+		case reflect.Pointer, reflect.Interface:
+			if rv.IsNil() {
+				return &Value{Content: nil}, nil
+			}
+
+			return encodeValue(rv.Elem())
+
+		case reflect.Slice, reflect.Array:
+			list := make(List, rv.Len())
+
+			for i := range rv.Len() {
+				value, err := encodeValue(rv.Index(i))
+				if err != nil {
+					return nil, err
+				}
+
+				list[i] = value
+			}
+
+			content = list
+
+		case reflect.Map:
+			if rv.Type().Key().Kind() != reflect.String {
+				return nil, fmt.Errorf("cannot encode map with keys of type %v",
+					rv.Type().Key())
+			}
+
+			m := make(Map, 0, rv.Len())
+
+			for _, key := range sortedMapKeys(rv) {
+				value, err := encodeValue(rv.MapIndex(key))
+				if err != nil {
+					return nil, err
+				}
+
+				m = append(m, &MapEntry{Key: key.String(), Value: value})
+			}
+
+			content = m
+
+		default:
+			return nil, fmt.Errorf("cannot encode value of type %v",
+				rv.Type())
+		}
+	}
+
+	return &Value{Content: content}, nil
+}
+
+func isValueSlice(rv reflect.Value) bool {
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		return rv.Type().Elem().Kind() != reflect.Uint8
+	}
+
+	return false
+}
+
+func sortedMapKeys(rv reflect.Value) []reflect.Value {
+	keys := rv.MapKeys()
+
+	slices.SortFunc(keys, func(k1, k2 reflect.Value) int {
+		s1, s2 := k1.String(), k2.String()
+
+		switch {
+		case s1 < s2:
+			return -1
+		case s1 > s2:
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	return keys
+}
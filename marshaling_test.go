@@ -0,0 +1,193 @@
+package bcl
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type marshalingTestServer struct {
+	Name    string        `bcl:",name"`
+	Address string        `bcl:"address"`
+	Timeout time.Duration `bcl:"timeout,omitempty"`
+}
+
+type marshalingTestConfig struct {
+	LogLevel string                  `bcl:"log_level"`
+	Ports    []int                   `bcl:"ports,omitempty"`
+	Limit    *int                    `bcl:"limit"`
+	MaxConns *int                    `bcl:"max_conns,omitempty"`
+	Labels   map[string]string       `bcl:"labels,entry,omitempty"`
+	Servers  []*marshalingTestServer `bcl:"server"`
+}
+
+func TestMarshal(t *testing.T) {
+	tests := []struct {
+		value any
+		s     string
+	}{
+		{
+			marshalingTestConfig{LogLevel: "info"},
+			"log_level \"info\"\nlimit null\n",
+		},
+		{
+			marshalingTestConfig{
+				LogLevel: "debug",
+				Ports:    []int{80, 443},
+				Limit:    ref(10),
+				MaxConns: ref(0),
+			},
+			"log_level \"debug\"\nports 80 443\nlimit 10\nmax_conns 0\n",
+		},
+		{
+			marshalingTestConfig{
+				LogLevel: "info",
+				Limit:    ref(1),
+				Labels:   map[string]string{"b": "2", "a": "1"},
+			},
+			"log_level \"info\"\nlimit 1\nlabels {a = \"1\", b = \"2\"}\n",
+		},
+		{
+			&marshalingTestConfig{
+				LogLevel: "info",
+				Limit:    ref(1),
+				Servers: []*marshalingTestServer{
+					{Name: "a", Address: "localhost:80"},
+					{Name: "b", Address: "localhost:81",
+						Timeout: 1500 * time.Millisecond},
+				},
+			},
+			`log_level "info"
+limit 1
+server "a" {
+  address "localhost:80"
+}
+
+server "b" {
+  address "localhost:81"
+  timeout 1.5
+}
+`,
+		},
+		{
+			map[string]any{"b": true, "a": "x", "c": nil},
+			"a \"x\"\nb true\nc null\n",
+		},
+	}
+
+	for _, test := range tests {
+		doc, err := Marshal(test.value)
+		if err != nil {
+			t.Errorf("cannot marshal %#v: %v", test.value, err)
+			continue
+		}
+
+		var buf bytes.Buffer
+		if err := doc.Print(&buf); err != nil {
+			t.Errorf("cannot print document: %v", err)
+			continue
+		}
+
+		if s := buf.String(); s != test.s {
+			t.Errorf("%#v was marshaled as:\n%s\nbut should have been "+
+				"marshaled as:\n%s", test.value, s, test.s)
+		}
+	}
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	config := marshalingTestConfig{
+		LogLevel: "warning",
+		Ports:    []int{8080},
+		Labels:   map[string]string{"env": "prod"},
+		Servers: []*marshalingTestServer{
+			{Name: "main", Address: "localhost:8080", Timeout: time.Minute},
+		},
+	}
+
+	doc, err := Marshal(config)
+	if err != nil {
+		t.Fatalf("cannot marshal value: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := doc.Print(&buf); err != nil {
+		t.Fatalf("cannot print document: %v", err)
+	}
+
+	config2 := marshalingTestConfig{Limit: ref(42)}
+	if err := Unmarshal(buf.Bytes(), "test", &config2); err != nil {
+		t.Fatalf("cannot unmarshal document: %v", err)
+	}
+
+	if config2.Limit != nil {
+		t.Errorf("null entry was unmarshaled as %d", *config2.Limit)
+	}
+
+	doc2, err := Marshal(config2)
+	if err != nil {
+		t.Fatalf("cannot marshal value: %v", err)
+	}
+
+	if !doc.TopLevel.Equal(doc2.TopLevel) {
+		t.Errorf("unmarshaled value %#v does not match original value %#v",
+			config2, config)
+	}
+}
+
+func TestMarshalInvalid(t *testing.T) {
+	tests := []any{
+		42,
+		(*marshalingTestConfig)(nil),
+		map[int]string{1: "a"},
+		struct {
+			C chan int `bcl:"c"`
+		}{},
+	}
+
+	for _, value := range tests {
+		if _, err := Marshal(value); err == nil {
+			t.Errorf("marshaling %#v should have failed", value)
+		}
+	}
+}
+
+func TestMarshalNestedSlices(t *testing.T) {
+	type config struct {
+		Strings [][]string `bcl:"strings"`
+		Ints    [][]int    `bcl:"ints,omitempty"`
+	}
+
+	tests := []config{
+		{Strings: [][]string{{"a", "b"}}},
+		{Strings: [][]string{{"a"}, {"b", "c"}}},
+		{Strings: [][]string{{}}},
+		{Strings: [][]string{{"a"}}, Ints: [][]int{{1, 2}, {3}}},
+	}
+
+	for _, value := range tests {
+		doc, err := Marshal(value)
+		if err != nil {
+			t.Errorf("cannot marshal %#v: %v", value, err)
+			continue
+		}
+
+		var buf bytes.Buffer
+		if err := doc.Print(&buf); err != nil {
+			t.Errorf("cannot print document: %v", err)
+			continue
+		}
+
+		var value2 config
+		if err := Unmarshal(buf.Bytes(), "test", &value2); err != nil {
+			t.Errorf("cannot unmarshal %q: %v", buf.String(), err)
+			continue
+		}
+
+		if !reflect.DeepEqual(value2, value) {
+			t.Errorf("%#v was marshaled as %q and unmarshaled as %#v",
+				value, buf.String(), value2)
+		}
+	}
+}
@@ -0,0 +1,352 @@
+package bcl
+
+import (
+	"fmt"
+	"maps"
+	"math/big"
+	"slices"
+)
+
+type MergeEntryPolicy string
+
+const (
+	// Entries of an overlay replace all entries with the same name in the
+	// block they are merged into.
+	MergeEntryPolicyReplace MergeEntryPolicy = "replace"
+
+	// Entries of an overlay are added after entries of the block they are
+	// merged into.
+	MergeEntryPolicyAppend MergeEntryPolicy = "append"
+)
+
+type MergeOptions struct {
+	// The default policy is MergeEntryPolicyReplace.
+	EntryPolicy MergeEntryPolicy
+}
+
+// Merge overlays into a base document. See MergeWithOptions.
+func Merge(base *Document, overlays ...*Document) (*Document, error) {
+	return MergeWithOptions(MergeOptions{}, base, overlays...)
+}
+
+// Merge overlays into a base document, each overlay being applied to the
+// result of the previous merge. Documents are not modified.
+//
+// Elements of an overlay block are merged into the block with the same
+// identifier (see Element.Id) in the base document:
+//
+//   - Blocks are merged recursively if the base block contains a block with
+//     the same identifier; they are added at the end of the base block
+//     otherwise.
+//   - Entries replace or are appended to entries with the same name according
+//     to the entry policy.
+//   - A "remove" entry deletes from the base block all elements whose
+//     identifiers are the values of the entry, e.g. `remove "port"
+//     "server.api"`. Removal markers are never copied to the result.
+//
+// Elements are processed in order, so an element can be removed then added
+// again in the same overlay.
+func MergeWithOptions(opts MergeOptions, base *Document, overlays ...*Document) (*Document, error) {
+	if opts.EntryPolicy == "" {
+		opts.EntryPolicy = MergeEntryPolicyReplace
+	}
+
+	switch opts.EntryPolicy {
+	case MergeEntryPolicyReplace, MergeEntryPolicyAppend:
+	default:
+		return nil, fmt.Errorf("invalid entry policy %q", opts.EntryPolicy)
+	}
+
+	doc := Document{
+		Source:   base.Source,
+		TopLevel: base.TopLevel.Clone(),
+
+		sourceLines: SourceLines{
+			Lines:   base.sourceLines.Lines,
+			Sources: maps.Clone(base.sourceLines.Sources),
+		},
+	}
+
+	for _, overlay := range overlays {
+		block := doc.TopLevel.Content.(*Block)
+		overlayBlock := overlay.TopLevel.Content.(*Block)
+
+		if err := block.merge(overlayBlock, opts); err != nil {
+			if overlay.Source != "" {
+				err = fmt.Errorf("%s: %w", overlay.Source, err)
+			}
+
+			return nil, err
+		}
+
+		// Keep the lines of all documents so that errors can be printed
+		// with the source of the elements they refer to.
+		if overlay.Source != "" && overlay.Source != doc.Source {
+			sources := doc.sourceLines.Sources
+			if sources == nil {
+				sources = make(map[string][]string)
+				doc.sourceLines.Sources = sources
+			}
+
+			sources[overlay.Source] = overlay.sourceLines.Lines
+			maps.Copy(sources, overlay.sourceLines.Sources)
+		}
+	}
+
+	doc.ResetReadStatus()
+
+	return &doc, nil
+}
+
+func (block *Block) merge(overlay *Block, opts MergeOptions) error {
+	// Entries of the base block are replaced by the first overlay entry with
+	// the same name; following overlay entries are added after it.
+	replacedEntries := make(map[string]*Element)
+
+	for _, elt := range overlay.Elements {
+		switch content := elt.Content.(type) {
+		case *Block:
+			if target := block.findMergeTarget(elt.Id()); target != nil {
+				if err := target.merge(content, opts); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			// Merging into an empty block applies the removal markers the
+			// overlay block contains instead of copying them.
+			newElt := elt.Clone()
+
+			newBlock := newElt.Content.(*Block)
+			newBlock.Elements = nil
+
+			if err := newBlock.merge(content, opts); err != nil {
+				return err
+			}
+
+			if err := block.mergeAppend(newElt); err != nil {
+				return err
+			}
+
+		case *Entry:
+			if content.Name == "remove" {
+				if err := block.mergeRemove(elt, replacedEntries); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			newElt := elt.Clone()
+
+			if opts.EntryPolicy == MergeEntryPolicyAppend {
+				if err := block.mergeAppend(newElt); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			if prevElt := replacedEntries[content.Name]; prevElt != nil {
+				i := slices.Index(block.Elements, prevElt)
+				newElt.FollowedByEmptyLine = prevElt.FollowedByEmptyLine
+				prevElt.FollowedByEmptyLine = false
+				block.Elements = slices.Insert(block.Elements, i+1, newElt)
+			} else if i := block.replaceEntries(content.Name, newElt); i == -1 {
+				if err := block.mergeAppend(newElt); err != nil {
+					return err
+				}
+			}
+
+			replacedEntries[content.Name] = newElt
+		}
+	}
+
+	return nil
+}
+
+func (block *Block) findMergeTarget(id string) *Block {
+	for _, elt := range block.Elements {
+		if child, ok := elt.Content.(*Block); ok && elt.Id() == id {
+			return child
+		}
+	}
+
+	return nil
+}
+
+func (block *Block) mergeAppend(elt *Element) error {
+	elt.FollowedByEmptyLine = false
+
+	if err := block.appendElement(elt); err != nil {
+		return fmt.Errorf("%v: cannot add element %q: %w", elt.Location,
+			elt.Id(), err)
+	}
+
+	return nil
+}
+
+// Replace all entries with a specific name by a single element placed at the
+// position of the first entry. Return this position, or -1 if the block does
+// not contain any entry with this name.
+func (block *Block) replaceEntries(name string, elt *Element) int {
+	pos := -1
+
+	for i := 0; i < len(block.Elements); i++ {
+		child := block.Elements[i]
+
+		if entry, ok := child.Content.(*Entry); !ok || entry.Name != name {
+			continue
+		}
+
+		if pos == -1 {
+			pos = i
+			elt.FollowedByEmptyLine = child.FollowedByEmptyLine
+			block.Elements[i] = elt
+			continue
+		}
+
+		// The replacing entry ends where the last replaced entry ended
+		elt.FollowedByEmptyLine = child.FollowedByEmptyLine
+
+		block.RemoveElement(child)
+		i--
+	}
+
+	return pos
+}
+
+// Remove the elements identified by a removal marker. Removed entries are
+// forgotten so that entries added later are merged as new entries.
+func (block *Block) mergeRemove(elt *Element, replacedEntries map[string]*Element) error {
+	entry := elt.Content.(*Entry)
+
+	for _, value := range entry.Values {
+		var id string
+
+		switch v := value.Content.(type) {
+		case String:
+			id = v.String
+		case Symbol:
+			id = string(v)
+		default:
+			return fmt.Errorf("%v: invalid removal marker: value must be a "+
+				"string or a symbol", value.Location)
+		}
+
+		for _, child := range slices.Clone(block.Elements) {
+			if child.Id() == id {
+				block.RemoveElement(child)
+			}
+		}
+
+		delete(replacedEntries, id)
+	}
+
+	return nil
+}
+
+// Return a deep copy of the element. The copy is not associated with the
+// original formatting of the element and is printed as a new element.
+func (elt *Element) Clone() *Element {
+	elt2 := Element{
+		Location:            elt.Location,
+		FollowedByEmptyLine: elt.FollowedByEmptyLine,
+		LeadingComments:     cloneComments(elt.LeadingComments),
+		TrailingComment:     cloneComment(elt.TrailingComment),
+		readStatus:          ElementReadStatusUnread,
+	}
+
+	switch content := elt.Content.(type) {
+	case *Block:
+		block := Block{
+			Type:           content.Type,
+			Name:           content.Name,
+			Elements:       make([]*Element, len(content.Elements)),
+			EndComments:    cloneComments(content.EndComments),
+			ClosingComment: cloneComment(content.ClosingComment),
+		}
+
+		for i, child := range content.Elements {
+			block.Elements[i] = child.Clone()
+		}
+
+		elt2.Content = &block
+
+	case *Entry:
+		entry := Entry{
+			Name:   content.Name,
+			Values: make([]*Value, len(content.Values)),
+		}
+
+		for i, value := range content.Values {
+			entry.Values[i] = value.Clone()
+		}
+
+		elt2.Content = &entry
+
+	default:
+		panic(fmt.Sprintf("unhandled element content %#v (%T)", elt, elt))
+	}
+
+	return &elt2
+}
+
+// Return a deep copy of the value.
+func (v *Value) Clone() *Value {
+	v2 := *v
+
+	v2.LeadingComments = cloneComments(v.LeadingComments)
+	v2.TrailingComment = cloneComment(v.TrailingComment)
+	v2.EndComments = cloneComments(v.EndComments)
+
+	switch content := v.Content.(type) {
+	case *big.Int:
+		v2.Content = new(big.Int).Set(content)
+
+	case List:
+		list := make(List, len(content))
+		for i, child := range content {
+			list[i] = child.Clone()
+		}
+
+		v2.Content = list
+
+	case Map:
+		m := make(Map, len(content))
+		for i, entry := range content {
+			entry2 := *entry
+			entry2.Value = entry.Value.Clone()
+			entry2.LeadingComments = cloneComments(entry.LeadingComments)
+			entry2.TrailingComment = cloneComment(entry.TrailingComment)
+			m[i] = &entry2
+		}
+
+		v2.Content = m
+	}
+
+	return &v2
+}
+
+func cloneComments(comments []*Comment) []*Comment {
+	if comments == nil {
+		return nil
+	}
+
+	comments2 := make([]*Comment, len(comments))
+	for i, comment := range comments {
+		comments2[i] = cloneComment(comment)
+	}
+
+	return comments2
+}
+
+func cloneComment(comment *Comment) *Comment {
+	if comment == nil {
+		return nil
+	}
+
+	comment2 := *comment
+	return &comment2
+}
@@ -0,0 +1,190 @@
+package bcl
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		policy   MergeEntryPolicy
+		base     string
+		overlays []string
+		expected string
+	}{
+		{
+			"",
+			"a 1\nb 2\n",
+			[]string{"b 3\nc 4\n"},
+			"a 1\nb 3\nc 4\n",
+		},
+		{
+			"",
+			"a 1\nb 2\nb 3\nc 4\n",
+			[]string{"b 5\nb 6\n"},
+			"a 1\nb 5\nb 6\nc 4\n",
+		},
+		{
+			MergeEntryPolicyAppend,
+			"a 1\nb 2\n",
+			[]string{"a 3\n"},
+			"a 1\nb 2\na 3\n",
+		},
+		{
+			"",
+			"x \"n\" {\n  a 1\n}\n",
+			[]string{"x \"n\" {\n  b 2\n}\nx \"m\" {\n  c 3\n}\n"},
+			"x \"n\" {\n  a 1\n  b 2\n}\n\nx \"m\" {\n  c 3\n}\n",
+		},
+		{
+			"",
+			"a 1\nb 2\nx \"n\" {}\n",
+			[]string{"remove a \"x.n\"\n", "remove b\nb 3\n"},
+			"b 3\n",
+		},
+		{
+			"",
+			"a 1\n",
+			[]string{"x {\n  remove y\n  b 2\n}\n"},
+			"a 1\n\nx {\n  b 2\n}\n",
+		},
+		{
+			"",
+			"a 1\n",
+			[]string{"b [\n  1 # one\n  # end\n]\n"},
+			"a 1\nb [\n  1 # one\n  # end\n]\n",
+		},
+		{
+			"",
+			"a 1\n",
+			[]string{"b {\n  # x\n  x = 1 # one\n}\n"},
+			"a 1\nb {\n  # x\n  x = 1 # one\n}\n",
+		},
+		{
+			"",
+			"a 1\nport 80\nb 2\n",
+			[]string{"port 1\nremove port\nport 2\n"},
+			"a 1\nb 2\nport 2\n",
+		},
+		{
+			"",
+			"a 1\nport 80\nb 2\n",
+			[]string{"port 1\nremove port\nport 2\nport 3\n"},
+			"a 1\nb 2\nport 2\nport 3\n",
+		},
+		{
+			"",
+			"a 1\n",
+			nil,
+			"a 1\n",
+		},
+	}
+
+	for _, test := range tests {
+		base, err := Parse([]byte(test.base), "base")
+		if err != nil {
+			t.Fatalf("cannot parse %q: %v", test.base, err)
+		}
+
+		var overlays []*Document
+		for _, s := range test.overlays {
+			overlay, err := Parse([]byte(s), "overlay")
+			if err != nil {
+				t.Fatalf("cannot parse %q: %v", s, err)
+			}
+
+			overlays = append(overlays, overlay)
+		}
+
+		var baseBuf bytes.Buffer
+		base.Print(&baseBuf)
+
+		opts := MergeOptions{EntryPolicy: test.policy}
+
+		doc, err := MergeWithOptions(opts, base, overlays...)
+		if err != nil {
+			t.Errorf("cannot merge %q into %q: %v",
+				test.overlays, test.base, err)
+			continue
+		}
+
+		var buf bytes.Buffer
+		if err := doc.Print(&buf); err != nil {
+			t.Errorf("cannot print document: %v", err)
+			continue
+		}
+
+		if s := buf.String(); s != test.expected {
+			t.Errorf("merging %q into %q returned %q instead of %q",
+				test.overlays, test.base, s, test.expected)
+		}
+
+		// Documents are not modified
+		buf.Reset()
+		base.Print(&buf)
+
+		if s := buf.String(); s != baseBuf.String() {
+			t.Errorf("merging modified base document %q into %q",
+				baseBuf.String(), s)
+		}
+	}
+}
+
+func TestMergeInvalid(t *testing.T) {
+	tests := []struct {
+		policy  MergeEntryPolicy
+		overlay string
+		err     string
+	}{
+		{"foo", "a 1", "invalid entry policy"},
+		{"", "remove 1", "overlay: 1:8: invalid removal marker"},
+		{"", "x {\n  remove [a]\n}", "overlay: 2:10-2:12: invalid removal marker"},
+	}
+
+	for _, test := range tests {
+		base, err := Parse([]byte("x {}"), "base")
+		if err != nil {
+			t.Fatalf("cannot parse base document: %v", err)
+		}
+
+		overlay, err := Parse([]byte(test.overlay), "overlay")
+		if err != nil {
+			t.Fatalf("cannot parse %q: %v", test.overlay, err)
+		}
+
+		opts := MergeOptions{EntryPolicy: test.policy}
+
+		_, err = MergeWithOptions(opts, base, overlay)
+		if err == nil {
+			t.Errorf("merging %q should have failed", test.overlay)
+			continue
+		}
+
+		if !strings.Contains(err.Error(), test.err) {
+			t.Errorf("merging %q failed with error %q which does not "+
+				"contain %q", test.overlay, err, test.err)
+		}
+	}
+}
+
+func TestMergeAppendDuplicate(t *testing.T) {
+	doc, err := Parse([]byte("x \"n\" {}\n"), "test")
+	if err != nil {
+		t.Fatalf("cannot parse document: %v", err)
+	}
+
+	block := doc.TopLevel.Content.(*Block)
+
+	err = block.mergeAppend(block.Elements[0].Clone())
+	if err == nil {
+		t.Fatalf("appending a duplicate named block should have failed")
+	}
+
+	var derr *DuplicateError
+	if !errors.As(err, &derr) {
+		t.Errorf("appending a duplicate named block failed with error %v",
+			err)
+	}
+}
@@ -0,0 +1,636 @@
+package bcl
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"unicode/utf8"
+)
+
+// Create a value from its content, which must be nil, a Symbol, a bool, a
+// String, an int64, a *big.Int, a float64, a List or a Map. The value has no
+// location until it is added to a document with Element.SetValues,
+// Element.AddEntry or Document.Set, which give it the location of the
+// element it is added to.
+func NewValue(content any) (*Value, error) {
+	switch content.(type) {
+	case nil, Symbol, bool, String, int64, *big.Int, float64, List, Map:
+	default:
+		return nil, fmt.Errorf("invalid value content %#v (%T)", content,
+			content)
+	}
+
+	return &Value{Content: content}, nil
+}
+
+func NewEntry(name string, values ...*Value) (*Element, error) {
+	if !isValidSymbol(name) {
+		return nil, fmt.Errorf("invalid entry name %q", name)
+	}
+
+	entry := Entry{
+		Name:   name,
+		Values: values,
+	}
+
+	elt := Element{
+		Content:    &entry,
+		readStatus: ElementReadStatusUnread,
+	}
+
+	return &elt, nil
+}
+
+func NewBlock(btype, name string) (*Element, error) {
+	if !isValidSymbol(btype) {
+		return nil, fmt.Errorf("invalid block type %q", btype)
+	}
+
+	block := Block{
+		Type: btype,
+		Name: name,
+	}
+
+	elt := Element{
+		Content:    &block,
+		readStatus: ElementReadStatusUnread,
+	}
+
+	return &elt, nil
+}
+
+// Append a new entry at the end of the block.
+func (block *Block) AddEntry(name string, values ...*Value) (*Element, error) {
+	elt, err := NewEntry(name, values...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := block.appendElement(elt); err != nil {
+		return nil, err
+	}
+
+	return elt, nil
+}
+
+// Append a new empty block at the end of the block. If the name is not empty
+// and the block already contains a block with the same type and name, a
+// DuplicateError is returned.
+func (block *Block) AddBlock(btype, name string) (*Element, error) {
+	elt, err := NewBlock(btype, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := block.appendElement(elt); err != nil {
+		return nil, err
+	}
+
+	return elt, nil
+}
+
+func (block *Block) appendElement(elt *Element) error {
+	if err := block.InsertElement(len(block.Elements), elt); err != nil {
+		return err
+	}
+
+	// Blocks are separated from other elements by an empty line, as in
+	// documents created by Marshal.
+	if n := len(block.Elements); n > 1 {
+		if prevElt := block.Elements[n-2]; prevElt.IsBlock() || elt.IsBlock() {
+			prevElt.FollowedByEmptyLine = true
+		}
+	}
+
+	return nil
+}
+
+// Insert an element at position i, i being between 0 and the number of
+// elements in the block. Named blocks must be unique in the block as they are
+// in parsed documents.
+func (block *Block) InsertElement(i int, elt *Element) error {
+	if i < 0 || i > len(block.Elements) {
+		return fmt.Errorf("invalid element position %d", i)
+	}
+
+	if slices.Contains(block.Elements, elt) {
+		return errors.New("element already present in block")
+	}
+
+	if err := block.checkNamedBlock(elt); err != nil {
+		return err
+	}
+
+	block.Elements = slices.Insert(block.Elements, i, elt)
+
+	return nil
+}
+
+// Remove an element from the block. Return false if the element is not a
+// child of the block.
+//
+// Leading comments separated from the element by an empty line, e.g. the
+// comment at the top of a document, do not belong to the element: they are
+// moved to the next element, or to the end of the block if the element is the
+// last one.
+func (block *Block) RemoveElement(elt *Element) bool {
+	i := slices.Index(block.Elements, elt)
+	if i == -1 {
+		return false
+	}
+
+	block.Elements = slices.Delete(block.Elements, i, i+1)
+
+	comments := detachedComments(elt.LeadingComments)
+	elt.LeadingComments = elt.LeadingComments[len(comments):]
+
+	switch {
+	case len(comments) > 0 && i < len(block.Elements):
+		next := block.Elements[i]
+		next.LeadingComments = append(comments, next.LeadingComments...)
+
+	case len(comments) > 0:
+		// The comments take the place of the last element, and are only
+		// separated by an empty line from the end comments of the block.
+		comments[len(comments)-1].FollowedByEmptyLine =
+			len(block.EndComments) > 0
+		block.EndComments = append(comments, block.EndComments...)
+
+	case i == len(block.Elements) && i > 0:
+		// When removing the last element, the new last element takes its
+		// place regarding the empty line before the end of the block.
+		block.Elements[i-1].FollowedByEmptyLine = elt.FollowedByEmptyLine
+	}
+
+	return true
+}
+
+// Return the leading comments which are separated from the element they
+// precede by an empty line.
+func detachedComments(comments []*Comment) []*Comment {
+	for i := len(comments) - 1; i >= 0; i-- {
+		if comments[i].FollowedByEmptyLine {
+			return slices.Clip(comments[:i+1])
+		}
+	}
+
+	return nil
+}
+
+// Replace an element of the block by another element.
+func (block *Block) ReplaceElement(elt, newElt *Element) error {
+	i := slices.Index(block.Elements, elt)
+	if i == -1 {
+		return errors.New("element not found in block")
+	}
+
+	if newElt != elt && slices.Contains(block.Elements, newElt) {
+		return errors.New("element already present in block")
+	}
+
+	if err := block.checkNamedBlock(newElt, elt); err != nil {
+		return err
+	}
+
+	newElt.FollowedByEmptyLine = elt.FollowedByEmptyLine
+	block.Elements[i] = newElt
+
+	return nil
+}
+
+// Change the name of an entry or the type of a block which is a child of the
+// block.
+func (block *Block) RenameElement(elt *Element, name string) error {
+	if !slices.Contains(block.Elements, elt) {
+		return errors.New("element not found in block")
+	}
+
+	if !isValidSymbol(name) {
+		return fmt.Errorf("invalid %s name %q", elt.Type(), name)
+	}
+
+	switch content := elt.Content.(type) {
+	case *Block:
+		prevType := content.Type
+		content.Type = name
+
+		if err := block.checkNamedBlock(elt, elt); err != nil {
+			content.Type = prevType
+			return err
+		}
+
+	case *Entry:
+		content.Name = name
+	}
+
+	return nil
+}
+
+// Change the name of a block which is a child of the block. An empty name
+// turns the block into an unnamed block.
+func (block *Block) RenameBlock(elt *Element, name string) error {
+	if !slices.Contains(block.Elements, elt) {
+		return errors.New("element not found in block")
+	}
+
+	child, ok := elt.Content.(*Block)
+	if !ok {
+		return fmt.Errorf("element %q is not a block", elt.Name())
+	}
+
+	prevName := child.Name
+	child.Name = name
+
+	if err := block.checkNamedBlock(elt, elt); err != nil {
+		child.Name = prevName
+		return err
+	}
+
+	return nil
+}
+
+func (block *Block) checkNamedBlock(elt *Element, ignoredElts ...*Element) error {
+	child, ok := elt.Content.(*Block)
+	if !ok || child.Name == "" {
+		return nil
+	}
+
+	for _, elt2 := range block.Elements {
+		if slices.Contains(ignoredElts, elt2) {
+			continue
+		}
+
+		if block2, ok := elt2.Content.(*Block); ok && block2.Name != "" {
+			if elt2.Id() == elt.Id() {
+				return &DuplicateError{
+					Element:         elt,
+					PreviousElement: elt2,
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (entry *Entry) SetValues(values ...*Value) {
+	entry.Values = values
+}
+
+// The following functions are equivalent to the functions operating on the
+// content of the element, but they also give new elements and values without
+// location the location of the element they are added to, so that
+// diagnostics about them point at an existing part of the document.
+
+// Append a new entry at the end of a block element.
+func (elt *Element) AddEntry(name string, values ...*Value) (*Element, error) {
+	child, err := NewEntry(name, values...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := elt.appendElement(child); err != nil {
+		return nil, err
+	}
+
+	return child, nil
+}
+
+// Append a new empty block at the end of a block element.
+func (elt *Element) AddBlock(btype, name string) (*Element, error) {
+	child, err := NewBlock(btype, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := elt.appendElement(child); err != nil {
+		return nil, err
+	}
+
+	return child, nil
+}
+
+func (elt *Element) appendElement(child *Element) error {
+	block, err := elt.mutableBlock()
+	if err != nil {
+		return err
+	}
+
+	if err := block.appendElement(child); err != nil {
+		return err
+	}
+
+	child.locate(elt.Location)
+
+	return nil
+}
+
+// Insert an element at position i in a block element.
+func (elt *Element) InsertElement(i int, child *Element) error {
+	block, err := elt.mutableBlock()
+	if err != nil {
+		return err
+	}
+
+	if err := block.InsertElement(i, child); err != nil {
+		return err
+	}
+
+	child.locate(elt.Location)
+
+	return nil
+}
+
+// Remove a child of a block element. Return false if the element is not a
+// child of the block.
+func (elt *Element) RemoveElement(child *Element) bool {
+	block, ok := elt.Content.(*Block)
+	if !ok {
+		return false
+	}
+
+	return block.RemoveElement(child)
+}
+
+// Replace a child of a block element by another element, which takes the
+// location of the replaced element if it does not have one.
+func (elt *Element) ReplaceElement(child, newChild *Element) error {
+	block, err := elt.mutableBlock()
+	if err != nil {
+		return err
+	}
+
+	if err := block.ReplaceElement(child, newChild); err != nil {
+		return err
+	}
+
+	newChild.locate(child.Location)
+
+	return nil
+}
+
+// Change the name of an entry or the type of a block which is a child of a
+// block element.
+func (elt *Element) RenameElement(child *Element, name string) error {
+	block, err := elt.mutableBlock()
+	if err != nil {
+		return err
+	}
+
+	return block.RenameElement(child, name)
+}
+
+// Change the name of a block which is a child of a block element.
+func (elt *Element) RenameBlock(child *Element, name string) error {
+	block, err := elt.mutableBlock()
+	if err != nil {
+		return err
+	}
+
+	return block.RenameBlock(child, name)
+}
+
+// Replace the values of an entry element.
+func (elt *Element) SetValues(values ...*Value) error {
+	entry, ok := elt.Content.(*Entry)
+	if !ok {
+		return fmt.Errorf("element %q is not an entry", elt.Id())
+	}
+
+	for _, value := range values {
+		value.locate(elt.Location)
+	}
+
+	entry.SetValues(values...)
+
+	return nil
+}
+
+func (elt *Element) mutableBlock() (*Block, error) {
+	block, ok := elt.Content.(*Block)
+	if !ok {
+		return nil, fmt.Errorf("element %q is not a block", elt.Id())
+	}
+
+	return block, nil
+}
+
+// Set the location of an element and of its content if it does not have
+// one.
+func (elt *Element) locate(span Span) {
+	if elt.Location.Start.Line != 0 {
+		return
+	}
+
+	elt.Location = span
+
+	switch content := elt.Content.(type) {
+	case *Block:
+		for _, child := range content.Elements {
+			child.locate(span)
+		}
+
+	case *Entry:
+		for _, value := range content.Values {
+			value.locate(span)
+		}
+	}
+}
+
+// Set the location of a value and of its children if it does not have one.
+func (v *Value) locate(span Span) {
+	if v.Location.Start.Line != 0 {
+		return
+	}
+
+	v.setLocation(span)
+}
+
+func (v *Value) setLocation(span Span) {
+	v.Location = span
+
+	switch content := v.Content.(type) {
+	case List:
+		for _, child := range content {
+			child.setLocation(span)
+		}
+
+	case Map:
+		for _, entry := range content {
+			entry.KeyLocation = span
+			entry.Value.setLocation(span)
+		}
+	}
+}
+
+// Return the block element containing an element, or nil if the element is
+// either the top-level element or not part of the document.
+func (doc *Document) FindParent(elt *Element) *Element {
+	var find func(*Element) *Element
+	find = func(parent *Element) *Element {
+		block, ok := parent.Content.(*Block)
+		if !ok {
+			return nil
+		}
+
+		for _, child := range block.Elements {
+			if child == elt {
+				return parent
+			}
+
+			if found := find(child); found != nil {
+				return found
+			}
+		}
+
+		return nil
+	}
+
+	return find(doc.TopLevel)
+}
+
+// Set the values of all entries selected by a path. If the path does not
+// select any element and if its last step is a name without block name or
+// predicate, a new entry is added to each block selected by the rest of the
+// path. If the path ends with a value index, the values at this position in
+// selected entries are replaced by the single value provided. Each entry
+// receives its own copy of the values, located at the position of the entry
+// or of the value it replaces.
+func (doc *Document) Set(path string, values ...*Value) error {
+	qp, err := ParsePath(path)
+	if err != nil {
+		return err
+	}
+
+	if qp.index != nil && len(values) != 1 {
+		return errors.New("a single value is required to replace an " +
+			"indexed value")
+	}
+
+	elts := qp.Query(doc.TopLevel)
+
+	if len(elts) == 0 {
+		if qp.index != nil {
+			return fmt.Errorf("no entry matching %q", path)
+		}
+
+		return doc.addEntries(qp, path, values)
+	}
+
+	for _, elt := range elts {
+		if !elt.IsEntry() {
+			return fmt.Errorf("path %q matches block %q", path, elt.Id())
+		}
+	}
+
+	for _, elt := range elts {
+		entry := elt.Content.(*Entry)
+
+		if qp.index != nil {
+			value := values[0].Clone()
+			value.setLocation(entry.Values[*qp.index].Location)
+
+			entry.Values[*qp.index] = value
+		} else {
+			values2 := cloneValues(values)
+			for _, value := range values2 {
+				value.setLocation(elt.Location)
+			}
+
+			entry.SetValues(values2...)
+		}
+	}
+
+	return nil
+}
+
+func (doc *Document) addEntries(qp *Path, path string, values []*Value) error {
+	lastStep := qp.steps[len(qp.steps)-1]
+	if lastStep.Name == "" || lastStep.BlockName != nil ||
+		len(lastStep.Predicates) > 0 {
+		return fmt.Errorf("no entry matching %q", path)
+	}
+
+	parents := []*Element{doc.TopLevel}
+	if len(qp.steps) > 1 {
+		parentPath := Path{steps: qp.steps[:len(qp.steps)-1]}
+
+		parents = nil
+		for _, parent := range parentPath.Query(doc.TopLevel) {
+			if parent.IsBlock() {
+				parents = append(parents, parent)
+			}
+		}
+
+		if len(parents) == 0 {
+			return fmt.Errorf("no block matching the parent of %q", path)
+		}
+	}
+
+	for _, parent := range parents {
+		values2 := cloneValues(values)
+		for _, value := range values2 {
+			value.setLocation(parent.Location)
+		}
+
+		if _, err := parent.AddEntry(lastStep.Name, values2...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func cloneValues(values []*Value) []*Value {
+	values2 := make([]*Value, len(values))
+	for i, value := range values {
+		values2[i] = value.Clone()
+	}
+
+	return values2
+}
+
+// Delete all elements selected by a path and return the number of deleted
+// elements. If the path ends with a value index, the values at this position
+// in selected entries are deleted instead.
+func (doc *Document) Delete(path string) (int, error) {
+	qp, err := ParsePath(path)
+	if err != nil {
+		return 0, err
+	}
+
+	elts := qp.Query(doc.TopLevel)
+
+	for _, elt := range elts {
+		if qp.index != nil {
+			entry := elt.Content.(*Entry)
+			entry.Values = slices.Delete(entry.Values, *qp.index, *qp.index+1)
+			continue
+		}
+
+		parent := doc.FindParent(elt)
+		parent.Content.(*Block).RemoveElement(elt)
+	}
+
+	return len(elts), nil
+}
+
+func isValidSymbol(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	first, _ := utf8.DecodeRuneInString(s)
+	if !isSymbolFirstChar(first) {
+		return false
+	}
+
+	for _, c := range s {
+		if !isSymbolChar(c) {
+			return false
+		}
+	}
+
+	return true
+}
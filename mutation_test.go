@@ -0,0 +1,330 @@
+package bcl
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func testMutationDocument(t *testing.T, s string) *Document {
+	t.Helper()
+
+	doc, err := Parse([]byte(s), "test")
+	if err != nil {
+		t.Fatalf("cannot parse %q: %v", s, err)
+	}
+
+	return doc
+}
+
+func testCheckDocument(t *testing.T, doc *Document, expected string) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := doc.PrintPreserving(&buf); err != nil {
+		t.Fatalf("cannot print document: %v", err)
+	}
+
+	if s := buf.String(); s != expected {
+		t.Errorf("document was printed as:\n%s\nbut should have been "+
+			"printed as:\n%s", s, expected)
+	}
+}
+
+func TestNewValue(t *testing.T) {
+	valid := []any{nil, Symbol("a"), true, String{String: "a"}, int64(1),
+		1.5, List{}, Map{}}
+
+	for _, content := range valid {
+		value, err := NewValue(content)
+		if err != nil {
+			t.Errorf("cannot create value from %#v: %v", content, err)
+			continue
+		}
+
+		if value.Location.Start.Line != 0 {
+			t.Errorf("new value has location %v", value.Location)
+		}
+	}
+
+	invalid := []any{1, "a", []int{1}, map[string]any{}, float32(1)}
+
+	for _, content := range invalid {
+		if _, err := NewValue(content); err == nil {
+			t.Errorf("creating a value from %#v should have failed", content)
+		}
+	}
+}
+
+func TestDocumentSet(t *testing.T) {
+	tests := []struct {
+		s        string
+		path     string
+		values   []string
+		expected string
+	}{
+		{
+			"a 1 # foo\nb   2\n",
+			"b",
+			[]string{"3", `"x"`},
+			"a 1 # foo\nb 3 \"x\"\n",
+		},
+		{
+			"a 1\n",
+			"b",
+			[]string{"hello"},
+			"a 1\nb hello\n",
+		},
+		{
+			"x {\n  a 1\n}\n\nx {\n    a 2\n}\n",
+			"x.a",
+			[]string{"[1 2]"},
+			"x {\n  a [1 2]\n}\n\nx {\n  a [1 2]\n}\n",
+		},
+		{
+			"x {\n  a 1\n}\n",
+			"x.b",
+			[]string{"true"},
+			"x {\n  a 1\n  b true\n}\n",
+		},
+		{
+			"a 1 2 3\n",
+			"a[1]",
+			[]string{"null"},
+			"a 1 null 3\n",
+		},
+	}
+
+	for _, test := range tests {
+		doc := testMutationDocument(t, test.s)
+
+		values := make([]*Value, len(test.values))
+		for i, s := range test.values {
+			value, err := ParseValue(s)
+			if err != nil {
+				t.Fatalf("cannot parse value %q: %v", s, err)
+			}
+
+			values[i] = value
+		}
+
+		if err := doc.Set(test.path, values...); err != nil {
+			t.Errorf("cannot set %q in %q: %v", test.path, test.s, err)
+			continue
+		}
+
+		testCheckDocument(t, doc, test.expected)
+	}
+}
+
+func TestDocumentSetInvalid(t *testing.T) {
+	tests := []struct {
+		s      string
+		path   string
+		values int
+	}{
+		{"a {}", "a", 1},
+		{"a 1", "a[0]", 2},
+		{"a 1", "b[0]", 1},
+		{"a 1", "x.b", 1},
+		{"a 1", "a[", 1},
+		{"a 1", `b."x"`, 1},
+	}
+
+	for _, test := range tests {
+		doc := testMutationDocument(t, test.s)
+
+		values := make([]*Value, test.values)
+		for i := range values {
+			values[i] = &Value{Content: int64(i)}
+		}
+
+		if err := doc.Set(test.path, values...); err == nil {
+			t.Errorf("setting %q in %q should have failed", test.path, test.s)
+		}
+	}
+}
+
+func TestDocumentSetLocation(t *testing.T) {
+	doc := testMutationDocument(t, "a 1\nx {\n  b 2 3\n}\n")
+
+	value, _ := ParseValue("[42]")
+
+	if err := doc.Set("x.b", value); err != nil {
+		t.Fatalf("cannot set entry: %v", err)
+	}
+
+	if err := doc.Set("x.c", value); err != nil {
+		t.Fatalf("cannot set entry: %v", err)
+	}
+
+	if err := doc.Set("a[0]", value); err != nil {
+		t.Fatalf("cannot set entry: %v", err)
+	}
+
+	tests := []struct {
+		path string
+		line int
+	}{
+		{"x.b", 3},
+		{"x.c", 2},
+		{"a", 1},
+	}
+
+	for _, test := range tests {
+		values, err := doc.QueryValues(test.path)
+		if err != nil {
+			t.Fatalf("cannot query %q: %v", test.path, err)
+		}
+
+		for _, value := range values {
+			if line := value.Location.Start.Line; line != test.line {
+				t.Errorf("value of %q is located on line %d instead of "+
+					"line %d", test.path, line, test.line)
+			}
+
+			child := value.Content.(List)[0]
+			if line := child.Location.Start.Line; line != test.line {
+				t.Errorf("list element of %q is located on line %d instead "+
+					"of line %d", test.path, line, test.line)
+			}
+		}
+	}
+}
+
+func TestDocumentDelete(t *testing.T) {
+	tests := []struct {
+		s        string
+		path     string
+		n        int
+		expected string
+	}{
+		{"a 1\nb 2\n", "a", 1, "b 2\n"},
+		{"a 1\nb 2\n", "c", 0, "a 1\nb 2\n"},
+		{"x {\n  a 1\n}\nx {\n  a 2\n  b 3\n}\n", "x.a", 2,
+			"x {\n}\nx {\n  b 3\n}\n"},
+		{"a 1 2 3\n", "a[1]", 1, "a 1 3\n"},
+		{"x \"a\" {}\nx \"b\" {}\n", `x."a"`, 1, "x \"b\" {}\n"},
+		{"# header\n\na 1\nb 2\n", "a", 1, "# header\n\nb 2\n"},
+		{"# header\n\n# a\na 1\n# b\nb 2\n", "a", 1,
+			"# header\n\n# b\nb 2\n"},
+		{"a 1\n\n# section\n\nb 2\n", "b", 1, "a 1\n\n# section\n"},
+		{"x {\n  # x\n\n  a 1\n  # end\n}\n", "x.a", 1,
+			"x {\n  # x\n\n  # end\n}\n"},
+	}
+
+	for _, test := range tests {
+		doc := testMutationDocument(t, test.s)
+
+		n, err := doc.Delete(test.path)
+		if err != nil {
+			t.Errorf("cannot delete %q in %q: %v", test.path, test.s, err)
+			continue
+		}
+
+		if n != test.n {
+			t.Errorf("deleting %q in %q deleted %d elements instead of %d",
+				test.path, test.s, n, test.n)
+		}
+
+		testCheckDocument(t, doc, test.expected)
+	}
+}
+
+func TestElementMutation(t *testing.T) {
+	doc := testMutationDocument(t, "x {\n  a 1\n}\ny \"n\" {}\n")
+
+	x := doc.FindBlock("x")
+	y := doc.FindNamedBlock("y", "n")
+	a := x.FindEntry("a")
+
+	value, err := NewValue(int64(2))
+	if err != nil {
+		t.Fatalf("cannot create value: %v", err)
+	}
+
+	b, err := x.AddEntry("b", value)
+	if err != nil {
+		t.Fatalf("cannot add entry: %v", err)
+	}
+
+	if b.Location != x.Location || value.Location != x.Location {
+		t.Errorf("new entry has location %v and value %v instead of %v",
+			b.Location, value.Location, x.Location)
+	}
+
+	c, err := x.AddBlock("c", "")
+	if err != nil {
+		t.Fatalf("cannot add block: %v", err)
+	}
+
+	if err := x.RenameElement(c, "d"); err != nil {
+		t.Fatalf("cannot rename block: %v", err)
+	}
+
+	if err := x.RenameBlock(c, "n"); err != nil {
+		t.Fatalf("cannot rename block: %v", err)
+	}
+
+	if err := a.SetValues(&Value{Content: Symbol("z")}); err != nil {
+		t.Fatalf("cannot set values: %v", err)
+	}
+
+	if a.Content.(*Entry).Values[0].Location != a.Location {
+		t.Errorf("new value of %q is not located at the entry", a.Id())
+	}
+
+	if !doc.TopLevel.RemoveElement(y) {
+		t.Errorf("cannot remove block")
+	}
+
+	testCheckDocument(t, doc, "x {\n  a z\n  b 2\n\n  d \"n\" {\n  }\n}\n")
+
+	// Invalid operations
+	if _, err := a.AddEntry("e"); err == nil {
+		t.Errorf("adding an entry to an entry should have failed")
+	}
+
+	if err := x.SetValues(value); err == nil {
+		t.Errorf("setting the values of a block should have failed")
+	}
+
+	if _, err := x.AddEntry("E"); err == nil {
+		t.Errorf("adding an entry with an invalid name should have failed")
+	}
+
+	if _, err := x.AddBlock("d", "n"); err == nil {
+		t.Errorf("adding a duplicate named block should have failed")
+	} else {
+		var derr *DuplicateError
+		if !errors.As(err, &derr) {
+			t.Errorf("adding a duplicate named block failed with error %v",
+				err)
+		}
+	}
+
+	if err := x.RenameElement(y, "z"); err == nil {
+		t.Errorf("renaming an element which is not a child should have failed")
+	}
+
+	if err := x.InsertElement(10, a); err == nil {
+		t.Errorf("inserting an element at an invalid position should have " +
+			"failed")
+	}
+
+	if err := x.InsertElement(0, a); err == nil {
+		t.Errorf("inserting an element twice should have failed")
+	}
+
+	if x.RemoveElement(y) {
+		t.Errorf("removing an element which is not a child should have failed")
+	}
+
+	if err := x.ReplaceElement(a, b); err == nil {
+		t.Errorf("replacing an element by another child should have failed")
+	}
+
+	if err := x.ReplaceElement(a, a); err != nil {
+		t.Errorf("cannot replace an element by itself: %v", err)
+	}
+}
@@ -2,6 +2,9 @@ package bcl
 
 import (
 	"fmt"
+	"math"
+	"path"
+	"strings"
 )
 
 type parser struct {
@@ -10,21 +13,49 @@ type parser struct {
 	lines    []string
 	tokens   []*Token
 	endPoint Point
+
+	recoverErrors        bool
+	validateSigils       bool
+	allowNonFiniteFloats bool
+	errs                 []error
+	blockDepth           int
+
+	includes *includeState
+
+	allTokens      []*Token
+	trailingTrivia string
 }
 
-func newParser(data []byte, source string) *parser {
-	return &parser{
+func newParser(data []byte, source string, opts ParseOptions) *parser {
+	p := parser{
 		source: source,
 		data:   data,
 		lines:  splitLines(data),
+
+		recoverErrors:        opts.RecoverErrors,
+		validateSigils:       opts.ValidateSigils,
+		allowNonFiniteFloats: opts.AllowNonFiniteFloats,
 	}
+
+	if opts.IncludeFS != nil {
+		p.includes = &includeState{
+			fsys:  opts.IncludeFS,
+			stack: []string{path.Clean(source)},
+			lines: make(map[string][]string),
+		}
+	}
+
+	return &p
 }
 
 func (p *parser) Parse() (doc *Document, err error) {
 	defer func() {
 		if v := recover(); v != nil {
 			if verr, ok := v.(error); ok {
-				err = ParseError{Err: verr, Lines: p.lines}
+				err = ParseError{
+					Err:         verr,
+					SourceLines: p.sourceLines(),
+				}
 				return
 			}
 
@@ -36,7 +67,7 @@ func (p *parser) Parse() (doc *Document, err error) {
 	tokens := []*Token{}
 
 	for {
-		token := tokenizer.readToken()
+		token := p.readTokenizerToken(tokenizer)
 		if token == nil {
 			break
 		}
@@ -45,6 +76,8 @@ func (p *parser) Parse() (doc *Document, err error) {
 	}
 
 	p.tokens = tokens
+	p.allTokens = tokens
+	p.trailingTrivia = tokenizer.trailingTrivia
 
 	if len(tokens) > 0 {
 		// Errors signaled because a syntaxic element is truncated must point at
@@ -53,34 +86,143 @@ func (p *parser) Parse() (doc *Document, err error) {
 		p.endPoint.Column++
 	}
 
-	elts := p.parseBlockContent(true)
+	cst := elementCST{Start: 0, End: len(tokens)}
+
+	elts, comments := p.parseBlockContent(true, &cst)
 
 	block := Block{
-		Elements: elts,
+		Elements:    elts,
+		EndComments: comments,
 	}
 
 	topLevel := Element{
 		Location: NewSpanAt(Point{0, 1, 1}, 0),
 		Content:  &block,
+		cst:      &cst,
 	}
 
 	doc = &Document{
 		Source:   p.source,
 		TopLevel: &topLevel,
+
+		sourceLines: p.sourceLines(),
+
+		tokens:         p.allTokens,
+		trailingTrivia: p.trailingTrivia,
+	}
+
+	doc.TopLevel.snapshotCST()
+
+	if len(p.errs) > 0 {
+		err = newParseErrors(p.errs, p.sourceLines())
 	}
 
 	return
 }
 
+func (p *parser) readTokenizerToken(t *tokenizer) *Token {
+	if !p.recoverErrors {
+		return t.readToken()
+	}
+
+	for {
+		token, err := t.readTokenRecover()
+		if err == nil {
+			return token
+		}
+
+		p.errs = append(p.errs, err)
+	}
+}
+
+// Signal an error which does not prevent the parser from building the current
+// element. In recovery mode, parsing continues as if nothing happened.
+func (p *parser) signalError(err error) {
+	if !p.recoverErrors {
+		panic(err)
+	}
+
+	p.errs = append(p.errs, err)
+}
+
+func (p *parser) parseElementRecover() (elt *Element, ok bool) {
+	if !p.recoverErrors {
+		return p.parseElement(), true
+	}
+
+	defer func() {
+		if v := recover(); v != nil {
+			if verr, isErr := v.(error); isErr {
+				p.errs = append(p.errs, verr)
+				p.resync()
+
+				elt = nil
+				ok = false
+				return
+			}
+
+			panic(v)
+		}
+	}()
+
+	return p.parseElement(), true
+}
+
+// Skip tokens until the end of the current line or the closing bracket of the
+// current block, ignoring any nested block or list.
+func (p *parser) resync() {
+	depth := 0
+	listDepth := 0
+
+	for {
+		token := p.peekToken()
+		if token == nil {
+			return
+		}
+
+		switch token.Type {
+		case TokenTypeEOL:
+			if depth == 0 && listDepth == 0 {
+				p.skipToken()
+				return
+			}
+
+		case TokenTypeOpeningSquareBracket:
+			listDepth++
+
+		case TokenTypeClosingSquareBracket:
+			if listDepth > 0 {
+				listDepth--
+			}
+
+		case TokenTypeOpeningBracket:
+			depth++
+
+		case TokenTypeClosingBracket:
+			if depth == 0 {
+				if p.blockDepth > 0 {
+					return
+				}
+			} else {
+				depth--
+			}
+		}
+
+		p.skipToken()
+	}
+}
+
 func (p *parser) tokenSyntaxError(token *Token, format string, args ...any) error {
 	return p.syntaxErrorAt(token.Span, format, args...)
 }
 
 func (p *parser) syntaxErrorAtPoint(point Point, format string, args ...any) error {
-	return p.syntaxErrorAt(Span{point, point}, format, args...)
+	return p.syntaxErrorAt(Span{Start: point, End: point}, format, args...)
 }
 
 func (p *parser) syntaxErrorAt(span Span, format string, args ...any) error {
+	span.Source = p.source
+
 	return &SyntaxError{
 		Source:      p.source,
 		Location:    span,
@@ -89,13 +231,23 @@ func (p *parser) syntaxErrorAt(span Span, format string, args ...any) error {
 }
 
 func (p *parser) duplicateErrorAt(elt, prevElt *Element) error {
+	// Elements can come from an included file
+	source := elt.Location.Source
+	if source == "" {
+		source = p.source
+	}
+
 	return &DuplicateError{
-		Source:          p.source,
+		Source:          source,
 		Element:         elt,
 		PreviousElement: prevElt,
 	}
 }
 
+func (p *parser) tokenIndex() int {
+	return len(p.allTokens) - len(p.tokens)
+}
+
 func (p *parser) peekToken() *Token {
 	if len(p.tokens) == 0 {
 		return nil
@@ -136,7 +288,7 @@ func (p *parser) skipEOL() int {
 
 func (p *parser) parseElement() *Element {
 	p.skipEOL()
-	nameToken := p.readToken()
+	nameToken := p.peekToken()
 	if nameToken == nil {
 		return nil
 	}
@@ -146,6 +298,8 @@ func (p *parser) parseElement() *Element {
 			"name or entry name", nameToken.Type))
 	}
 
+	p.skipToken()
+
 	valueToken := p.peekToken()
 	if valueToken != nil {
 		if valueToken.Type == TokenTypeString {
@@ -156,29 +310,44 @@ func (p *parser) parseElement() *Element {
 	}
 
 	token := p.peekToken()
-	if token != nil && token.Type == TokenTypeOpeningBracket {
+	if token != nil && token.Type == TokenTypeOpeningBracket &&
+		!p.startsWithInlineMap() {
 		p.skipToken()
 
-		elts := p.parseBlockContent(false)
-
-		block := Block{
-			Type:     nameToken.Value.(string),
-			Elements: elts,
-		}
+		var name string
 
 		if valueToken != nil {
 			s := valueToken.Value.(String)
 			if s.Sigil != "" {
-				panic(p.tokenSyntaxError(valueToken,
+				p.signalError(p.tokenSyntaxError(valueToken,
 					"invalid block name: block names cannot have a sigil"))
 			}
 
-			block.Name = s.String
+			name = s.String
+		}
+
+		trailingComment := p.parseTrailingComment()
+
+		var cst elementCST
+
+		p.blockDepth++
+		elts, endComments := p.parseBlockContent(false, &cst)
+		p.blockDepth--
+
+		block := Block{
+			Type:        nameToken.Value.(string),
+			Name:        name,
+			Elements:    elts,
+			EndComments: endComments,
 		}
 
+		block.ClosingComment = p.parseTrailingComment()
+
 		elt := Element{
-			Location: nameToken.Span,
-			Content:  &block,
+			Location:        nameToken.Span,
+			Content:         &block,
+			TrailingComment: trailingComment,
+			cst:             &cst,
 		}
 
 		if valueToken != nil {
@@ -192,7 +361,7 @@ func (p *parser) parseElement() *Element {
 		return &elt
 	}
 
-	values := p.parseEntryValues()
+	values, trailingComment := p.parseEntryValues()
 	if valueToken != nil {
 		values = append([]*Value{p.tokenValue(valueToken)}, values...)
 	}
@@ -203,8 +372,10 @@ func (p *parser) parseElement() *Element {
 	}
 
 	elt := Element{
-		Location: nameToken.Span,
-		Content:  &entry,
+		Location:        nameToken.Span,
+		Content:         &entry,
+		TrailingComment: trailingComment,
+		cst:             &elementCST{},
 	}
 
 	if p.skipEOL() > 0 {
@@ -214,13 +385,32 @@ func (p *parser) parseElement() *Element {
 	return &elt
 }
 
-func (p *parser) parseBlockContent(topLevel bool) []*Element {
+// Parse the content of a block and fill the header end and footer start of
+// its syntax tree information. Tokens which are skipped because of errors
+// are part of the range of the next element, or of the footer of the block.
+func (p *parser) parseBlockContent(topLevel bool, cst *elementCST) ([]*Element, []*Comment) {
 	var elts []*Element
+	var comments []*Comment
 
 	blockTable := make(map[string]*Element)
 
+	start := -1
+
 	for {
 		p.skipEOL()
+
+		if start == -1 {
+			start = p.tokenIndex()
+
+			if len(elts) == 0 && len(cst.Includes) == 0 {
+				cst.HeaderEnd = start
+			}
+		}
+
+		cst.FooterStart = start
+
+		comments = p.parseComments()
+
 		token := p.peekToken()
 
 		if topLevel {
@@ -229,7 +419,9 @@ func (p *parser) parseBlockContent(topLevel bool) []*Element {
 			}
 		} else {
 			if token == nil {
-				panic(p.syntaxErrorAtPoint(p.endPoint, "truncated block"))
+				p.signalError(p.syntaxErrorAtPoint(p.endPoint,
+					"truncated block"))
+				break
 			}
 
 			if token.Type == TokenTypeClosingBracket {
@@ -238,77 +430,468 @@ func (p *parser) parseBlockContent(topLevel bool) []*Element {
 			}
 		}
 
-		elt := p.parseElement()
+		elt, ok := p.parseElementRecover()
+		if !ok {
+			continue
+		}
+
 		if elt == nil {
-			panic(p.syntaxErrorAtPoint(p.endPoint, "truncated block"))
+			p.signalError(p.syntaxErrorAtPoint(p.endPoint, "truncated block"))
+			break
 		}
 
-		if block, ok := elt.Content.(*Block); ok {
-			if block.Name != "" {
-				id := elt.Id()
+		elt.cst.Start = start
+		elt.cst.End = p.tokenIndex()
+
+		elt.LeadingComments = comments
+		comments = nil
+
+		if p.includes != nil && isIncludeDirective(elt) {
+			includedElts := p.resolveInclude(elt)
+
+			cst.Includes = append(cst.Includes, elt)
+			for _, includedElt := range includedElts {
+				includedElt.include = elt
+			}
 
-				if prevElt := blockTable[id]; prevElt != nil {
-					panic(p.duplicateErrorAt(elt, prevElt))
+			if n := len(includedElts); n > 0 {
+				includedElts[0].LeadingComments = append(elt.LeadingComments,
+					includedElts[0].LeadingComments...)
+				includedElts[n-1].FollowedByEmptyLine = elt.FollowedByEmptyLine
+			}
+
+			for _, includedElt := range includedElts {
+				if p.checkNamedBlock(blockTable, includedElt) {
+					elts = append(elts, includedElt)
 				}
+			}
+		} else {
+			if !p.checkNamedBlock(blockTable, elt) {
+				continue
+			}
+
+			elts = append(elts, elt)
+		}
+
+		start = -1
+	}
+
+	return elts, comments
+}
 
-				blockTable[id] = elt
+// Register a named block in the table of the block containing it. Return
+// false and signal an error if a block with the same type and name is already
+// present.
+func (p *parser) checkNamedBlock(blockTable map[string]*Element, elt *Element) bool {
+	if block, ok := elt.Content.(*Block); ok {
+		if block.Name != "" {
+			id := elt.Id()
+
+			if prevElt := blockTable[id]; prevElt != nil {
+				p.signalError(p.duplicateErrorAt(elt, prevElt))
+				return false
 			}
+
+			blockTable[id] = elt
 		}
+	}
+
+	return true
+}
+
+func (p *parser) parseComments() []*Comment {
+	var comments []*Comment
 
-		elts = append(elts, elt)
+	for {
+		token := p.peekToken()
+		if token == nil || token.Type != TokenTypeComment {
+			break
+		}
+
+		p.skipToken()
+
+		comment := p.tokenComment(token)
+
+		// Comments always end with an EOL sequence (or with the end of the
+		// document), so there is an empty line after the comment if there is
+		// more than one EOL token.
+		if p.skipEOL() > 1 {
+			comment.FollowedByEmptyLine = true
+		}
+
+		comments = append(comments, comment)
 	}
 
-	return elts
+	return comments
 }
 
-func (p *parser) parseEntryValues() []*Value {
+func (p *parser) parseTrailingComment() *Comment {
+	token := p.peekToken()
+	if token == nil || token.Type != TokenTypeComment {
+		return nil
+	}
+
+	p.skipToken()
+
+	return p.tokenComment(token)
+}
+
+func (p *parser) parseEntryValues() ([]*Value, *Comment) {
 	var values []*Value
+	var comment *Comment
 
 	for {
-		token := p.readToken()
-		if token == nil || token.Type == TokenTypeEOL {
+		token := p.peekToken()
+		if token == nil {
+			break
+		}
+
+		if token.Type == TokenTypeClosingBracket {
+			// Do not consume the closing bracket so that the parser can
+			// resynchronize on the end of the current block.
+			panic(p.tokenSyntaxError(token, "invalid token %q, expected "+
+				"symbol, string, integer, float, list or map", token.Type))
+		}
+
+		p.skipToken()
+
+		if token.Type == TokenTypeEOL {
 			break
 		}
 
-		values = append(values, p.tokenValue(token))
+		if token.Type == TokenTypeComment {
+			comment = p.tokenComment(token)
+			continue
+		}
+
+		values = append(values, p.parseValue(token))
+	}
+
+	return values, comment
+}
+
+// Parse a value starting with a token which has already been consumed.
+func (p *parser) parseValue(token *Token) *Value {
+	switch token.Type {
+	case TokenTypeOpeningSquareBracket:
+		return p.parseList(token)
+	case TokenTypeOpeningBracket:
+		return p.parseMap(token)
 	}
 
-	return values
+	return p.tokenValue(token)
+}
+
+// Parse the elements of a list. Lists can span multiple lines, so EOL tokens
+// are ignored until the closing square bracket. A comment is the trailing
+// comment of the last element of its line if there is one; otherwise it is a
+// leading comment of the next element, or an end comment of the list if there
+// is no next element.
+func (p *parser) parseList(openingToken *Token) *Value {
+	list := List{}
+	layout := valueLayoutInline
+
+	var comments []*Comment
+	var lineValue *Value // last element of the current line
+
+	for {
+		token := p.readToken()
+		if token == nil {
+			panic(p.syntaxErrorAtPoint(p.endPoint, "truncated list"))
+		}
+
+		switch token.Type {
+		case TokenTypeEOL:
+			layout = valueLayoutMultiLine
+			lineValue = nil
+			continue
+
+		case TokenTypeComment:
+			comment := p.tokenComment(token)
+
+			if lineValue != nil {
+				lineValue.TrailingComment = comment
+				continue
+			}
+
+			layout = valueLayoutMultiLine
+			if p.skipEOL() > 1 {
+				comment.FollowedByEmptyLine = true
+			}
+
+			comments = append(comments, comment)
+			continue
+
+		case TokenTypeClosingSquareBracket:
+			return &Value{
+				Location:    openingToken.Span.Union(token.Span),
+				Content:     list,
+				EndComments: comments,
+				layout:      layout,
+			}
+		}
+
+		value := p.parseValue(token)
+		value.LeadingComments = comments
+		comments = nil
+
+		list = append(list, value)
+		lineValue = value
+	}
+}
+
+// Return whether the next tokens are the start of an inline map and not the
+// start of a block, i.e. an opening bracket followed by a key and an equal
+// sign, possibly on a following line, or the empty map `{,}`.
+func (p *parser) startsWithInlineMap() bool {
+	skipTrivia := func(i int) int {
+		for i < len(p.tokens) && (p.tokens[i].Type == TokenTypeEOL ||
+			p.tokens[i].Type == TokenTypeComment) {
+			i++
+		}
+
+		return i
+	}
+
+	i := skipTrivia(1)
+	if i+1 >= len(p.tokens) {
+		return false
+	}
+
+	if p.tokens[i].Type == TokenTypeComma {
+		i = skipTrivia(i + 1)
+		return i < len(p.tokens) && p.tokens[i].Type == TokenTypeClosingBracket
+	}
+
+	keyType := p.tokens[i].Type
+
+	return (keyType == TokenTypeSymbol || keyType == TokenTypeString) &&
+		p.tokens[i+1].Type == TokenTypeEqual
+}
+
+// Parse the entries of an inline map. Maps can span multiple lines; entries
+// are separated by commas or EOL tokens. Comments are attached to entries as
+// in lists. A single comma, possibly surrounded by comments, denotes an empty
+// map.
+func (p *parser) parseMap(openingToken *Token) *Value {
+	m := Map{}
+	layout := valueLayoutInline
+	empty := false
+
+	var comments []*Comment
+	var lineEntry *MapEntry // last entry of the current line
+
+	readToken := func() *Token {
+		token := p.readToken()
+		if token == nil {
+			panic(p.syntaxErrorAtPoint(p.endPoint, "truncated map"))
+		}
+
+		return token
+	}
+
+	for {
+		token := readToken()
+
+		var key string
+
+		switch token.Type {
+		case TokenTypeEOL:
+			layout = valueLayoutMultiLine
+			lineEntry = nil
+			continue
+
+		case TokenTypeComment:
+			comment := p.tokenComment(token)
+
+			if lineEntry != nil {
+				lineEntry.TrailingComment = comment
+				continue
+			}
+
+			layout = valueLayoutMultiLine
+			if p.skipEOL() > 1 {
+				comment.FollowedByEmptyLine = true
+			}
+
+			comments = append(comments, comment)
+			continue
+
+		case TokenTypeComma:
+			if len(m) > 0 || empty {
+				panic(p.tokenSyntaxError(token, "invalid token %q, expected "+
+					"map key", token.Type))
+			}
+
+			empty = true
+			continue
+
+		case TokenTypeClosingBracket:
+			return &Value{
+				Location:    openingToken.Span.Union(token.Span),
+				Content:     m,
+				EndComments: comments,
+				layout:      layout,
+			}
+
+		case TokenTypeSymbol:
+			key = token.Value.(string)
+
+		case TokenTypeString:
+			s := token.Value.(String)
+			if s.Sigil != "" {
+				p.signalError(p.tokenSyntaxError(token,
+					"invalid map key: map keys cannot have a sigil"))
+			}
+
+			key = s.String
+
+		default:
+			panic(p.tokenSyntaxError(token, "invalid token %q, expected map "+
+				"key", token.Type))
+		}
+
+		if empty {
+			panic(p.tokenSyntaxError(token, "invalid token %q, expected end "+
+				"of empty map", token.Type))
+		}
+
+		keyToken := token
+
+		if token := readToken(); token.Type != TokenTypeEqual {
+			panic(p.tokenSyntaxError(token, "invalid token %q, expected '=' "+
+				"after map key", token.Type))
+		}
+
+		token = readToken()
+		switch token.Type {
+		case TokenTypeEOL, TokenTypeComment, TokenTypeComma,
+			TokenTypeClosingBracket:
+			panic(p.tokenSyntaxError(token, "missing value for map key %q",
+				key))
+		}
+
+		entry := MapEntry{
+			Key:             key,
+			KeyLocation:     keyToken.Span,
+			Value:           p.parseValue(token),
+			LeadingComments: comments,
+		}
+
+		comments = nil
+
+		if m.Get(key) != nil {
+			p.signalError(p.tokenSyntaxError(keyToken, "duplicate map key %q",
+				key))
+		} else {
+			m = append(m, &entry)
+		}
+
+		lineEntry = &entry
+
+		if token := p.peekToken(); token != nil {
+			switch token.Type {
+			case TokenTypeComma:
+				p.skipToken()
+			case TokenTypeEOL, TokenTypeComment, TokenTypeClosingBracket:
+			default:
+				panic(p.tokenSyntaxError(token, "invalid token %q, expected "+
+					"',' or end of map", token.Type))
+			}
+		}
+	}
+}
+
+func (p *parser) tokenComment(t *Token) *Comment {
+	return &Comment{
+		Location: t.Span,
+		Text:     t.Value.(string),
+	}
 }
 
 func (p *parser) tokenValue(t *Token) *Value {
 	var v any
+	var radix int
+	var style stringStyle
 
 	switch t.Type {
 	case TokenTypeSymbol:
 		s := t.Value.(string)
-		switch s {
-		case "true":
+		switch {
+		case s == "true":
 			v = true
-		case "false":
+		case s == "false":
 			v = false
-		case "null":
+		case s == "null":
 			v = nil
+		case s == "inf" && p.allowNonFiniteFloats:
+			v = math.Inf(1)
+		case s == "nan" && p.allowNonFiniteFloats:
+			v = math.NaN()
 		default:
 			v = Symbol(t.Value.(string))
 		}
 
 	case TokenTypeString:
-		v = t.Value.(String)
+		s := t.Value.(String)
+
+		if p.validateSigils && s.Sigil != "" {
+			if _, _, err := decodeSigilString(s); err != nil {
+				p.signalError(p.tokenSyntaxError(t, "%v", err))
+			}
+		}
+
+		v = s
+		style = stringLiteralStyle(t.Data)
 
 	case TokenTypeInteger:
-		v = t.Value.(int64)
+		v = t.Value
+		radix = integerLiteralRadix(t.Data)
 
 	case TokenTypeFloat:
-		v = t.Value.(float64)
+		f := t.Value.(float64)
+
+		if math.IsInf(f, 0) && !p.allowNonFiniteFloats {
+			p.signalError(p.tokenSyntaxError(t,
+				"invalid float: non-finite floats are not allowed"))
+		}
+
+		v = f
 
 	default:
 		panic(p.tokenSyntaxError(t, "invalid token %q, expected symbol, "+
-			"string, integer or float", t.Type))
+			"string, integer, float, list or map", t.Type))
 	}
 
 	return &Value{
-		Location: t.Span,
-		Content:  v,
+		Location:    t.Span,
+		Content:     v,
+		radix:       radix,
+		stringStyle: style,
 	}
 }
+
+func stringLiteralStyle(s string) stringStyle {
+	s = s[strings.IndexAny(s, "\"`"):]
+
+	switch {
+	case strings.HasPrefix(s, `"""`):
+		return stringStyleMultiLine
+	case strings.HasPrefix(s, "`"):
+		return stringStyleRaw
+	default:
+		return stringStyleQuoted
+	}
+}
+
+func integerLiteralRadix(s string) int {
+	s = strings.TrimLeft(s, "+-")
+
+	if len(s) > 1 && s[0] == '0' {
+		if radix := integerRadixes[s[1]]; radix != 0 {
+			return radix
+		}
+	}
+
+	return 10
+}
@@ -0,0 +1,214 @@
+package bcl
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"slices"
+	"testing"
+)
+
+func TestParseInvalid(t *testing.T) {
+	tests := []string{
+		`"a" 1`,
+		`a 1 }`,
+		`a {`,
+		`a { b 1`,
+		`a ~x"b" {}`,
+		`a "foo`,
+		`a 1x`,
+		"a 1 \\ b",
+		`a = 1`,
+		`a {,,}`,
+		`a {, x = 1}`,
+		`a {x = 1,,}`,
+		`a [{x = 1 y = 2}]`,
+		`b "x" {}
+b "x" {}`,
+	}
+
+	for _, s := range tests {
+		if _, err := Parse([]byte(s), "test"); err == nil {
+			t.Errorf("parsing %q should have failed", s)
+		}
+	}
+}
+
+func TestParseRecoverErrors(t *testing.T) {
+	tests := []struct {
+		s     string
+		lines []int // lines of the errors
+		ids   []string
+	}{
+		{
+			"a 1\nb 2\n",
+			nil,
+			[]string{"a", "b"},
+		},
+		{
+			"a 1x\nb 2\nc \"foo\nd 4\n",
+			[]int{1, 3},
+			[]string{"a", "b", "c", "d"},
+		},
+		{
+			"x {\n  = 1\n  b 1\n}\ny 2\n",
+			[]int{2},
+			[]string{"x", "y"},
+		},
+		{
+			"x \"n\" {}\nx \"n\" {}\ny 1\n",
+			[]int{2},
+			[]string{"x.n", "y"},
+		},
+		{
+			"x {\n  a 1\n",
+			[]int{2},
+			[]string{"x"},
+		},
+		{
+			"a ~x\"b\" {\n  c 1\n}\nd 2 3 = 4\ne 5\n",
+			[]int{1, 4},
+			[]string{"a.b", "e"},
+		},
+	}
+
+	for _, test := range tests {
+		doc, err := ParseWithOptions([]byte(test.s), "test",
+			ParseOptions{RecoverErrors: true})
+
+		var lines []int
+
+		if err != nil {
+			var errs ParseErrors
+			if !errors.As(err, &errs) {
+				t.Errorf("%q: error %v is not a ParseErrors value", test.s,
+					err)
+				continue
+			}
+
+			for _, err := range errs.Errs {
+				if span := ParseErrorLocation(err); span != nil {
+					lines = append(lines, span.Start.Line)
+				} else {
+					lines = append(lines, 0)
+				}
+			}
+		}
+
+		if !slices.Equal(lines, test.lines) {
+			t.Errorf("%q: errors %v were found on lines %v but should have "+
+				"been found on lines %v", test.s, err, lines, test.lines)
+		}
+
+		if doc == nil {
+			t.Errorf("%q: no document returned", test.s)
+			continue
+		}
+
+		var ids []string
+		for _, elt := range doc.TopLevel.Content.(*Block).Elements {
+			ids = append(ids, elt.Id())
+		}
+
+		if !slices.Equal(ids, test.ids) {
+			t.Errorf("%q: document contains elements %v but should contain "+
+				"elements %v", test.s, ids, test.ids)
+		}
+	}
+}
+
+func TestParseNumbers(t *testing.T) {
+	bigInt := func(s string) *big.Int {
+		i, _ := new(big.Int).SetString(s, 0)
+		return i
+	}
+
+	tests := []struct {
+		s               string
+		allowNonFinite  bool
+		expectedContent any
+	}{
+		{"0", false, int64(0)},
+		{"-0", false, int64(0)},
+		{"0755", false, int64(755)},
+		{"0xff", false, int64(255)},
+		{"0x7fffffffffffffff", false, int64(math.MaxInt64)},
+		{"-0x10", false, int64(-16)},
+		{"0o755", false, int64(0o755)},
+		{"0b1010", false, int64(10)},
+		{"+0b1", false, int64(1)},
+		{"1_000_000", false, int64(1_000_000)},
+		{"0xff_ff", false, int64(0xffff)},
+		{"-9223372036854775808", false, int64(math.MinInt64)},
+		{"9223372036854775808", false, bigInt("9223372036854775808")},
+		{"-99999999999999999999", false, bigInt("-99999999999999999999")},
+		{"0xffffffffffffffffff", false, bigInt("0xffffffffffffffffff")},
+		{"1.5", false, 1.5},
+		{"1_000.5", false, 1000.5},
+		{"1e3", false, 1000.0},
+		{"-2.5E-3", false, -0.0025},
+		{"inf", false, Symbol("inf")},
+		{"nan", false, Symbol("nan")},
+		{"inf", true, math.Inf(1)},
+		{"+inf", true, math.Inf(1)},
+		{"-inf", true, math.Inf(-1)},
+		{"nan", true, math.NaN()},
+	}
+
+	for _, test := range tests {
+		opts := ParseOptions{AllowNonFiniteFloats: test.allowNonFinite}
+
+		doc, err := ParseWithOptions([]byte("a "+test.s), "test", opts)
+		if err != nil {
+			t.Errorf("cannot parse %q: %v", test.s, err)
+			continue
+		}
+
+		value := doc.TopLevel.FindEntry("a").Content.(*Entry).Values[0]
+
+		var equal bool
+		if f, ok := test.expectedContent.(float64); ok && math.IsNaN(f) {
+			f2, ok := value.Content.(float64)
+			equal = ok && math.IsNaN(f2)
+		} else {
+			equal = value.Equal(&Value{Content: test.expectedContent})
+		}
+
+		if !equal {
+			t.Errorf("%q was parsed as %#v instead of %#v", test.s,
+				value.Content, test.expectedContent)
+		}
+	}
+}
+
+func TestParseNumbersInvalid(t *testing.T) {
+	tests := []struct {
+		s              string
+		allowNonFinite bool
+	}{
+		{"0XFF", false},
+		{"0x", false},
+		{"0x_ff", false},
+		{"0o9", false},
+		{"0b2", false},
+		{"1__0", false},
+		{"1_", false},
+		{"1.5_", false},
+		{"1x", false},
+		{"1.", false},
+		{"1e", false},
+		{"1e400", false},
+		{"+inf", false},
+		{"-inf", false},
+		{"1e400", true},
+	}
+
+	for _, test := range tests {
+		opts := ParseOptions{AllowNonFiniteFloats: test.allowNonFinite}
+
+		if _, err := ParseWithOptions([]byte("a "+test.s), "test",
+			opts); err == nil {
+			t.Errorf("parsing %q should have failed", test.s)
+		}
+	}
+}
@@ -3,23 +3,60 @@ package bcl
 import (
 	"fmt"
 	"io"
+	"math"
+	"math/big"
 	"strconv"
+	"strings"
 )
 
 type printer struct {
 	w     io.Writer
 	doc   *Document
 	level int
+
+	indent      string
+	atLineStart bool
+
+	// Print maps on a single line whatever their length
+	inline bool
 }
 
+// Maps are printed on a single line when it does not make the map longer than
+// this number of characters.
+const maxInlineMapLength = 60
+
 func newPrinter(w io.Writer, doc *Document) *printer {
 	return &printer{
 		w:   w,
 		doc: doc,
+
+		indent:      "  ",
+		atLineStart: true,
 	}
 }
 
-func (p *printer) Print() (err error) {
+func (p *printer) Print() error {
+	return p.run(p.printDocument)
+}
+
+func (p *printer) PrintPreserving() error {
+	return p.run(p.printDocumentPreserving)
+}
+
+func (p *printer) PrintElement(elt *Element) error {
+	return p.run(func() {
+		p.printComments(elt.LeadingComments)
+		p.printElementContent(elt)
+	})
+}
+
+func (p *printer) PrintValue(value *Value) error {
+	return p.run(func() {
+		p.printValue(value)
+	})
+}
+
+func (p *printer) run(fn func()) (err error) {
 	defer func() {
 		if v := recover(); v != nil {
 			if verr, ok := v.(error); ok {
@@ -31,7 +68,7 @@ func (p *printer) Print() (err error) {
 		}
 	}()
 
-	p.printDocument()
+	fn()
 	return
 }
 
@@ -41,44 +78,64 @@ func (p *printer) printDocument() {
 	for _, child := range block.Elements {
 		p.printElement(child)
 	}
+
+	p.printComments(block.EndComments)
 }
 
 func (p *printer) printElement(elt *Element) {
+	p.printComments(elt.LeadingComments)
+	p.printElementContent(elt)
+
+	if elt.FollowedByEmptyLine {
+		p.print("\n")
+	}
+}
+
+func (p *printer) printElementContent(elt *Element) {
 	switch v := elt.Content.(type) {
 	case *Block:
-		p.printBlock(v)
+		p.printBlock(v, elt.TrailingComment)
 	case *Entry:
-		p.printEntry(v)
+		p.printEntry(v, elt.TrailingComment)
 	}
+}
 
-	if elt.FollowedByEmptyLine {
-		p.print("\n")
+func (p *printer) printBlock(block *Block, trailingComment *Comment) {
+	p.printBlockStart(block, trailingComment)
+
+	p.level++
+	for _, elt := range block.Elements {
+		p.printElement(elt)
 	}
+	p.printComments(block.EndComments)
+	p.level--
+
+	p.printBlockEnd(block)
 }
 
-func (p *printer) printBlock(block *Block) {
+func (p *printer) printBlockStart(block *Block, trailingComment *Comment) {
 	p.printIndent()
 
 	p.print(block.Type)
 
 	if block.Name != "" {
 		p.print(" ")
-		p.printStringValue(String{String: block.Name})
+		p.printStringValue(String{String: block.Name}, stringStyleQuoted)
 	}
 
-	p.print(" {\n")
-
-	p.level++
-	for _, elt := range block.Elements {
-		p.printElement(elt)
-	}
-	p.level--
+	p.print(" {")
+	p.printTrailingComment(trailingComment)
+	p.print("\n")
+}
 
+func (p *printer) printBlockEnd(block *Block) {
 	p.printIndent()
-	p.print("}\n")
+	p.print("}")
+	p.printTrailingComment(block.ClosingComment)
+	p.print("\n")
 }
 
-func (p *printer) printEntry(entry *Entry) {
+func (p *printer) printEntry(entry *Entry, trailingComment *Comment) {
 	p.printIndent()
 
 	p.print(entry.Name)
@@ -88,11 +145,41 @@ func (p *printer) printEntry(entry *Entry) {
 		p.printValue(value)
 	}
 
+	p.printTrailingComment(trailingComment)
 	p.print("\n")
 }
 
+func (p *printer) printComments(comments []*Comment) {
+	for _, comment := range comments {
+		p.printIndent()
+		p.printComment(comment)
+		p.print("\n")
+
+		if comment.FollowedByEmptyLine {
+			p.print("\n")
+		}
+	}
+}
+
+func (p *printer) printTrailingComment(comment *Comment) {
+	if comment == nil {
+		return
+	}
+
+	p.print(" ")
+	p.printComment(comment)
+}
+
+func (p *printer) printComment(comment *Comment) {
+	p.print("#")
+	p.print(comment.Text)
+}
+
 func (p *printer) printValue(value *Value) {
-	switch v := value.Content.(type) {
+	switch v := value.RawContent().(type) {
+	case nil:
+		p.print("null")
+
 	case Symbol:
 		p.print(string(v))
 
@@ -100,49 +187,290 @@ func (p *printer) printValue(value *Value) {
 		p.print(strconv.FormatBool(v))
 
 	case String:
-		p.printStringValue(v)
+		p.printStringValue(v, value.stringStyle)
 
 	case int64:
-		p.print(strconv.FormatInt(v, 10))
+		var i big.Int
+		p.print(formatInteger(i.SetInt64(v), value.radix))
+
+	case *big.Int:
+		p.print(formatInteger(v, value.radix))
 
 	case float64:
-		p.print(strconv.FormatFloat(v, 'f', -1, 64))
+		switch {
+		case math.IsInf(v, 1):
+			p.print("+inf")
+			return
+		case math.IsInf(v, -1):
+			p.print("-inf")
+			return
+		case math.IsNaN(v):
+			p.print("nan")
+			return
+		}
+
+		// Floats must always contain a fractional part, otherwise they would
+		// be read as integers.
+		s := strconv.FormatFloat(v, 'f', -1, 64)
+		if !strings.Contains(s, ".") {
+			s += ".0"
+		}
+
+		p.print(s)
+
+	case List:
+		p.printList(value, v)
+
+	case Map:
+		p.printMap(value, v)
 
 	default:
 		panic(fmt.Sprintf("unhandled value %#v (%T)", value, value))
 	}
 }
 
-func (p *printer) printStringValue(s String) {
+func (p *printer) printList(value *Value, list List) {
+	if value.layout != valueLayoutMultiLine && !value.containsComments() {
+		p.print("[")
+
+		for i, child := range list {
+			if i > 0 {
+				p.print(" ")
+			}
+
+			p.printValue(child)
+		}
+
+		p.print("]")
+		return
+	}
+
+	p.print("[\n")
+
+	p.level++
+	for _, child := range list {
+		p.printComments(child.LeadingComments)
+		p.printIndent()
+		p.printValue(child)
+		p.printTrailingComment(child.TrailingComment)
+		p.print("\n")
+	}
+	p.printComments(value.EndComments)
+	p.level--
+
+	p.printIndent()
+	p.print("]")
+}
+
+func (p *printer) printMap(value *Value, m Map) {
+	// Empty maps contain a comma so that they are not read as empty blocks
+	if len(m) == 0 && len(value.EndComments) == 0 {
+		p.print("{,}")
+		return
+	}
+
+	if !value.containsComments() {
+		if value.layout == valueLayoutInline ||
+			(p.inline && value.layout == valueLayoutAuto) {
+			p.printInlineMap(m)
+			return
+		}
+
+		if value.layout == valueLayoutAuto {
+			var buf strings.Builder
+
+			p2 := newPrinter(&buf, p.doc)
+			p2.inline = true
+			p2.printInlineMap(m)
+
+			if buf.Len() <= maxInlineMapLength &&
+				!strings.Contains(buf.String(), "\n") {
+				p.print(buf.String())
+				return
+			}
+		}
+	}
+
+	if len(m) == 0 {
+		p.print("{,\n")
+	} else {
+		p.print("{\n")
+	}
+
+	p.level++
+	for _, entry := range m {
+		p.printComments(entry.LeadingComments)
+		p.printIndent()
+		p.printMapKey(entry.Key)
+		p.print(" = ")
+		p.printValue(entry.Value)
+		p.printTrailingComment(entry.TrailingComment)
+		p.print("\n")
+	}
+	p.printComments(value.EndComments)
+	p.level--
+
+	p.printIndent()
+	p.print("}")
+}
+
+func (p *printer) printInlineMap(m Map) {
+	p.print("{")
+
+	for i, entry := range m {
+		if i > 0 {
+			p.print(", ")
+		}
+
+		p.printMapKey(entry.Key)
+		p.print(" = ")
+		p.printValue(entry.Value)
+	}
+
+	p.print("}")
+}
+
+func (p *printer) printMapKey(key string) {
+	if isValidSymbol(key) {
+		p.print(key)
+	} else {
+		p.printStringValue(String{String: key}, stringStyleQuoted)
+	}
+}
+
+// Print a string in a specific style. Raw strings are printed as quoted
+// strings if their content cannot be represented without escape sequences.
+func (p *printer) printStringValue(s String, style stringStyle) {
 	if s.Sigil != "" {
 		p.print("~")
 		p.print(s.Sigil)
 	}
 
-	p.print("\"")
+	switch {
+	case style == stringStyleRaw && isValidRawString(s.String):
+		p.print("`")
+		p.print(s.String)
+		p.print("`")
+
+	case style == stringStyleMultiLine:
+		p.printMultiLineString(s.String)
+
+	default:
+		p.print("\"")
+
+		for _, c := range s.String {
+			if esc, found := stringEscapeSequences[c]; found {
+				p.print(esc)
+			} else {
+				p.print(string(c))
+			}
+		}
+
+		p.print("\"")
+	}
+}
+
+// Print the content of a multi-line string indented one level deeper than
+// the current element.
+func (p *printer) printMultiLineString(s string) {
+	p.print("\"\"\"\n")
+
+	p.level++
+
+	for _, line := range strings.Split(s, "\n") {
+		if line != "" {
+			p.printIndent()
+
+			// A line starting with a triple quote would be read as the
+			// closing delimiter.
+			content := strings.TrimLeft(line, " \t")
+			quoteIdx := -1
+			if strings.HasPrefix(content, `"""`) {
+				quoteIdx = len(line) - len(content)
+			}
+
+			for i, c := range line {
+				esc, found := stringEscapeSequences[c]
+
+				switch {
+				case c == '\t', c == '"' && i != quoteIdx, !found:
+					p.print(string(c))
+				default:
+					p.print(esc)
+				}
+			}
+		}
+
+		p.print("\n")
+	}
+
+	p.printIndent()
+	p.print(`"""`)
 
-	for _, c := range s.String {
-		switch c {
-		case '\a', '\b', '\t', '\n', '\v', '\f', '\r', '"', '\\':
-			p.print("\\")
-			p.print(string(c))
+	p.level--
+}
 
-		default:
-			p.print(string(c))
+var stringEscapeSequences = map[rune]string{
+	'\a': `\a`,
+	'\b': `\b`,
+	'\t': `\t`,
+	'\n': `\n`,
+	'\v': `\v`,
+	'\f': `\f`,
+	'\r': `\r`,
+	'"':  `\"`,
+	'\\': `\\`,
+}
+
+func isValidRawString(s string) bool {
+	for _, c := range s {
+		if c == '`' || c == '\r' || (c < 0x20 && c != '\t' && c != '\n') {
+			return false
 		}
 	}
 
-	p.print("\"")
+	return true
 }
 
 func (p *printer) print(s string) {
+	if s == "" {
+		return
+	}
+
 	if _, err := p.w.Write([]byte(s)); err != nil {
 		panic(err)
 	}
+
+	p.atLineStart = s[len(s)-1] == '\n'
 }
 
 func (p *printer) printIndent() {
 	for range p.level {
-		p.print("  ")
+		p.print(p.indent)
+	}
+}
+
+// Format an integer in the radix of the literal it was read from.
+func formatInteger(i *big.Int, radix int) string {
+	var prefix string
+
+	switch radix {
+	case 16:
+		prefix = "0x"
+	case 8:
+		prefix = "0o"
+	case 2:
+		prefix = "0b"
+	default:
+		return i.Text(10)
 	}
+
+	var abs big.Int
+	abs.Abs(i)
+
+	if i.Sign() < 0 {
+		prefix = "-" + prefix
+	}
+
+	return prefix + abs.Text(radix)
 }
@@ -0,0 +1,336 @@
+package bcl
+
+import (
+	"bytes"
+	"testing"
+)
+
+func testPrint(t *testing.T, tests []struct{ s, expected string }) {
+	t.Helper()
+
+	for _, test := range tests {
+		doc, err := Parse([]byte(test.s), "test")
+		if err != nil {
+			t.Errorf("cannot parse %q: %v", test.s, err)
+			continue
+		}
+
+		var buf bytes.Buffer
+		if err := doc.Print(&buf); err != nil {
+			t.Errorf("cannot print %q: %v", test.s, err)
+			continue
+		}
+
+		expected := test.expected
+		if expected == "" {
+			expected = test.s
+		}
+
+		if s := buf.String(); s != expected {
+			t.Errorf("%q was printed as:\n%s\nbut should have been printed "+
+				"as:\n%s", test.s, s, expected)
+		}
+	}
+}
+
+func TestPrintComments(t *testing.T) {
+	testPrint(t, []struct{ s, expected string }{
+		{"# foo\n", ""},
+		{"a 1 # foo\n", ""},
+		{"a 1   #foo  \n", "a 1 #foo\n"},
+		{"# a\n\n# b\na 1\n", ""},
+		{"a 1\n# end\n", ""},
+		{
+			"b { # opening\n  # leading\n  a 1 # trailing\n  # end\n} # closing\n",
+			"",
+		},
+		{
+			"b {\n# leading\n    a 1\n\n    # end\n}\n",
+			"b {\n  # leading\n  a 1\n\n  # end\n}\n",
+		},
+		{"a 1 # été\n", ""},
+	})
+}
+
+func TestPrintLists(t *testing.T) {
+	testPrint(t, []struct{ s, expected string }{
+		{"a []\n", ""},
+		{"a [1 2 3]\n", ""},
+		{"a [ 1\t2 ]\n", "a [1 2]\n"},
+		{"a [\n  1\n  2\n]\n", ""},
+		{"a [1\n   2]\n", "a [\n  1\n  2\n]\n"},
+		{"a [[1 2] [\n  3\n]]\n", ""},
+		{"a [\n  # leading\n  1 # trailing\n  2\n  # end\n]\n", ""},
+		{"a [1 # trailing\n 2]\n", "a [\n  1 # trailing\n  2\n]\n"},
+		{"a [\n  # a\n\n  # b\n  1\n]\n", ""},
+		{"a [ # opening\n  1\n]\n", "a [\n  # opening\n  1\n]\n"},
+		{
+			"x {\n  a [\n    1 # one\n    [2 # two\n    ]\n  ]\n}\n",
+			"x {\n  a [\n    1 # one\n    [\n      2 # two\n    ]\n  ]\n}\n",
+		},
+	})
+}
+
+func TestPrintMaps(t *testing.T) {
+	testPrint(t, []struct{ s, expected string }{
+		{"a {,}\n", ""},
+		{"a { , }\n", "a {,}\n"},
+		{"a 1 {}\n", "a 1 {,}\n"},
+		{"a [{}]\n", "a [{,}]\n"},
+		{"a \"b\" {,}\n", ""},
+		{"a {x = 1, y = 2}\n", ""},
+		{"a {x =   1,y = 2 ,}\n", "a {x = 1, y = 2}\n"},
+		{
+			"a {x = \"aaaaaaaaaaaaaaaaaaaa\", y = \"bbbbbbbbbbbbbbbbbbbb\", " +
+				"z = \"cccccccccccccccccccc\"}\n",
+			"",
+		},
+		{"a {\n  x = 1\n}\n", ""},
+		{"a {x = 1,\n  y = 2}\n", "a {\n  x = 1\n  y = 2\n}\n"},
+		{
+			"a {\n  # leading\n  x = 1 # trailing\n  y = 2, # two\n  # end\n}\n",
+			"a {\n  # leading\n  x = 1 # trailing\n  y = 2 # two\n  # end\n}\n",
+		},
+		{"a {\n  # a\n\n  # b\n  x = 1\n}\n", ""},
+		{"a {, # empty\n}\n", "a {,\n  # empty\n}\n"},
+		{"a [{,\n  # empty\n}]\n", "a [\n  {,\n    # empty\n  }\n]\n"},
+		{
+			"x {\n  a {y = [1 # one\n  ]}\n}\n",
+			"x {\n  a {\n    y = [\n      1 # one\n    ]\n  }\n}\n",
+		},
+	})
+}
+
+func TestPrintNull(t *testing.T) {
+	testPrint(t, []struct{ s, expected string }{
+		{"a null\n", ""},
+		{"a 1 null [null] {x = null}\n", ""},
+		{"a  null  # none\n", "a null # none\n"},
+	})
+}
+
+func TestPrintNumbers(t *testing.T) {
+	testPrint(t, []struct{ s, expected string }{
+		{"a 0 -1 42\n", ""},
+		{"a 0xff -0x10 0o755 0b1010\n", ""},
+		{"a +0b1 0755 -0\n", "a 0b1 755 0\n"},
+		{"a 1_000 0xff_ff\n", "a 1000 0xffff\n"},
+		{"a 99999999999999999999 0xffffffffffffffffff\n", ""},
+		{"a 1.5 -0.25 1.0\n", ""},
+		{"a 1e3 2.5e-1\n", "a 1000.0 0.25\n"},
+	})
+
+	// Non-finite floats
+	s := "a inf -inf nan\n"
+
+	opts := ParseOptions{AllowNonFiniteFloats: true}
+	doc, err := ParseWithOptions([]byte(s), "test", opts)
+	if err != nil {
+		t.Fatalf("cannot parse %q: %v", s, err)
+	}
+
+	var buf bytes.Buffer
+	if err := doc.Print(&buf); err != nil {
+		t.Fatalf("cannot print %q: %v", s, err)
+	}
+
+	if s2, expected := buf.String(), "a +inf -inf nan\n"; s2 != expected {
+		t.Errorf("%q was printed as %q instead of %q", s, s2, expected)
+	}
+
+	if _, err := ParseWithOptions(buf.Bytes(), "test", opts); err != nil {
+		t.Errorf("cannot parse printed document %q: %v", buf.String(), err)
+	}
+}
+
+func TestPrintStrings(t *testing.T) {
+	testPrint(t, []struct{ s, expected string }{
+		{"a \"x\\ty\\n\\\"z\\\"\"\n", ""},
+		{"a `x\\ty` ~re`\\d+`\n", ""},
+		{"a `x\ny`\n", ""},
+		{"a `x\r\ny`\n", "a `x\ny`\n"},
+		{
+			"a \"\"\"\n  SELECT *\n    FROM t\n  \"\"\"\n",
+			"",
+		},
+		{
+			"x {\n  a \"\"\"\n      b\n\n      \"c\"\n      \"\"\"\n}\n",
+			"x {\n  a \"\"\"\n    b\n\n    \"c\"\n    \"\"\"\n}\n",
+		},
+		{
+			"a ~x\"\"\"\n  \\\"\"\" \\t\n  \"\"\"\n",
+			"a ~x\"\"\"\n  \\\"\"\" \t\n  \"\"\"\n",
+		},
+		{
+			"a [\"\"\"\n  b\n  \"\"\"]\n",
+			"",
+		},
+	})
+}
+
+func TestPrintPreservingComments(t *testing.T) {
+	tests := []string{
+		"# foo\n\n\na   1 # bar\n",
+		"b {  # opening\n\t# leading\n\ta 1\n}   # closing\n# end",
+	}
+
+	for _, s := range tests {
+		doc, err := Parse([]byte(s), "test")
+		if err != nil {
+			t.Errorf("cannot parse %q: %v", s, err)
+			continue
+		}
+
+		var buf bytes.Buffer
+		if err := doc.PrintPreserving(&buf); err != nil {
+			t.Errorf("cannot print %q: %v", s, err)
+			continue
+		}
+
+		if s2 := buf.String(); s2 != s {
+			t.Errorf("%q was printed as %q", s, s2)
+		}
+	}
+}
+
+func TestPrintPreservingUnchanged(t *testing.T) {
+	tests := []string{
+		"",
+		"a 1",
+		"\n\n  a    1   2\n\n\n",
+		"a [1\n   2]  {x =    1}\n",
+		"a \"x\\ty\" 0x1f 0b101 1e3 ~re\"a+\"\n",
+		"a 1 \\\n  2\n",
+		"x \"n\" {\n    b {\n        c true\n    }\n}\n",
+		"x  {\na 1\n  }\ny {}\n",
+		"a 1\r\nb 2\r\n",
+		"a [ # c\n  1   # d\n\n# e\n  2 ]\n",
+		"a { # c\n  x =  1   # d\n# e\n}\n",
+		"a  {,}\n",
+	}
+
+	for _, s := range tests {
+		doc, err := Parse([]byte(s), "test")
+		if err != nil {
+			t.Errorf("cannot parse %q: %v", s, err)
+			continue
+		}
+
+		var buf bytes.Buffer
+		if err := doc.PrintPreserving(&buf); err != nil {
+			t.Errorf("cannot print %q: %v", s, err)
+			continue
+		}
+
+		if s2 := buf.String(); s2 != s {
+			t.Errorf("%q was printed as %q", s, s2)
+		}
+	}
+}
+
+func TestPrintPreservingModified(t *testing.T) {
+	tests := []struct {
+		s        string
+		modify   func(*Document)
+		expected string
+	}{
+		{
+			"a   1 # foo\nb   2\n",
+			func(doc *Document) {
+				entry := doc.TopLevel.FindEntry("b").Content.(*Entry)
+				entry.Values[0] = &Value{Content: int64(3)}
+			},
+			"a   1 # foo\nb 3\n",
+		},
+		{
+			"a   1\nb   [1 2]\n",
+			func(doc *Document) {
+				entry := doc.TopLevel.FindEntry("b").Content.(*Entry)
+				list := entry.Values[0].Content.(List)
+				list[0].Content = int64(3)
+			},
+			"a   1\nb [3 2]\n",
+		},
+		{
+			"a   [\n  1 # one\n  2\n]\n",
+			func(doc *Document) {
+				entry := doc.TopLevel.FindEntry("a").Content.(*Entry)
+				list := entry.Values[0].Content.(List)
+				list[0].TrailingComment.Text = " uno"
+			},
+			"a [\n  1 # uno\n  2\n]\n",
+		},
+		{
+			"a   [\n  1\n  # end\n]\n",
+			func(doc *Document) {
+				entry := doc.TopLevel.FindEntry("a").Content.(*Entry)
+				entry.Values[0].EndComments = nil
+			},
+			"a [\n  1\n]\n",
+		},
+		{
+			"a   {\n  # x\n  x = 1\n}\n",
+			func(doc *Document) {
+				entry := doc.TopLevel.FindEntry("a").Content.(*Entry)
+				m := entry.Values[0].Content.(Map)
+				m[0].LeadingComments = nil
+				m[0].TrailingComment = &Comment{Text: " one"}
+			},
+			"a {\n  x = 1 # one\n}\n",
+		},
+		{
+			"x {\n    a   1\n}\n",
+			func(doc *Document) {
+				x := doc.FindBlock("x")
+				x.Content.(*Block).Name = "n"
+				x.AddEntry("b", &Value{Content: true})
+			},
+			"x \"n\" {\n    a   1\n    b true\n}\n",
+		},
+		{
+			"# foo\na   1 # bar\n",
+			func(doc *Document) {
+				a := doc.TopLevel.FindEntry("a")
+				a.LeadingComments = nil
+				a.TrailingComment.Text = " baz"
+			},
+			"a 1 # baz\n",
+		},
+		{
+			"a 1\nx {\n  # end\n}\n",
+			func(doc *Document) {
+				x := doc.FindBlock("x").Content.(*Block)
+				x.EndComments = nil
+			},
+			"a 1\nx {\n}\n",
+		},
+		{
+			"a 1",
+			func(doc *Document) {
+				doc.TopLevel.AddEntry("b", &Value{Content: int64(2)})
+			},
+			"a 1\nb 2\n",
+		},
+	}
+
+	for _, test := range tests {
+		doc, err := Parse([]byte(test.s), "test")
+		if err != nil {
+			t.Errorf("cannot parse %q: %v", test.s, err)
+			continue
+		}
+
+		test.modify(doc)
+
+		var buf bytes.Buffer
+		if err := doc.PrintPreserving(&buf); err != nil {
+			t.Errorf("cannot print %q: %v", test.s, err)
+			continue
+		}
+
+		if s := buf.String(); s != test.expected {
+			t.Errorf("%q was modified and printed as %q instead of %q",
+				test.s, s, test.expected)
+		}
+	}
+}
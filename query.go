@@ -0,0 +1,519 @@
+package bcl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Paths select elements in a document. A path is a sequence of steps
+// separated by '.', each step selecting children of the elements selected by
+// the previous step:
+//
+//   - A symbol selects entries with this name and blocks with this type.
+//   - '*' selects all elements.
+//
+// A step can be followed by a quoted string to only select blocks with this
+// name, e.g. `server."api"`, and by any number of predicates in brackets:
+//
+//   - `[@name = <value>]`: blocks whose name is equal to the value.
+//   - `[@value = <value>]`: entries with at least one value equal to the value.
+//   - `[<name> = <value>]`: blocks containing a <name> entry with at least one
+//     value equal to the value.
+//
+// Predicates using the `!=` operator select the elements not matched by the
+// same predicate with the `=` operator.
+//
+// Values in predicates are written as in documents: strings, symbols,
+// integers, floats, booleans or null. Strings and symbols are equal if they
+// contain the same characters.
+//
+// The last step of a path can end with a value index, e.g. `listen[0]`, to
+// select the value at this position in matching entries. Entries with not
+// enough values and blocks are not selected.
+//
+// Querying elements does not change their read status.
+type Path struct {
+	steps []*queryStep
+	index *int
+}
+
+type queryStep struct {
+	Name       string // empty for wildcards
+	BlockName  *string
+	Predicates []*queryPredicate
+}
+
+type queryPredicate struct {
+	Operand string // "@name", "@value" or an entry name
+	Negated bool
+	Value   any // nil, bool, string, int64 or float64
+}
+
+func (doc *Document) Query(path string) ([]*Element, error) {
+	return doc.TopLevel.Query(path)
+}
+
+func (doc *Document) QueryValues(path string) ([]*Value, error) {
+	return doc.TopLevel.QueryValues(path)
+}
+
+func (elt *Element) Query(path string) ([]*Element, error) {
+	qp, err := ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return qp.Query(elt), nil
+}
+
+func (elt *Element) QueryValues(path string) ([]*Value, error) {
+	qp, err := ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return qp.QueryValues(elt), nil
+}
+
+func ParsePath(s string) (qp *Path, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			if verr, ok := v.(*queryPathError); ok {
+				err = fmt.Errorf("invalid path %q: %w", s, verr)
+				return
+			}
+
+			panic(v)
+		}
+	}()
+
+	p := queryParser{s: s}
+
+	qp = p.parsePath()
+	return
+}
+
+// Return whether the path ends with a value index, i.e. whether it selects
+// individual values instead of whole entries.
+func (qp *Path) HasValueIndex() bool {
+	return qp.index != nil
+}
+
+func (qp *Path) QueryValues(elt *Element) []*Value {
+	var values []*Value
+
+	for _, child := range qp.Query(elt) {
+		entry, ok := child.Content.(*Entry)
+		if !ok {
+			continue
+		}
+
+		if qp.index == nil {
+			values = append(values, entry.Values...)
+		} else {
+			values = append(values, entry.Values[*qp.index])
+		}
+	}
+
+	return values
+}
+
+func (qp *Path) Query(elt *Element) []*Element {
+	elts := []*Element{elt}
+
+	for _, step := range qp.steps {
+		var children []*Element
+
+		for _, elt := range elts {
+			block, ok := elt.Content.(*Block)
+			if !ok {
+				continue
+			}
+
+			for _, child := range block.Elements {
+				if step.match(child) {
+					children = append(children, child)
+				}
+			}
+		}
+
+		elts = children
+	}
+
+	if qp.index != nil {
+		var entries []*Element
+
+		for _, elt := range elts {
+			if entry, ok := elt.Content.(*Entry); ok {
+				if *qp.index < len(entry.Values) {
+					entries = append(entries, elt)
+				}
+			}
+		}
+
+		elts = entries
+	}
+
+	return elts
+}
+
+func (s *queryStep) match(elt *Element) bool {
+	if s.Name != "" && elt.Name() != s.Name {
+		return false
+	}
+
+	if s.BlockName != nil {
+		block, ok := elt.Content.(*Block)
+		if !ok || block.Name != *s.BlockName {
+			return false
+		}
+	}
+
+	for _, pred := range s.Predicates {
+		if pred.match(elt) == pred.Negated {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (p *queryPredicate) match(elt *Element) bool {
+	switch p.Operand {
+	case "@name":
+		block, ok := elt.Content.(*Block)
+		return ok && queryValueMatch(&Value{Content: String{String: block.Name}},
+			p.Value)
+
+	case "@value":
+		entry, ok := elt.Content.(*Entry)
+		return ok && queryValuesMatch(entry.Values, p.Value)
+
+	default:
+		block, ok := elt.Content.(*Block)
+		if !ok {
+			return false
+		}
+
+		for _, child := range block.Elements {
+			if entry, ok := child.Content.(*Entry); ok && entry.Name == p.Operand {
+				if queryValuesMatch(entry.Values, p.Value) {
+					return true
+				}
+			}
+		}
+
+		return false
+	}
+}
+
+func queryValuesMatch(values []*Value, expected any) bool {
+	for _, v := range values {
+		if queryValueMatch(v, expected) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func queryValueMatch(v *Value, expected any) bool {
+	switch e := expected.(type) {
+	case nil:
+		return v.Content == nil
+
+	case bool:
+		b, ok := v.Content.(bool)
+		return ok && b == e
+
+	case string:
+		switch content := v.Content.(type) {
+		case Symbol:
+			return string(content) == e
+		case String:
+			return content.String == e
+		}
+
+	case int64:
+		switch content := v.Content.(type) {
+		case int64:
+			return content == e
+		case float64:
+			return content == float64(e)
+		}
+
+	case float64:
+		switch content := v.Content.(type) {
+		case int64:
+			return float64(content) == e
+		case float64:
+			return content == e
+		}
+
+	default:
+		panic(fmt.Sprintf("unhandled value %#v (%T)", e, e))
+	}
+
+	return false
+}
+
+type queryParser struct {
+	s   string
+	pos int
+}
+
+type queryPathError struct {
+	Offset  int
+	Message string
+}
+
+func (err *queryPathError) Error() string {
+	return fmt.Sprintf("offset %d: %s", err.Offset, err.Message)
+}
+
+func (p *queryParser) error(format string, args ...any) {
+	panic(&queryPathError{
+		Offset:  p.pos,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (p *queryParser) parsePath() *Path {
+	var qp Path
+
+	if p.s == "" {
+		p.error("empty path")
+	}
+
+	for {
+		qp.steps = append(qp.steps, p.parseStep())
+
+		if p.pos == len(p.s) {
+			break
+		}
+
+		if p.peek() == '[' {
+			p.pos++
+			i := p.parseIndex()
+			qp.index = &i
+
+			if p.pos < len(p.s) {
+				p.error("value index must be at the end of the path")
+			}
+
+			break
+		}
+
+		p.expect('.')
+	}
+
+	return &qp
+}
+
+func (p *queryParser) parseStep() *queryStep {
+	var step queryStep
+
+	switch c := p.peek(); {
+	case c == '*':
+		p.pos++
+
+	case isSymbolFirstChar(c):
+		step.Name = p.parseSymbol()
+
+	case c == '"':
+		p.error("missing block type before block name")
+
+	default:
+		p.error("invalid path step")
+	}
+
+	if strings.HasPrefix(p.s[p.pos:], ".\"") {
+		p.pos++
+		name := p.parseQuotedString()
+		step.BlockName = &name
+	}
+
+	for strings.HasPrefix(p.s[p.pos:], "[") {
+		// Value indexes are handled at the path level
+		if c := p.peekAt(1); c >= '0' && c <= '9' {
+			break
+		}
+
+		p.pos++
+		step.Predicates = append(step.Predicates, p.parsePredicate())
+	}
+
+	return &step
+}
+
+func (p *queryParser) parsePredicate() *queryPredicate {
+	var pred queryPredicate
+
+	p.skipWhitespaces()
+
+	switch c := p.peek(); {
+	case c == '@':
+		p.pos++
+
+		start := p.pos
+		operand := p.parseSymbol()
+
+		switch operand {
+		case "name", "value":
+			pred.Operand = "@" + operand
+		default:
+			p.pos = start
+			p.error("unknown attribute %q", operand)
+		}
+
+	case isSymbolFirstChar(c):
+		pred.Operand = p.parseSymbol()
+
+	default:
+		p.error("invalid predicate")
+	}
+
+	p.skipWhitespaces()
+
+	switch {
+	case strings.HasPrefix(p.s[p.pos:], "="):
+		p.pos++
+	case strings.HasPrefix(p.s[p.pos:], "!="):
+		pred.Negated = true
+		p.pos += 2
+	default:
+		p.error("missing predicate operator")
+	}
+
+	p.skipWhitespaces()
+	pred.Value = p.parseValue()
+	p.skipWhitespaces()
+
+	p.expect(']')
+
+	return &pred
+}
+
+func (p *queryParser) parseIndex() int {
+	start := p.pos
+
+	for p.pos < len(p.s) && p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+		p.pos++
+	}
+
+	i, err := strconv.Atoi(p.s[start:p.pos])
+	if err != nil {
+		p.pos = start
+		p.error("invalid value index")
+	}
+
+	p.expect(']')
+
+	return i
+}
+
+func (p *queryParser) parseValue() any {
+	if p.peek() == '"' {
+		return p.parseQuotedString()
+	}
+
+	start := p.pos
+
+	for p.pos < len(p.s) && p.s[p.pos] != ']' && p.s[p.pos] != ' ' &&
+		p.s[p.pos] != '\t' {
+		p.pos++
+	}
+
+	s := p.s[start:p.pos]
+
+	switch s {
+	case "":
+		p.error("missing predicate value")
+	case "null":
+		return nil
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i
+	}
+
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+
+	for i, c := range s {
+		if (i == 0 && !isSymbolFirstChar(c)) || !isSymbolChar(c) {
+			p.pos = start
+			p.error("invalid value %q", s)
+		}
+	}
+
+	return s
+}
+
+func (p *queryParser) parseSymbol() string {
+	start := p.pos
+
+	for p.pos < len(p.s) {
+		c, size := utf8.DecodeRuneInString(p.s[p.pos:])
+
+		if p.pos == start && !isSymbolFirstChar(c) {
+			p.error("invalid symbol")
+		} else if !isSymbolChar(c) {
+			break
+		}
+
+		p.pos += size
+	}
+
+	return p.s[start:p.pos]
+}
+
+func (p *queryParser) parseQuotedString() string {
+	prefix, err := strconv.QuotedPrefix(p.s[p.pos:])
+	if err != nil || prefix[0] != '"' {
+		p.error("invalid quoted string")
+	}
+
+	s, _ := strconv.Unquote(prefix)
+	p.pos += len(prefix)
+
+	return s
+}
+
+func (p *queryParser) skipWhitespaces() {
+	for p.pos < len(p.s) && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *queryParser) expect(c byte) {
+	if p.peek() != rune(c) {
+		if p.pos == len(p.s) {
+			p.error("missing %q", c)
+		}
+
+		p.error("unexpected character %q, expected %q", p.s[p.pos], c)
+	}
+
+	p.pos++
+}
+
+func (p *queryParser) peek() rune {
+	return p.peekAt(0)
+}
+
+func (p *queryParser) peekAt(offset int) rune {
+	if p.pos+offset >= len(p.s) {
+		return 0
+	}
+
+	c, _ := utf8.DecodeRuneInString(p.s[p.pos+offset:])
+	return c
+}
@@ -0,0 +1,177 @@
+package bcl
+
+import (
+	"bytes"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+const testQueryDocument = `
+log_level "info"
+tags "a" b 3
+
+server "api" {
+  port 80
+  tls true
+  listen "localhost" 8080
+}
+
+server "web" {
+  port 81
+}
+
+server {
+  port 82
+  debug null
+}
+
+ratio 0.5
+`
+
+func TestQuery(t *testing.T) {
+	doc, err := Parse([]byte(testQueryDocument), "test")
+	if err != nil {
+		t.Fatalf("cannot parse document: %v", err)
+	}
+
+	tests := []struct {
+		path string
+		ids  []string // element ids with the line of the element
+	}{
+		{"log_level", []string{"log_level:2"}},
+		{"unknown", nil},
+		{"server", []string{"server.api:5", "server.web:11", "server:15"}},
+		{`server."web"`, []string{"server.web:11"}},
+		{`server."foo"`, nil},
+		{"server.port", []string{"port:6", "port:12", "port:16"}},
+		{`server."api".port`, []string{"port:6"}},
+		{"*", []string{"log_level:2", "tags:3", "server.api:5",
+			"server.web:11", "server:15", "ratio:20"}},
+		{"*.tls", []string{"tls:7"}},
+		{`server[@name = "api"]`, []string{"server.api:5"}},
+		{`server[@name != "api"]`, []string{"server.web:11", "server:15"}},
+		{"server[port = 81]", []string{"server.web:11"}},
+		{"server[port = 81].port", []string{"port:12"}},
+		{"server[port != 81][tls != true]", []string{"server:15"}},
+		{"server[debug = null]", []string{"server:15"}},
+		{"server[tls = true]", []string{"server.api:5"}},
+		{`tags[@value = "b"]`, []string{"tags:3"}},
+		{"tags[@value = b]", []string{"tags:3"}},
+		{"tags[@value = 3]", []string{"tags:3"}},
+		{"tags[@value = 4]", nil},
+		{"ratio[@value = 0.5]", []string{"ratio:20"}},
+		{"server.listen[1]", []string{"listen:8"}},
+		{"tags[2]", []string{"tags:3"}},
+		{"tags[3]", nil},
+		{"server[0]", nil},
+	}
+
+	for _, test := range tests {
+		elts, err := doc.Query(test.path)
+		if err != nil {
+			t.Errorf("cannot query %q: %v", test.path, err)
+			continue
+		}
+
+		var ids []string
+		for _, elt := range elts {
+			ids = append(ids,
+				elt.Id()+":"+strconv.Itoa(elt.Location.Start.Line))
+		}
+
+		if !slices.Equal(ids, test.ids) {
+			t.Errorf("query %q returned %v instead of %v",
+				test.path, ids, test.ids)
+		}
+	}
+}
+
+func TestQueryValues(t *testing.T) {
+	doc, err := Parse([]byte(testQueryDocument), "test")
+	if err != nil {
+		t.Fatalf("cannot parse document: %v", err)
+	}
+
+	tests := []struct {
+		path   string
+		values string
+	}{
+		{"log_level", `"info"`},
+		{"tags", `"a" b 3`},
+		{"tags[1]", `b`},
+		{"server.port", `80 81 82`},
+		{"server.listen[0]", `"localhost"`},
+		{"server", ``},
+	}
+
+	for _, test := range tests {
+		values, err := doc.QueryValues(test.path)
+		if err != nil {
+			t.Errorf("cannot query %q: %v", test.path, err)
+			continue
+		}
+
+		var buf bytes.Buffer
+		for i, value := range values {
+			if i > 0 {
+				buf.WriteByte(' ')
+			}
+
+			value.Print(&buf)
+		}
+
+		if buf.String() != test.values {
+			t.Errorf("query %q returned values %q instead of %q",
+				test.path, buf.String(), test.values)
+		}
+	}
+}
+
+func TestQueryReadStatus(t *testing.T) {
+	doc, err := Parse([]byte(testQueryDocument), "test")
+	if err != nil {
+		t.Fatalf("cannot parse document: %v", err)
+	}
+
+	elts, err := doc.Query("server.port")
+	if err != nil {
+		t.Fatalf("cannot query document: %v", err)
+	}
+
+	for _, elt := range elts {
+		if elt.readStatus != ElementReadStatusUnread {
+			t.Errorf("querying element %q changed its read status to %q",
+				elt.Id(), elt.readStatus)
+		}
+	}
+}
+
+func TestParsePathInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		".",
+		"a.",
+		".a",
+		"a..b",
+		"A",
+		"a[",
+		"a[]",
+		"a[@foo = 1]",
+		"a[b]",
+		"a[b = ]",
+		"a[b == 1]",
+		`a[b = "x]`,
+		"a[-1]",
+		"a[1].b",
+		"a[1][2]",
+		`a."b`,
+		"a b",
+	}
+
+	for _, s := range tests {
+		if _, err := ParsePath(s); err == nil {
+			t.Errorf("parsing path %q should have failed", s)
+		}
+	}
+}
@@ -0,0 +1,476 @@
+package bcl
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// A schema describes the structure of a document. Schemas are themselves BCL
+// documents, for example:
+//
+//	entry "log_level" {
+//	  value {
+//	    type symbol
+//	    one_of "debug" "info" "error"
+//	  }
+//	}
+//
+//	block "server" {
+//	  name required
+//	  repeated true
+//
+//	  entry "listen" {
+//	    required true
+//	    value {
+//	      type string
+//	    }
+//	    value {
+//	      type integer
+//	      min 1
+//	      max 65535
+//	    }
+//	  }
+//
+//	  entry "tls_certificate" {
+//	    value {
+//	      type string
+//	    }
+//	  }
+//
+//	  entry "insecure" {
+//	    value {
+//	      type bool
+//	    }
+//	  }
+//
+//	  maybe_one_of "tls_certificate" "insecure"
+//	}
+//
+// The top-level of the schema describes the top-level of the document and
+// accepts the same elements as "block" blocks, with the exception of "name",
+// "required" and "repeated".
+//
+// Block schemas contain:
+//
+//   - "name": "required", "forbidden" or "optional" (the default).
+//   - "required": whether at least one block of this type must be present.
+//   - "repeated": whether multiple blocks of this type are allowed.
+//   - "open": whether unknown elements are accepted in the block.
+//   - "entry" and "block": schemas for child elements.
+//   - "one_of" and "maybe_one_of": groups of child elements which are
+//     mutually exclusive, with one of them being required for "one_of".
+//
+// Entry schemas contain "required" and "repeated", one "value" block for each
+// positional value, an optional "values" block describing all additional
+// values, and "min_values" and "max_values" to override the number of values
+// implied by "value" and "values" blocks.
+//
+// Value schemas contain "type" (one or more value types), "one_of" (a list of
+// accepted values) and "min" and "max" for numeric values.
+type Schema struct {
+	Root *BlockSchema
+}
+
+type BlockNameConstraint string
+
+const (
+	BlockNameOptional  BlockNameConstraint = "optional"
+	BlockNameRequired  BlockNameConstraint = "required"
+	BlockNameForbidden BlockNameConstraint = "forbidden"
+)
+
+type BlockSchema struct {
+	Type     string
+	Name     BlockNameConstraint
+	Required bool
+	Repeated bool
+	Open     bool
+
+	Entries []*EntrySchema
+	Blocks  []*BlockSchema
+
+	OneOf      [][]string
+	MaybeOneOf [][]string
+}
+
+type EntrySchema struct {
+	Name     string
+	Required bool
+	Repeated bool
+
+	Values    []*ValueSchema
+	Rest      *ValueSchema
+	MinValues *int
+	MaxValues *int
+}
+
+type ValueSchema struct {
+	Types []ValueType
+	OneOf []*Value
+	Min   *float64
+	Max   *float64
+}
+
+func ParseSchema(data []byte, source string) (*Schema, error) {
+	doc, err := Parse(data, source)
+	if err != nil {
+		return nil, err
+	}
+
+	var root BlockSchema
+	root.readContent(doc.TopLevel)
+
+	if errs := doc.ValidationErrors(); errs != nil {
+		return nil, errs
+	}
+
+	return &Schema{Root: &root}, nil
+}
+
+func (s *BlockSchema) ReadBCLElement(elt *Element) error {
+	s.Type = elt.BlockName()
+
+	s.Name = BlockNameOptional
+	if entry := elt.FindEntry("name"); entry != nil {
+		if entry.CheckValueOneOf(0, string(BlockNameOptional),
+			string(BlockNameRequired), string(BlockNameForbidden)) {
+			entry.Value(0, (*string)(&s.Name))
+		}
+	}
+
+	elt.MaybeEntryValues("required", &s.Required)
+	elt.MaybeEntryValues("repeated", &s.Repeated)
+
+	s.readContent(elt)
+
+	return nil
+}
+
+func (s *BlockSchema) readContent(elt *Element) {
+	elt.MaybeEntryValues("open", &s.Open)
+
+	elt.Blocks("entry", &s.Entries)
+	elt.Blocks("block", &s.Blocks)
+
+	for _, entry := range elt.FindEntries("one_of") {
+		var names []string
+		if entry.CheckMinNbValues(2) && entry.Values(&names) {
+			s.OneOf = append(s.OneOf, names)
+		}
+	}
+
+	for _, entry := range elt.FindEntries("maybe_one_of") {
+		var names []string
+		if entry.CheckMinNbValues(2) && entry.Values(&names) {
+			s.MaybeOneOf = append(s.MaybeOneOf, names)
+		}
+	}
+}
+
+func (s *EntrySchema) ReadBCLElement(elt *Element) error {
+	s.Name = elt.BlockName()
+
+	elt.MaybeEntryValues("required", &s.Required)
+	elt.MaybeEntryValues("repeated", &s.Repeated)
+
+	elt.Blocks("value", &s.Values)
+	elt.MaybeBlock("values", &s.Rest)
+
+	elt.MaybeEntryValues("min_values", &s.MinValues)
+	elt.MaybeEntryValues("max_values", &s.MaxValues)
+
+	return nil
+}
+
+func (s *ValueSchema) ReadBCLElement(elt *Element) error {
+	if entry := elt.FindEntry("type"); entry != nil {
+		if entry.CheckMinNbValues(1) {
+			for i := range entry.NbValues() {
+				if !entry.CheckValueOneOf(i, string(ValueTypeNull),
+					string(ValueTypeSymbol),
+					string(ValueTypeBool), string(ValueTypeString),
+					string(ValueTypeInteger), string(ValueTypeFloat),
+					string(ValueTypeList), string(ValueTypeMap)) {
+					continue
+				}
+
+				var t string
+				entry.Value(i, &t)
+				s.Types = append(s.Types, ValueType(t))
+			}
+		}
+	}
+
+	if entry := elt.FindEntry("one_of"); entry != nil {
+		if entry.CheckMinNbValues(1) {
+			s.OneOf = entry.Content.(*Entry).Values
+		}
+	}
+
+	elt.MaybeEntryValues("min", &s.Min)
+	elt.MaybeEntryValues("max", &s.Max)
+
+	return nil
+}
+
+// Validate a document using a schema. Elements described by the schema are
+// marked as read, so validation errors include both errors detected by the
+// schema and elements which are not part of it.
+//
+// The read status and the validation errors of all elements are reset first,
+// so that a document can be validated several times, e.g. after being
+// modified or with another schema.
+func (doc *Document) ValidateSchema(schema *Schema) *ValidationErrors {
+	doc.ResetReadStatus()
+	doc.resetValidationErrors()
+
+	schema.Root.validateContent(doc.TopLevel)
+	return doc.ValidationErrors()
+}
+
+func (s *BlockSchema) validate(elt *Element) {
+	switch s.Name {
+	case BlockNameRequired:
+		elt.BlockName()
+
+	case BlockNameForbidden:
+		if elt.Content.(*Block).Name != "" {
+			elt.AddUnexpectedBlockNameError()
+		}
+	}
+
+	s.validateContent(elt)
+}
+
+func (s *BlockSchema) validateContent(elt *Element) {
+	for _, es := range s.Entries {
+		entries := elt.FindEntries(es.Name)
+		if !s.checkOccurrences(elt, entries, ElementTypeEntry, es.Name,
+			es.Required, es.Repeated) {
+			continue
+		}
+
+		for _, entry := range entries {
+			if entry.readStatus == ElementReadStatusRead {
+				es.validate(entry)
+			}
+		}
+	}
+
+	for _, bs := range s.Blocks {
+		blocks := elt.FindBlocks(bs.Type)
+		if !s.checkOccurrences(elt, blocks, ElementTypeBlock, bs.Type,
+			bs.Required, bs.Repeated) {
+			continue
+		}
+
+		for _, block := range blocks {
+			if block.readStatus == ElementReadStatusRead {
+				bs.validate(block)
+			}
+		}
+	}
+
+	for _, names := range s.OneOf {
+		elt.CheckElementsOneOf(names...)
+	}
+
+	for _, names := range s.MaybeOneOf {
+		elt.CheckElementsMaybeOneOf(names...)
+	}
+
+	if s.Open {
+		markUnreadElements(elt)
+	}
+}
+
+func (s *BlockSchema) checkOccurrences(elt *Element, children []*Element, eltType ElementType, name string, required, repeated bool) bool {
+	if len(children) == 0 {
+		if required {
+			elt.AddMissingElementError(&eltType, []string{name})
+		}
+
+		return false
+	}
+
+	if !repeated {
+		for _, child := range children[1:] {
+			child.readStatus = ElementReadStatusIgnored
+		}
+	}
+
+	return true
+}
+
+func (s *EntrySchema) validate(elt *Element) {
+	minValues := len(s.Values)
+	if s.MinValues != nil {
+		minValues = *s.MinValues
+	}
+
+	maxValues := len(s.Values)
+	if s.Rest != nil {
+		maxValues = math.MaxInt
+	}
+	if s.MaxValues != nil {
+		maxValues = *s.MaxValues
+	}
+
+	if maxValues == math.MaxInt {
+		if !elt.CheckMinNbValues(minValues) {
+			return
+		}
+	} else {
+		if !elt.CheckMinMaxNbValues(minValues, maxValues) {
+			return
+		}
+	}
+
+	for i, value := range elt.Content.(*Entry).Values {
+		vs := s.Rest
+		if i < len(s.Values) {
+			vs = s.Values[i]
+		}
+
+		if vs == nil {
+			continue
+		}
+
+		if err := vs.validate(value); err != nil {
+			elt.AddInvalidValueError(value, err)
+		}
+	}
+}
+
+func (s *ValueSchema) validate(v *Value) error {
+	if len(s.Types) > 0 {
+		t := v.Type()
+
+		valid := false
+		for _, t2 := range s.Types {
+			if t == t2 {
+				valid = true
+				break
+			}
+		}
+
+		if !valid {
+			return NewValueTypeError(v, s.Types...)
+		}
+	}
+
+	if len(s.OneOf) > 0 {
+		contents := make([]any, len(s.OneOf))
+		for i, v2 := range s.OneOf {
+			contents[i] = comparableValueContent(v2)
+		}
+
+		if err := v.IsOneOf(contents...); err != nil {
+			return err
+		}
+	}
+
+	switch content := v.Content.(type) {
+	case int64:
+		if err := s.validateIntegerRange(float64(content)); err != nil {
+			return err
+		}
+
+	case *big.Int:
+		f, _ := new(big.Float).SetInt(content).Float64()
+		if err := s.validateIntegerRange(f); err != nil {
+			return err
+		}
+
+	case float64:
+		if err := s.validateFloatRange(content); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *ValueSchema) validateIntegerRange(f float64) error {
+	switch {
+	case s.Min != nil && s.Max != nil:
+		if f < *s.Min || f > *s.Max {
+			return NewMinMaxIntegerValueError(int64(math.Ceil(*s.Min)),
+				int64(math.Floor(*s.Max)))
+		}
+
+	case s.Min != nil:
+		if f < *s.Min {
+			return NewMinIntegerValueError(int64(math.Ceil(*s.Min)))
+		}
+
+	case s.Max != nil:
+		if f > *s.Max {
+			return NewMaxIntegerValueError(int64(math.Floor(*s.Max)))
+		}
+	}
+
+	return nil
+}
+
+func (s *ValueSchema) validateFloatRange(f float64) error {
+	switch {
+	case s.Min != nil && s.Max != nil:
+		if f < *s.Min || f > *s.Max {
+			return NewMinMaxFloatValueError(*s.Min, *s.Max)
+		}
+
+	case s.Min != nil:
+		if f < *s.Min {
+			return NewMinFloatValueError(*s.Min)
+		}
+
+	case s.Max != nil:
+		if f > *s.Max {
+			return NewMaxFloatValueError(*s.Max)
+		}
+	}
+
+	return nil
+}
+
+// Return the content of a value in the form accepted by Value.IsOneOf.
+func comparableValueContent(v *Value) any {
+	switch content := v.Content.(type) {
+	case Symbol:
+		return string(content)
+	case String:
+		return content.String
+	case nil, bool, int64, *big.Int, float64:
+		return content
+	case List:
+		contents := make([]any, len(content))
+		for i, v2 := range content {
+			contents[i] = comparableValueContent(v2)
+		}
+		return contents
+	case Map:
+		contents := make(map[string]any, len(content))
+		for _, entry := range content {
+			contents[entry.Key] = comparableValueContent(entry.Value)
+		}
+		return contents
+	default:
+		panic(fmt.Sprintf("unhandled value content %#v (%T)", content, content))
+	}
+}
+
+func markUnreadElements(elt *Element) {
+	block, ok := elt.Content.(*Block)
+	if !ok {
+		return
+	}
+
+	for _, child := range block.Elements {
+		if child.readStatus == ElementReadStatusUnread {
+			child.readStatus = ElementReadStatusRead
+			markUnreadElements(child)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package bcl
+
+import (
+	"slices"
+	"testing"
+)
+
+const testSchema = `
+entry "log_level" {
+  required true
+  value {
+    type symbol
+    one_of "debug" "info" "error"
+  }
+}
+
+entry "tags" {
+  values {
+    type string
+  }
+}
+
+block "server" {
+  name required
+  repeated true
+
+  entry "port" {
+    required true
+    value {
+      type integer
+      min 1
+      max 65535
+    }
+  }
+
+  entry "ratio" {
+    value {
+      type float integer
+      min 0
+      max 1
+    }
+  }
+
+  entry "tls_certificate" {
+    value {
+      type string
+    }
+  }
+
+  entry "insecure" {
+    value {
+      type bool
+    }
+  }
+
+  maybe_one_of "tls_certificate" "insecure"
+}
+
+block "extra" {
+  name forbidden
+  open true
+}
+`
+
+func TestValidateSchema(t *testing.T) {
+	schema, err := ParseSchema([]byte(testSchema), "schema")
+	if err != nil {
+		t.Fatalf("cannot parse schema: %v", err)
+	}
+
+	tests := []struct {
+		s     string
+		lines []int // lines of validation errors
+	}{
+		{"log_level info", nil},
+		{"log_level info\ntags \"a\" \"b\" \"c\"", nil},
+		{
+			`log_level debug
+server "a" {
+  port 80
+  ratio 0.5
+  insecure true
+}
+server "b" {
+  port 443
+  tls_certificate "cert.pem"
+}
+extra {
+  anything 1 2 3
+  nested {
+    foo "bar"
+  }
+}`,
+			nil,
+		},
+
+		// Missing required entry
+		{"tags \"a\"", []int{0}},
+
+		// Invalid value type and content
+		{"log_level \"info\"", []int{1}},
+		{"log_level warning", []int{1}},
+		{"log_level info\ntags \"a\" 1", []int{2}},
+
+		// Invalid number of values
+		{"log_level info debug", []int{1}},
+		{"log_level", []int{1}},
+
+		// Repeated entry
+		{"log_level info\nlog_level debug", []int{2}},
+
+		// Unknown element
+		{"log_level info\nfoo 1", []int{2}},
+
+		// Invalid block name
+		{"log_level info\nserver {\n  port 80\n}", []int{2}},
+		{"log_level info\nextra \"x\" {}", []int{2}},
+
+		// Out of range values
+		{"log_level info\nserver \"a\" {\n  port 0\n}", []int{3}},
+		{"log_level info\nserver \"a\" {\n  port 70000\n}", []int{3}},
+		{"log_level info\nserver \"a\" {\n  port 80\n  ratio 1.5\n}", []int{4}},
+
+		// Mutually exclusive entries
+		{"log_level info\nserver \"a\" {\n  port 80\n  insecure true\n" +
+			"  tls_certificate \"c\"\n}", []int{2}},
+	}
+
+	for _, test := range tests {
+		doc, err := Parse([]byte(test.s), "test")
+		if err != nil {
+			t.Errorf("cannot parse %q: %v", test.s, err)
+			continue
+		}
+
+		// Validating a document again returns the same errors
+		for range 2 {
+			var lines []int
+			var errs *ValidationErrors
+
+			if errs = doc.ValidateSchema(schema); errs != nil {
+				for _, err := range errs.Errs {
+					if err.Location == nil {
+						lines = append(lines, 0)
+					} else {
+						lines = append(lines, err.Location.Start.Line)
+					}
+				}
+			}
+
+			if !slices.Equal(lines, test.lines) {
+				t.Errorf("%q: validation errors were found on lines %v but "+
+					"should have been found on lines %v: %v",
+					test.s, lines, test.lines, errs)
+			}
+		}
+	}
+}
+
+func TestParseSchemaInvalid(t *testing.T) {
+	tests := []string{
+		`entry {}`,
+		`entry "a" {
+  value {
+    type foo
+  }
+}`,
+		`block "a" {
+  name maybe
+}`,
+		`entry "a" {
+  required 1
+}`,
+		`unknown 42`,
+	}
+
+	for _, s := range tests {
+		if _, err := ParseSchema([]byte(s), "schema"); err == nil {
+			t.Errorf("parsing schema %q should have failed", s)
+		}
+	}
+}
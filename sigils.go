@@ -0,0 +1,162 @@
+package bcl
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// A sigil handler decodes strings with a specific sigil, e.g. `~re"^a+$"`,
+// to Go values. The result of Decode is used by Value.Extract when it can be
+// assigned to the destination, or when it is a pointer to a value which can
+// be assigned to the destination.
+type SigilHandler interface {
+	Decode(string) (any, error)
+}
+
+type SigilHandlerFunc func(string) (any, error)
+
+func (fn SigilHandlerFunc) Decode(s string) (any, error) {
+	return fn(s)
+}
+
+var (
+	sigilHandlers     = make(map[string]SigilHandler)
+	sigilHandlersLock sync.RWMutex
+)
+
+func init() {
+	RegisterSigil("re", SigilHandlerFunc(decodeRegexpSigil))
+	RegisterSigil("dur", SigilHandlerFunc(decodeDurationSigil))
+	RegisterSigil("url", SigilHandlerFunc(decodeURLSigil))
+	RegisterSigil("b64", SigilHandlerFunc(decodeBase64Sigil))
+	RegisterSigil("path", SigilHandlerFunc(decodePathSigil))
+	RegisterSigil("size", SigilHandlerFunc(decodeByteSizeSigil))
+	RegisterSigil("pct", SigilHandlerFunc(decodePercentageSigil))
+}
+
+// Register a handler for a sigil, replacing any existing handler. Built-in
+// handlers are:
+//
+//   - "re": regular expressions decoded to *regexp.Regexp.
+//   - "dur": durations as supported by time.ParseDuration decoded to
+//     time.Duration; negative durations are invalid.
+//   - "url": URLs decoded to *url.URL.
+//   - "b64": base64 data (standard encoding with padding) decoded to []byte.
+//   - "path": file paths, cleaned with filepath.Clean, decoded to string.
+//   - "size": sizes as supported by ParseByteSize decoded to ByteSize.
+//   - "pct": percentages as supported by ParsePercentage decoded to
+//     Percentage.
+func RegisterSigil(sigil string, handler SigilHandler) {
+	sigilHandlersLock.Lock()
+	defer sigilHandlersLock.Unlock()
+
+	sigilHandlers[sigil] = handler
+}
+
+func LookupSigil(sigil string) (SigilHandler, bool) {
+	sigilHandlersLock.RLock()
+	defer sigilHandlersLock.RUnlock()
+
+	handler, found := sigilHandlers[sigil]
+	return handler, found
+}
+
+func decodeSigilString(s String) (any, bool, error) {
+	handler, found := LookupSigil(s.Sigil)
+	if !found {
+		return nil, false, nil
+	}
+
+	value, err := handler.Decode(s.String)
+	if err != nil {
+		return nil, true, fmt.Errorf("invalid ~%s string: %w", s.Sigil, err)
+	}
+
+	return value, true, nil
+}
+
+// Extract a string with a registered sigil. Return false if the string does
+// not have any registered sigil or if the decoded value cannot be assigned to
+// the destination.
+func (v *Value) extractSigilString(dest any) (bool, error) {
+	s, ok := v.Content.(String)
+	if !ok || s.Sigil == "" {
+		return false, nil
+	}
+
+	value, found, err := decodeSigilString(s)
+	if !found {
+		return false, nil
+	} else if err != nil {
+		return true, err
+	}
+
+	dv := reflect.ValueOf(dest)
+	if dv.Kind() != reflect.Pointer || dv.IsNil() {
+		return false, nil
+	}
+
+	// Handlers returning nil do not provide any value to assign
+	value2 := reflect.ValueOf(value)
+	if !value2.IsValid() {
+		return false, nil
+	}
+
+	destValue := dv.Elem()
+
+	switch {
+	case value2.Type().AssignableTo(destValue.Type()):
+		destValue.Set(value2)
+
+	case value2.Kind() == reflect.Pointer && !value2.IsNil() &&
+		value2.Elem().Type().AssignableTo(destValue.Type()):
+		destValue.Set(value2.Elem())
+
+	default:
+		return false, nil
+	}
+
+	return true, nil
+}
+
+func decodeRegexpSigil(s string) (any, error) {
+	return regexp.Compile(s)
+}
+
+func decodeDurationSigil(s string) (any, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return nil, err
+	}
+
+	// Negative durations are rejected as they are when extracting integers,
+	// floats or strings to time.Duration values.
+	if d < 0 {
+		return nil, errors.New("negative duration")
+	}
+
+	return d, nil
+}
+
+func decodeURLSigil(s string) (any, error) {
+	return url.Parse(s)
+}
+
+func decodeBase64Sigil(s string) (any, error) {
+	return base64.StdEncoding.DecodeString(s)
+}
+
+func decodePathSigil(s string) (any, error) {
+	if s == "" {
+		return nil, errors.New("empty path")
+	}
+
+	return filepath.Clean(s), nil
+}
@@ -0,0 +1,163 @@
+package bcl
+
+import (
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testSigilValue(t *testing.T, s string) *Value {
+	t.Helper()
+
+	value, err := ParseValue(s)
+	if err != nil {
+		t.Fatalf("cannot parse value %q: %v", s, err)
+	}
+
+	return value
+}
+
+func TestExtractSigil(t *testing.T) {
+	tests := []struct {
+		s        string
+		dest     any
+		expected any
+	}{
+		{`~re"^a+$"`, new(*regexp.Regexp), regexp.MustCompile("^a+$")},
+		{`~re"^a+$"`, new(regexp.Regexp), *regexp.MustCompile("^a+$")},
+		{`~dur"1m30s"`, new(time.Duration), 90 * time.Second},
+		{`~dur"0s"`, new(time.Duration), time.Duration(0)},
+		{`~url"http://a/b"`, new(*url.URL), &url.URL{Scheme: "http", Host: "a",
+			Path: "/b"}},
+		{`~url"http://a/b"`, new(url.URL), url.URL{Scheme: "http", Host: "a",
+			Path: "/b"}},
+		{`~b64"aGVsbG8="`, new([]byte), []byte("hello")},
+		{`~path"a/../b/./c/"`, new(string), "b/c"},
+		{`~size"2KiB"`, new(ByteSize), ByteSize(2048)},
+		{`~pct"50%"`, new(Percentage), Percentage(50)},
+		{`~unknown"x"`, new(string), "x"},
+	}
+
+	for _, test := range tests {
+		value := testSigilValue(t, test.s)
+
+		if err := value.Extract(test.dest); err != nil {
+			t.Errorf("cannot extract %s: %v", test.s, err)
+			continue
+		}
+
+		dest := reflect.ValueOf(test.dest).Elem().Interface()
+
+		if !reflect.DeepEqual(dest, test.expected) {
+			t.Errorf("%s was extracted as %#v instead of %#v",
+				test.s, dest, test.expected)
+		}
+	}
+}
+
+func TestExtractSigilInvalid(t *testing.T) {
+	tests := []struct {
+		s    string
+		dest any
+		err  string
+	}{
+		{`~re"("`, new(*regexp.Regexp), "invalid ~re string"},
+		{`~dur"1x"`, new(time.Duration), "invalid ~dur string"},
+		{`~dur"-5s"`, new(time.Duration), "negative duration"},
+		{`~b64"a"`, new([]byte), "invalid ~b64 string"},
+		{`~path""`, new(string), "empty path"},
+		{`~size"1Q"`, new(ByteSize), "unknown size unit"},
+		{`~dur"5s"`, new(int), "should be an integer"},
+	}
+
+	for _, test := range tests {
+		value := testSigilValue(t, test.s)
+
+		err := value.Extract(test.dest)
+		if err == nil {
+			t.Errorf("extracting %s to %T should have failed", test.s,
+				test.dest)
+			continue
+		}
+
+		if !strings.Contains(err.Error(), test.err) {
+			t.Errorf("extracting %s to %T failed with error %q which does "+
+				"not contain %q", test.s, test.dest, err, test.err)
+		}
+	}
+}
+
+func TestNegativeDurations(t *testing.T) {
+	// Negative durations are rejected whatever their representation
+	for _, s := range []string{"-5", "-0.5", `"-5s"`, `~dur"-5s"`} {
+		var d time.Duration
+		if err := testSigilValue(t, s).Extract(&d); err == nil {
+			t.Errorf("extracting %s should have failed", s)
+		} else if !strings.Contains(err.Error(), "negative duration") {
+			t.Errorf("extracting %s failed with error %q", s, err)
+		}
+	}
+}
+
+func TestRegisterSigil(t *testing.T) {
+	type upper string
+
+	RegisterSigil("testupper", SigilHandlerFunc(func(s string) (any, error) {
+		return upper(strings.ToUpper(s)), nil
+	}))
+
+	RegisterSigil("testnil", SigilHandlerFunc(func(s string) (any, error) {
+		return nil, nil
+	}))
+
+	if _, found := LookupSigil("testupper"); !found {
+		t.Fatalf("registered sigil not found")
+	}
+
+	var u upper
+	if err := testSigilValue(t, `~testupper"abc"`).Extract(&u); err != nil {
+		t.Errorf("cannot extract value: %v", err)
+	} else if u != "ABC" {
+		t.Errorf("value was extracted as %q", u)
+	}
+
+	// Handlers returning nil fall back to the extraction of the string
+	var s string
+	if err := testSigilValue(t, `~testnil"abc"`).Extract(&s); err != nil {
+		t.Errorf("cannot extract value: %v", err)
+	} else if s != "abc" {
+		t.Errorf("value was extracted as %q", s)
+	}
+}
+
+func TestParseValidateSigils(t *testing.T) {
+	tests := []struct {
+		s     string
+		valid bool
+	}{
+		{`a ~re"a+" ~dur"1s" ~unknown"x"`, true},
+		{`a ~re"("`, false},
+		{`a [~dur"-1s"]`, false},
+		{`a {b = ~url":"}`, false},
+	}
+
+	for _, test := range tests {
+		opts := ParseOptions{ValidateSigils: true}
+
+		_, err := ParseWithOptions([]byte(test.s), "test", opts)
+		if test.valid && err != nil {
+			t.Errorf("cannot parse %q: %v", test.s, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("parsing %q should have failed", test.s)
+		}
+
+		// Sigils are not validated by default
+		if _, err := Parse([]byte(test.s), "test"); err != nil {
+			t.Errorf("cannot parse %q without validating sigils: %v",
+				test.s, err)
+		}
+	}
+}
@@ -7,35 +7,143 @@ import (
 	"io"
 	"math"
 	"regexp"
+	"slices"
 	"strings"
 )
 
-type ParseError struct {
-	Err   error
+// The lines of a document and of the files it includes, used to print the
+// source code errors refer to.
+type SourceLines struct {
 	Lines []string
+
+	// Lines of the files included by the document, indexed by source
+	Sources map[string][]string
+}
+
+// Return the lines of the source a span refers to, i.e. the lines of an
+// included file if the span belongs to one, or the lines of the document.
+func (sl SourceLines) spanLines(span *Span) []string {
+	if sourceLines, found := sl.Sources[span.Source]; found {
+		return sourceLines
+	}
+
+	return sl.Lines
+}
+
+type ParseError struct {
+	Err error
+	SourceLines
 }
 
 func (err ParseError) Error() string {
-	const indent = "  "
+	var buf bytes.Buffer
+	printParseError(&buf, err.Err, err.SourceLines, "  ")
+	return strings.TrimRight(buf.String(), "\n")
+}
+
+func (err ParseError) Unwrap() error {
+	return err.Err
+}
+
+type ParseErrors struct {
+	Errs []error
+	SourceLines
+}
+
+func newParseErrors(errs []error, sourceLines SourceLines) ParseErrors {
+	errs = slices.Clone(errs)
 
+	// Errors are sorted by location, errors in included files being grouped
+	// by file in the order in which files were first reported.
+	sourceRanks := make(map[string]int)
+	for _, err := range errs {
+		source := parseErrorSource(err)
+		if _, found := sourceRanks[source]; !found {
+			sourceRanks[source] = len(sourceRanks)
+		}
+	}
+
+	slices.SortStableFunc(errs, func(err1, err2 error) int {
+		rank1 := sourceRanks[parseErrorSource(err1)]
+		rank2 := sourceRanks[parseErrorSource(err2)]
+
+		if rank1 != rank2 {
+			return rank1 - rank2
+		}
+
+		return parseErrorPoint(err1).Cmp(parseErrorPoint(err2))
+	})
+
+	return ParseErrors{Errs: errs, SourceLines: sourceLines}
+}
+
+func (errs ParseErrors) Error() string {
 	var buf bytes.Buffer
 
-	fmt.Fprintln(&buf, err.Err)
+	for _, err := range errs.Errs {
+		buf.WriteString("  - ")
+		printParseError(&buf, err, errs.SourceLines, "      ")
+	}
 
+	return strings.TrimRight(buf.String(), "\n")
+}
+
+func (errs ParseErrors) Unwrap() []error {
+	return errs.Errs
+}
+
+func printParseError(w io.Writer, err error, sourceLines SourceLines, indent string) {
+	fmt.Fprintln(w, err)
+
+	if span := ParseErrorLocation(err); span != nil {
+		span.PrintSource(w, sourceLines.spanLines(span), indent)
+	}
+}
+
+// Return the location of a syntax error or of a duplicate element error, or
+// nil if the error does not carry any location.
+func ParseErrorLocation(err error) *Span {
 	var syntaxErr *SyntaxError
 	var duplicateErr *DuplicateError
 
 	if errors.As(err, &syntaxErr) {
-		syntaxErr.Location.PrintSource(&buf, err.Lines, indent)
+		return &syntaxErr.Location
 	} else if errors.As(err, &duplicateErr) {
-		duplicateErr.Element.Location.PrintSource(&buf, err.Lines, indent)
+		return &duplicateErr.Element.Location
 	}
 
-	return strings.TrimRight(buf.String(), "\n")
+	return nil
 }
 
-func (err ParseError) Unwrap() error {
-	return err.Err
+// Return the description of a syntax error or of a duplicate element error
+// without any location information, or the error message for other errors.
+func ParseErrorDescription(err error) string {
+	var syntaxErr *SyntaxError
+	var duplicateErr *DuplicateError
+
+	if errors.As(err, &syntaxErr) {
+		return syntaxErr.Description
+	} else if errors.As(err, &duplicateErr) {
+		return duplicateErr.description()
+	}
+
+	return err.Error()
+}
+
+func parseErrorSource(err error) string {
+	if span := ParseErrorLocation(err); span != nil {
+		return span.Source
+	}
+
+	return ""
+}
+
+func parseErrorPoint(err error) Point {
+	if span := ParseErrorLocation(err); span != nil {
+		return span.Start
+	}
+
+	return Point{}
 }
 
 type SyntaxError struct {
@@ -60,11 +168,10 @@ type DuplicateError struct {
 }
 
 func (err *DuplicateError) Error() string {
-	eltType := err.Element.Type()
-	description := fmt.Sprintf("duplicate %s %q, previous %s found line %d",
-		eltType, err.Element.Id(), eltType, err.Element.Location.Start.Line)
-
-	msg := err.Element.Location.String() + ": " + description
+	msg := err.description()
+	if err.Element.Location.Start.Line > 0 {
+		msg = err.Element.Location.String() + ": " + msg
+	}
 	if err.Source != "" {
 		msg = err.Source + ":" + msg
 	}
@@ -72,8 +179,29 @@ func (err *DuplicateError) Error() string {
 	return msg
 }
 
+func (err *DuplicateError) description() string {
+	eltType := err.Element.Type()
+
+	// Elements created programmatically do not have any location
+	line := err.PreviousElement.Location.Start.Line
+	if line == 0 {
+		return fmt.Sprintf("duplicate %s %q", eltType, err.Element.Id())
+	}
+
+	// The previous element can come from another file if the document
+	// includes other files.
+	prevSource := err.PreviousElement.Location.Source
+	if prevSource != "" && prevSource != err.Element.Location.Source {
+		return fmt.Sprintf("duplicate %s %q, previous %s found in %s line %d",
+			eltType, err.Element.Id(), eltType, prevSource, line)
+	}
+
+	return fmt.Sprintf("duplicate %s %q, previous %s found line %d",
+		eltType, err.Element.Id(), eltType, line)
+}
+
 type Point struct {
-	Offset int // counted in characters (runes), not in bytes
+	Offset int // counted in bytes, not in characters
 	Line   int
 	Column int
 }
@@ -134,10 +262,19 @@ func (p Point) Equal(p2 Point) bool {
 type Span struct {
 	Start Point
 	End   Point
+
+	// The source of the document containing the span, which can be an
+	// included file. Empty for locations which do not come from a source.
+	Source string
 }
 
 func (s Span) Union(s2 Span) Span {
-	return Span{MinPoint(s.Start, s2.Start), MaxPoint(s.End, s2.End)}
+	source := s.Source
+	if source == "" {
+		source = s2.Source
+	}
+
+	return Span{MinPoint(s.Start, s2.Start), MaxPoint(s.End, s2.End), source}
 }
 
 func NewSpanAt(start Point, len int) Span {
@@ -176,6 +313,12 @@ func (s Span) Point() (Point, bool) {
 func (s Span) PrintSource(w io.Writer, lines []string, indent string) {
 	const context = 2
 
+	// Spans of elements and values created programmatically, or referring to
+	// content which is not available, cannot be printed.
+	if s.Start.Line < 1 || s.End.Line < s.Start.Line || s.End.Line > len(lines) {
+		return
+	}
+
 	nbLineDigits := int(math.Floor(math.Log10(float64(len(lines)))) + 1)
 
 	printLine := func(l int) {
@@ -1,21 +1,30 @@
 package bcl
 
 import (
+	"bytes"
 	"fmt"
+	"math"
+	"math/big"
 	"strconv"
+	"strings"
 	"unicode/utf8"
 )
 
 type TokenType string
 
 const (
-	TokenTypeEOL            TokenType = "eol"
-	TokenTypeOpeningBracket TokenType = "opening_bracket"
-	TokenTypeClosingBracket TokenType = "closing_bracket"
-	TokenTypeSymbol         TokenType = "symbol"
-	TokenTypeString         TokenType = "string"
-	TokenTypeInteger        TokenType = "integer"
-	TokenTypeFloat          TokenType = "float"
+	TokenTypeEOL                  TokenType = "eol"
+	TokenTypeOpeningBracket       TokenType = "opening_bracket"
+	TokenTypeClosingBracket       TokenType = "closing_bracket"
+	TokenTypeOpeningSquareBracket TokenType = "opening_square_bracket"
+	TokenTypeClosingSquareBracket TokenType = "closing_square_bracket"
+	TokenTypeEqual                TokenType = "equal"
+	TokenTypeComma                TokenType = "comma"
+	TokenTypeSymbol               TokenType = "symbol"
+	TokenTypeString               TokenType = "string"
+	TokenTypeInteger              TokenType = "integer"
+	TokenTypeFloat                TokenType = "float"
+	TokenTypeComment              TokenType = "comment"
 )
 
 type Token struct {
@@ -23,6 +32,12 @@ type Token struct {
 	Data  string
 	Value any
 	Span  Span
+
+	// Whitespaces, line continuations and invalid content skipped before the
+	// token. Concatenating the trivia and data of all tokens of a document,
+	// followed by the trailing trivia of the tokenizer, yields the original
+	// document.
+	Trivia string
 }
 
 func (t *Token) String() string {
@@ -35,13 +50,18 @@ func (t *Token) String() string {
 
 type tokenizer struct {
 	source string
+	input  []byte
 	data   []byte
 	point  Point
+
+	triviaStart    int
+	trailingTrivia string
 }
 
 func newTokenizer(data []byte, source string) *tokenizer {
 	return &tokenizer{
 		source: source,
+		input:  data,
 		data:   data,
 		point:  Point{Offset: 0, Line: 1, Column: 1},
 	}
@@ -52,10 +72,12 @@ func (t *tokenizer) syntaxError(format string, args ...any) error {
 }
 
 func (t *tokenizer) syntaxErrorAtPoint(point Point, format string, args ...any) error {
-	return t.syntaxErrorAt(Span{point, point}, format, args...)
+	return t.syntaxErrorAt(Span{Start: point, End: point}, format, args...)
 }
 
 func (t *tokenizer) syntaxErrorAt(span Span, format string, args ...any) error {
+	span.Source = t.source
+
 	return &SyntaxError{
 		Source:      t.source,
 		Location:    span,
@@ -64,6 +86,20 @@ func (t *tokenizer) syntaxErrorAt(span Span, format string, args ...any) error {
 }
 
 func (t *tokenizer) readToken() *Token {
+	token := t.scanToken()
+	if token == nil {
+		t.trailingTrivia = string(t.input[t.triviaStart:])
+		return nil
+	}
+
+	token.Span.Source = t.source
+	token.Trivia = string(t.input[t.triviaStart:token.Span.Start.Offset])
+	t.triviaStart = t.point.Offset
+
+	return token
+}
+
+func (t *tokenizer) scanToken() *Token {
 	for {
 		t.skipWhitespaces()
 		if len(t.data) == 0 {
@@ -73,8 +109,7 @@ func (t *tokenizer) readToken() *Token {
 		c := t.peekChar()
 
 		if c == '#' {
-			t.skipComment()
-			continue
+			return t.readCommentToken()
 		}
 
 		if c == '\\' {
@@ -106,7 +141,7 @@ func (t *tokenizer) readToken() *Token {
 			return &Token{
 				Type: TokenTypeOpeningBracket,
 				Span: NewSpanAt(start, 1),
-				Data: "\n",
+				Data: "{",
 			}
 
 		case c == '}':
@@ -115,7 +150,43 @@ func (t *tokenizer) readToken() *Token {
 			return &Token{
 				Type: TokenTypeClosingBracket,
 				Span: NewSpanAt(start, 1),
-				Data: "\n",
+				Data: "}",
+			}
+
+		case c == '[':
+			t.skip(1)
+
+			return &Token{
+				Type: TokenTypeOpeningSquareBracket,
+				Span: NewSpanAt(start, 1),
+				Data: "[",
+			}
+
+		case c == ']':
+			t.skip(1)
+
+			return &Token{
+				Type: TokenTypeClosingSquareBracket,
+				Span: NewSpanAt(start, 1),
+				Data: "]",
+			}
+
+		case c == '=':
+			t.skip(1)
+
+			return &Token{
+				Type: TokenTypeEqual,
+				Span: NewSpanAt(start, 1),
+				Data: "=",
+			}
+
+		case c == ',':
+			t.skip(1)
+
+			return &Token{
+				Type: TokenTypeComma,
+				Span: NewSpanAt(start, 1),
+				Data: ",",
 			}
 
 		case c >= 'a' && c <= 'z':
@@ -124,7 +195,7 @@ func (t *tokenizer) readToken() *Token {
 		case c == '+' || c == '-' || (c >= '0' && c <= '9'):
 			return t.readNumberToken()
 
-		case c == '"' || c == '~':
+		case c == '"' || c == '`' || c == '~':
 			return t.readStringToken()
 		}
 
@@ -132,6 +203,33 @@ func (t *tokenizer) readToken() *Token {
 	}
 }
 
+// Read a token and return syntax errors instead of panicking. In case of
+// error, the rest of the line is skipped so that the next call can continue
+// with the following line.
+func (t *tokenizer) readTokenRecover() (token *Token, err error) {
+	data := t.data
+
+	defer func() {
+		if v := recover(); v != nil {
+			verr, ok := v.(error)
+			if !ok {
+				panic(v)
+			}
+
+			err = verr
+
+			if len(t.data) == len(data) {
+				t.skipByte()
+			}
+
+			t.skipToEOL()
+		}
+	}()
+
+	token = t.readToken()
+	return
+}
+
 func (t *tokenizer) readSymbolToken() *Token {
 	data := t.data
 	start := t.point
@@ -141,7 +239,7 @@ func (t *tokenizer) readSymbolToken() *Token {
 	for len(t.data) > 0 {
 		c := t.peekChar()
 
-		if isWhitespaceOrEOLChar(c) {
+		if isWordBoundary(c) {
 			break
 		}
 
@@ -189,42 +287,38 @@ func (t *tokenizer) readNumberToken() *Token {
 		panic(t.syntaxError("truncated number"))
 	}
 
-	if c := t.peekChar(); c < '0' || c > '9' {
+	c := t.peekChar()
+
+	if c == 'i' && len(s) > 0 {
+		return t.readInfinityToken(data, start, s[0])
+	}
+
+	if c < '0' || c > '9' {
 		panic(t.syntaxError("invalid number character %q", c))
 	}
 
-	for len(t.data) > 0 {
+	if c == '0' && len(t.data) > 1 {
+		if radix := integerRadixes[t.data[1]]; radix != 0 {
+			return t.readRadixIntegerToken(data, start, s, radix)
+		}
+	}
+
+	s = t.readDigits(s, 10)
+
+	if len(t.data) > 0 {
 		c := t.peekChar()
 
-		if c >= '0' && c <= '9' {
-			s = append(s, c)
-			t.skip(1)
-		} else if c == '.' || c == 'e' || c == 'E' {
+		if c == '.' || c == 'e' || c == 'E' {
 			isFloat = true
 			s = append(s, c)
 			t.skip(1)
-			break
-		} else if isWordBoundary(c) {
-			break
-		} else {
+		} else if !isWordBoundary(c) {
 			panic(t.syntaxError("invalid number character %q", c))
 		}
 	}
 
 	if !isFloat {
-		i, err := strconv.ParseInt(string(s), 10, 64)
-		if err != nil {
-			panic(t.syntaxErrorAtPoint(start, "invalid integer: %v", err))
-		}
-
-		span := NewSpanAt(start, len(s))
-
-		return &Token{
-			Type:  TokenTypeInteger,
-			Span:  span,
-			Data:  string(data[:span.Len()]),
-			Value: i,
-		}
+		return t.integerToken(data, start, s, 10)
 	}
 
 	// Fractional part
@@ -236,20 +330,16 @@ func (t *tokenizer) readNumberToken() *Token {
 		panic(t.syntaxError("invalid number character %q", c))
 	}
 
-	for len(t.data) > 0 {
+	s = t.readDigits(s, 10)
+
+	if len(t.data) > 0 {
 		c := t.peekChar()
 
-		if c >= '0' && c <= '9' {
-			s = append(s, c)
-			t.skip(1)
-		} else if c == 'e' || c == 'E' {
+		if c == 'e' || c == 'E' {
 			hasExponent = true
 			s = append(s, c)
 			t.skip(1)
-			break
-		} else if isWordBoundary(c) {
-			break
-		} else {
+		} else if !isWordBoundary(c) {
 			panic(t.syntaxError("invalid number character %q", c))
 		}
 	}
@@ -267,19 +357,16 @@ func (t *tokenizer) readNumberToken() *Token {
 			if len(t.data) == 0 {
 				panic(t.syntaxError("truncated number"))
 			}
-		} else if c < '0' || c > '9' {
+		}
+
+		if c := t.peekChar(); c < '0' || c > '9' {
 			panic(t.syntaxError("invalid exponent character %q", c))
 		}
 
-		for len(t.data) > 0 {
-			c := t.peekChar()
+		s = t.readDigits(s, 10)
 
-			if c >= '0' && c <= '9' {
-				s = append(s, c)
-				t.skip(1)
-			} else if isWordBoundary(c) {
-				break
-			} else {
+		if len(t.data) > 0 {
+			if c := t.peekChar(); !isWordBoundary(c) {
 				panic(t.syntaxError("invalid number character %q", c))
 			}
 		}
@@ -290,7 +377,7 @@ func (t *tokenizer) readNumberToken() *Token {
 		panic(t.syntaxErrorAtPoint(start, "invalid float: %v", err))
 	}
 
-	span := NewSpanAt(start, len(s))
+	span := NewSpanAt(start, t.point.Offset-start.Offset)
 
 	return &Token{
 		Type:  TokenTypeFloat,
@@ -300,6 +387,117 @@ func (t *tokenizer) readNumberToken() *Token {
 	}
 }
 
+var integerRadixes = map[byte]int{
+	'x': 16,
+	'o': 8,
+	'b': 2,
+}
+
+func (t *tokenizer) readRadixIntegerToken(data []byte, start Point, s []rune, radix int) *Token {
+	t.skip(2)
+
+	if len(t.data) == 0 {
+		panic(t.syntaxError("truncated number"))
+	}
+
+	if c := t.peekChar(); !isDigit(c, radix) {
+		panic(t.syntaxError("invalid base %d digit %q", radix, c))
+	}
+
+	s = t.readDigits(s, radix)
+
+	if len(t.data) > 0 {
+		if c := t.peekChar(); !isWordBoundary(c) {
+			panic(t.syntaxError("invalid base %d digit %q", radix, c))
+		}
+	}
+
+	return t.integerToken(data, start, s, radix)
+}
+
+// Integers which do not fit in 64 bits are represented as *big.Int values.
+func (t *tokenizer) integerToken(data []byte, start Point, s []rune, radix int) *Token {
+	var value any
+
+	i, err := strconv.ParseInt(string(s), radix, 64)
+	if err == nil {
+		value = i
+	} else {
+		bi, ok := new(big.Int).SetString(string(s), radix)
+		if !ok {
+			panic(t.syntaxErrorAtPoint(start, "invalid integer: %v", err))
+		}
+
+		value = bi
+	}
+
+	span := NewSpanAt(start, t.point.Offset-start.Offset)
+
+	return &Token{
+		Type:  TokenTypeInteger,
+		Span:  span,
+		Data:  string(data[:span.Len()]),
+		Value: value,
+	}
+}
+
+func (t *tokenizer) readInfinityToken(data []byte, start Point, sign rune) *Token {
+	if !bytes.HasPrefix(t.data, []byte("inf")) {
+		panic(t.syntaxError("invalid number character %q", t.peekChar()))
+	}
+
+	t.skip(3)
+
+	if len(t.data) > 0 {
+		if c := t.peekChar(); !isWordBoundary(c) {
+			panic(t.syntaxError("invalid number character %q", c))
+		}
+	}
+
+	f := math.Inf(1)
+	if sign == '-' {
+		f = math.Inf(-1)
+	}
+
+	span := NewSpanAt(start, t.point.Offset-start.Offset)
+
+	return &Token{
+		Type:  TokenTypeFloat,
+		Span:  span,
+		Data:  string(data[:span.Len()]),
+		Value: f,
+	}
+}
+
+// Read digits in a specific radix and append them to s. Digits can be
+// separated by single underscore characters which are not part of the value.
+func (t *tokenizer) readDigits(s []rune, radix int) []rune {
+	for len(t.data) > 0 {
+		c := t.peekChar()
+
+		if c == '_' {
+			point := t.point
+			t.skip(1)
+
+			if len(t.data) == 0 || !isDigit(t.peekChar(), radix) {
+				panic(t.syntaxErrorAtPoint(point,
+					"digit separators must be placed between digits"))
+			}
+
+			continue
+		}
+
+		if !isDigit(c, radix) {
+			break
+		}
+
+		s = append(s, c)
+		t.skip(1)
+	}
+
+	return s
+}
+
 func (t *tokenizer) readStringToken() *Token {
 	data := t.data
 	start := t.point
@@ -318,7 +516,7 @@ func (t *tokenizer) readStringToken() *Token {
 			point := t.point
 			c := t.peekChar()
 
-			if c == '"' {
+			if c == '"' || c == '`' {
 				break
 			}
 
@@ -336,14 +534,36 @@ func (t *tokenizer) readStringToken() *Token {
 		}
 	}
 
-	t.skip(1) // '"'
+	var s string
+	var end Point
+
+	switch {
+	case bytes.HasPrefix(t.data, []byte(`"""`)):
+		s, end = t.readMultiLineString()
+	case t.data[0] == '`':
+		s, end = t.readRawString()
+	default:
+		s, end = t.readQuotedString()
+	}
+
+	span := Span{Start: start, End: end}
+
+	return &Token{
+		Type:  TokenTypeString,
+		Span:  span,
+		Data:  string(data[:span.Len()]),
+		Value: String{String: s, Sigil: string(sigil)},
+	}
+}
 
+// Read a string delimited by double quotes and return its content and the
+// position of its closing delimiter.
+func (t *tokenizer) readQuotedString() (string, Point) {
 	var s []rune
 
-	charLen := 1
-	byteLen := 1
+	t.skip(1) // '"'
 
-	for len(t.data) > 0 {
+	for {
 		if len(t.data) == 0 {
 			panic(t.syntaxError("truncated string"))
 		}
@@ -356,65 +576,178 @@ func (t *tokenizer) readStringToken() *Token {
 		}
 
 		if c == '"' {
-			charLen++
-			byteLen++
-
 			t.skip(1)
-			break
+			return string(s), point
 		}
 
 		if c == '\\' {
-			charLen++
-			byteLen++
+			s = append(s, t.readEscapeSequence())
+			continue
+		}
+
+		s = append(s, c)
+		t.skip(1)
+	}
+}
+
+// Read a raw string delimited by backquotes. Raw strings can span multiple
+// lines; their content is not processed, except for carriage return
+// characters preceding line feeds which are removed.
+func (t *tokenizer) readRawString() (string, Point) {
+	var s []rune
+
+	t.skip(1) // '`'
+
+	for {
+		if len(t.data) == 0 {
+			panic(t.syntaxError("truncated string"))
+		}
 
+		point := t.point
+		c := t.peekChar()
+
+		if c == '`' {
 			t.skip(1)
-			if len(t.data) == 0 {
-				panic(t.syntaxErrorAtPoint(point, "truncated escape sequence"))
+			return string(s), point
+		}
+
+		if c == '\r' && len(t.data) > 1 && t.data[1] == '\n' {
+			t.skip(1)
+			continue
+		}
+
+		if c < 0x20 && c != '\t' && c != '\n' {
+			panic(t.syntaxErrorAtPoint(point, "invalid string character %q", c))
+		}
+
+		s = append(s, c)
+		t.skip(1)
+	}
+}
+
+// Read a multi-line string. Multi-line strings start with a triple quote
+// followed by a line end, and end with a triple quote on its own line. The
+// indentation of the closing delimiter is removed from every line, and the
+// line end preceding the closing delimiter is not part of the string.
+func (t *tokenizer) readMultiLineString() (string, Point) {
+	start := t.point
+
+	t.skip(3) // '"""'
+	t.skipWhitespaces()
+
+	if len(t.data) > 0 && t.skipEOL() == 0 {
+		panic(t.syntaxError("invalid character %q after multi-line string "+
+			"opening delimiter", t.peekChar()))
+	}
+
+	indent, closingOffset := t.findMultiLineStringEnd(start)
+
+	var s []rune
+
+	for nbLines := 0; t.point.Offset != closingOffset; nbLines++ {
+		if nbLines > 0 {
+			s = append(s, '\n')
+		}
+
+		if bytes.HasPrefix(t.data, indent) {
+			t.skip(int64(len(indent)))
+		} else {
+			t.skipWhitespaces()
+
+			if t.startsWithEOL() == 0 {
+				panic(t.syntaxError("insufficient indentation in multi-line " +
+					"string"))
 			}
+		}
 
-			c = t.peekChar()
-
-			switch c {
-			case 'a':
-				c = '\a'
-			case 'b':
-				c = '\b'
-			case 't':
-				c = '\t'
-			case 'n':
-				c = '\n'
-			case 'v':
-				c = '\v'
-			case 'f':
-				c = '\f'
-			case 'r':
-				c = '\r'
-			case '"':
-			case '\\':
-
-			default:
-				span := NewSpanAt(point, 2)
-				panic(t.syntaxErrorAt(span,
-					"invalid escape sequence \"\\%c\"", c))
+		for t.startsWithEOL() == 0 {
+			point := t.point
+			c := t.peekChar()
+
+			if c < 0x20 && c != '\t' {
+				panic(t.syntaxErrorAtPoint(point,
+					"invalid string character %q", c))
 			}
+
+			if c == '\\' {
+				s = append(s, t.readEscapeSequence())
+				continue
+			}
+
+			s = append(s, c)
+			t.skip(1)
 		}
 
-		s = append(s, c)
+		t.skipEOL()
+	}
 
-		charLen++
-		byteLen += utf8.RuneLen(c)
+	t.skip(int64(len(indent)) + 2)
+	end := t.point
+	t.skip(1)
 
-		t.skip(1)
+	return string(s), end
+}
+
+// Locate the line containing the closing delimiter of a multi-line string,
+// returning its indentation and its offset.
+func (t *tokenizer) findMultiLineStringEnd(start Point) ([]byte, int) {
+	offset := t.point.Offset
+	data := t.data
+
+	for {
+		line, rest, found := bytes.Cut(data, []byte("\n"))
+
+		content := bytes.TrimLeft(line, " \t")
+		if bytes.HasPrefix(content, []byte(`"""`)) {
+			return line[:len(line)-len(content)], offset
+		}
+
+		if !found {
+			panic(t.syntaxErrorAtPoint(start, "truncated multi-line string"))
+		}
+
+		offset += len(line) + 1
+		data = rest
 	}
+}
 
-	span := NewSpanAt(start, charLen)
+func (t *tokenizer) readEscapeSequence() rune {
+	point := t.point
 
-	return &Token{
-		Type:  TokenTypeString,
-		Span:  span,
-		Data:  string(data[:byteLen]),
-		Value: String{String: string(s), Sigil: string(sigil)},
+	t.skip(1) // '\\'
+
+	if len(t.data) == 0 {
+		panic(t.syntaxErrorAtPoint(point, "truncated escape sequence"))
 	}
+
+	c := t.peekChar()
+
+	switch c {
+	case 'a':
+		c = '\a'
+	case 'b':
+		c = '\b'
+	case 't':
+		c = '\t'
+	case 'n':
+		c = '\n'
+	case 'v':
+		c = '\v'
+	case 'f':
+		c = '\f'
+	case 'r':
+		c = '\r'
+	case '"':
+	case '\\':
+
+	default:
+		span := NewSpanAt(point, 2)
+		panic(t.syntaxErrorAt(span, "invalid escape sequence \"\\%c\"", c))
+	}
+
+	t.skip(1)
+
+	return c
 }
 
 func (t *tokenizer) peekChar() rune {
@@ -505,18 +838,66 @@ func (t *tokenizer) skipEOL() int {
 	return 0
 }
 
-func (t *tokenizer) skipComment() {
+func (t *tokenizer) readCommentToken() *Token {
+	data := t.data
+	start := t.point
+
 	t.skip(1) // '#'
 
+	var text []rune
+
+	// The span ends on the last character of the comment, whose offset and
+	// column cannot be derived from the length of the text since offsets are
+	// counted in bytes.
+	end := start
+
 	for len(t.data) > 0 {
-		t.peekChar()
+		c := t.peekChar()
 
 		if t.startsWithEOL() > 0 {
 			break
 		}
 
+		text = append(text, c)
+		end = t.point
 		t.skip(1)
 	}
+
+	span := Span{Start: start, End: end}
+
+	return &Token{
+		Type:  TokenTypeComment,
+		Span:  span,
+		Data:  string(data[:len(data)-len(t.data)]),
+		Value: strings.TrimRight(string(text), " \t"),
+	}
+}
+
+func (t *tokenizer) skipToEOL() {
+	for len(t.data) > 0 {
+		if t.data[0] == '\n' ||
+			(t.data[0] == '\r' && len(t.data) > 1 && t.data[1] == '\n') {
+			return
+		}
+
+		t.skipByte()
+	}
+}
+
+// Skip a character without validating it, which is only useful to skip
+// invalid data.
+func (t *tokenizer) skipByte() {
+	_, sz := utf8.DecodeRune(t.data)
+
+	if t.data[0] == '\n' {
+		t.point.Line += 1
+		t.point.Column = 1
+	} else {
+		t.point.Column += 1
+	}
+
+	t.data = t.data[sz:]
+	t.point.Offset += sz
 }
 
 func isWhitespaceChar(c rune) bool {
@@ -538,5 +919,19 @@ func isSymbolChar(c rune) bool {
 }
 
 func isWordBoundary(c rune) bool {
-	return isWhitespaceOrEOLChar(c) || c == '{' || c == '}'
+	return isWhitespaceOrEOLChar(c) || c == '{' || c == '}' ||
+		c == '[' || c == ']' || c == '=' || c == ','
+}
+
+func isDigit(c rune, radix int) bool {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c-'0') < radix
+	case c >= 'a' && c <= 'f':
+		return radix == 16
+	case c >= 'A' && c <= 'F':
+		return radix == 16
+	}
+
+	return false
 }
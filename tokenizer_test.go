@@ -0,0 +1,161 @@
+package bcl
+
+import (
+	"testing"
+)
+
+func TestTokenizerCommentSpans(t *testing.T) {
+	tests := []struct {
+		s    string
+		text string
+		span Span
+	}{
+		{
+			"# foo",
+			" foo",
+			Span{Start: Point{0, 1, 1}, End: Point{4, 1, 5}},
+		},
+		{
+			"#",
+			"",
+			Span{Start: Point{0, 1, 1}, End: Point{0, 1, 1}},
+		},
+		{
+			"a 1 # é",
+			" é",
+			Span{Start: Point{4, 1, 5}, End: Point{6, 1, 7}},
+		},
+		{
+			"# été\n",
+			" été",
+			Span{Start: Point{0, 1, 1}, End: Point{5, 1, 5}},
+		},
+		{
+			"# 日本 \r\n",
+			" 日本",
+			Span{Start: Point{0, 1, 1}, End: Point{8, 1, 5}},
+		},
+	}
+
+	for _, test := range tests {
+		tokenizer := newTokenizer([]byte(test.s), "test")
+
+		var token *Token
+		for {
+			token = tokenizer.readToken()
+			if token == nil || token.Type == TokenTypeComment {
+				break
+			}
+		}
+
+		if token == nil {
+			t.Errorf("%q: no comment token found", test.s)
+			continue
+		}
+
+		if text := token.Value.(string); text != test.text {
+			t.Errorf("%q: comment text is %q but should be %q",
+				test.s, text, test.text)
+		}
+
+		span := token.Span
+		span.Source = ""
+
+		if span != test.span {
+			t.Errorf("%q: comment span is %#v but should be %#v",
+				test.s, span, test.span)
+		}
+	}
+}
+
+func testTokenize(s string) (tokens []*Token, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			var ok bool
+			if err, ok = v.(error); !ok {
+				panic(v)
+			}
+		}
+	}()
+
+	tokenizer := newTokenizer([]byte(s), "test")
+
+	for {
+		token := tokenizer.readToken()
+		if token == nil {
+			break
+		}
+
+		tokens = append(tokens, token)
+	}
+
+	return
+}
+
+func TestTokenizerStrings(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected string
+	}{
+		{`"a\tb\n\"c\"\\"`, "a\tb\n\"c\"\\"},
+		{"`a\\tb`", `a\tb`},
+		{"`a\"b`", `a"b`},
+		{"`a\nb\r\nc`", "a\nb\nc"},
+		{"`\tx`", "\tx"},
+		{"``", ""},
+		{"\"\"\"\nabc\n\"\"\"", "abc"},
+		{"\"\"\"   \n  a\n    b\n  \"\"\"", "a\n  b"},
+		{"\"\"\"\n\ta\n\n\tb\n\t\"\"\"", "a\n\nb"},
+		{"\"\"\"\n  a\n  \n  b\n  \"\"\"", "a\n\nb"},
+		{"\"\"\"\r\n  a\r\n  b\r\n  \"\"\"", "a\nb"},
+		{"\"\"\"\n  a \\\"\"\" \\t b\n  \"\"\"", "a \"\"\" \t b"},
+		{"\"\"\"\n  \"quoted\"\n  \"\"\"", `"quoted"`},
+		{"\"\"\"\n\"\"\"", ""},
+		{"\"\"\"\n\n\"\"\"", ""},
+		{"\"\"\"\n\n\n\"\"\"", "\n"},
+		{"~re`a\\d+`", `a\d+`},
+		{"~x\"\"\"\n  a\n  \"\"\"", "a"},
+	}
+
+	for _, test := range tests {
+		tokens, err := testTokenize(test.s)
+		if err != nil {
+			t.Errorf("cannot tokenize %q: %v", test.s, err)
+			continue
+		}
+
+		if len(tokens) != 1 || tokens[0].Type != TokenTypeString {
+			t.Errorf("%q was not read as a single string token", test.s)
+			continue
+		}
+
+		if s := tokens[0].Value.(String).String; s != test.expected {
+			t.Errorf("%q was read as %q instead of %q", test.s, s,
+				test.expected)
+		}
+
+		if data := tokens[0].Data; data != test.s {
+			t.Errorf("%q has token data %q", test.s, data)
+		}
+	}
+}
+
+func TestTokenizerStringsInvalid(t *testing.T) {
+	tests := []string{
+		"\"a\nb\"",
+		"\"a",
+		"`a",
+		"`a\x01`",
+		"\"\"\"a\n\"\"\"",
+		"\"\"\"\na\n",
+		"\"\"\"\n    a\n  b\n    \"\"\"",
+		"\"\"\"\n  \\q\n  \"\"\"",
+		"\"\"\"\n  a\x01\n  \"\"\"",
+	}
+
+	for _, s := range tests {
+		if _, err := testTokenize(s); err == nil {
+			t.Errorf("tokenizing %q should have failed", s)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package bcl
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Keys of TOML tables are always sorted when exporting a document; the order
+// of keys is preserved when importing a document.
+func ExportTOML(doc *Document) ([]byte, []ConversionWarning, error) {
+	var c converter
+
+	table := c.tomlTable(c.exportDocument(doc), nil)
+
+	var buf bytes.Buffer
+
+	encoder := toml.NewEncoder(&buf)
+	encoder.Indent = ""
+
+	if err := encoder.Encode(table); err != nil {
+		return nil, nil, err
+	}
+
+	return buf.Bytes(), c.Warnings, nil
+}
+
+func ImportTOML(data []byte, source string) (*Document, []ConversionWarning, error) {
+	var table map[string]any
+
+	md, err := toml.Decode(string(data), &table)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyOrder := make(map[string]int)
+	for i, key := range md.Keys() {
+		keyOrder[key.String()] = i
+	}
+
+	var c converter
+
+	m := c.tomlMap(table, nil, keyOrder)
+
+	return c.importDocument(m, source), c.Warnings, nil
+}
+
+func (c *converter) tomlTable(m convMap, path []string) map[string]any {
+	table := make(map[string]any, len(m))
+
+	for _, item := range m {
+		itemPath := childPath(path, item.Key)
+
+		if value, ok := c.tomlValue(item.Value, itemPath); ok {
+			table[item.Key] = value
+		}
+	}
+
+	return table
+}
+
+func (c *converter) tomlValue(v any, path []string) (any, bool) {
+	switch v := v.(type) {
+	case convMap:
+		return c.tomlTable(v, path), true
+
+	case []any:
+		// Sequences of mappings must be typed as such to be encoded as arrays
+		// of tables.
+		if len(v) > 0 && !slices.ContainsFunc(v, func(v2 any) bool {
+			_, ok := v2.(convMap)
+			return !ok
+		}) {
+			tables := make([]map[string]any, len(v))
+			for i, v2 := range v {
+				tables[i] = c.tomlTable(v2.(convMap), path)
+			}
+
+			return tables, true
+		}
+
+		values := make([]any, 0, len(v))
+		for _, v2 := range v {
+			if value, ok := c.tomlValue(v2, path); ok {
+				values = append(values, value)
+			}
+		}
+
+		return values, true
+
+	case nil:
+		c.warn(path, "null value ignored")
+		return nil, false
+
+	default:
+		return v, true
+	}
+}
+
+func (c *converter) tomlMap(table map[string]any, key toml.Key, keyOrder map[string]int) convMap {
+	keys := make([]string, 0, len(table))
+	for k := range table {
+		keys = append(keys, k)
+	}
+
+	keyIndex := func(k string) int {
+		if i, found := keyOrder[append(slices.Clone(key), k).String()]; found {
+			return i
+		}
+
+		return len(keyOrder)
+	}
+
+	slices.SortStableFunc(keys, func(k1, k2 string) int {
+		i1, i2 := keyIndex(k1), keyIndex(k2)
+
+		switch {
+		case i1 < i2:
+			return -1
+		case i1 > i2:
+			return 1
+		case k1 < k2:
+			return -1
+		case k1 > k2:
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	m := make(convMap, len(keys))
+
+	for i, k := range keys {
+		childKey := append(slices.Clone(key), k)
+		m[i] = convMapItem{Key: k, Value: c.tomlToConv(table[k], childKey,
+			keyOrder)}
+	}
+
+	return m
+}
+
+func (c *converter) tomlToConv(v any, key toml.Key, keyOrder map[string]int) any {
+	switch v := v.(type) {
+	case map[string]any:
+		return c.tomlMap(v, key, keyOrder)
+
+	case []map[string]any:
+		seq := make([]any, len(v))
+		for i, table := range v {
+			seq[i] = c.tomlMap(table, key, keyOrder)
+		}
+
+		return seq
+
+	case []any:
+		seq := make([]any, len(v))
+		for i, v2 := range v {
+			seq[i] = c.tomlToConv(v2, key, keyOrder)
+		}
+
+		return seq
+
+	case int64, float64, string, bool, time.Time:
+		return v
+
+	default:
+		c.warn(key, "value of type %T converted to a string", v)
+		return fmt.Sprintf("%v", v)
+	}
+}
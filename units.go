@@ -0,0 +1,297 @@
+package bcl
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A number of bytes. Sizes are written as strings with the "size" sigil, e.g.
+// `~size"512MiB"`, or as integers counting bytes.
+type ByteSize int64
+
+const (
+	Byte ByteSize = 1
+
+	Kilobyte ByteSize = 1000 * Byte
+	Megabyte ByteSize = 1000 * Kilobyte
+	Gigabyte ByteSize = 1000 * Megabyte
+	Terabyte ByteSize = 1000 * Gigabyte
+	Petabyte ByteSize = 1000 * Terabyte
+
+	Kibibyte ByteSize = 1024 * Byte
+	Mebibyte ByteSize = 1024 * Kibibyte
+	Gibibyte ByteSize = 1024 * Mebibyte
+	Tebibyte ByteSize = 1024 * Gibibyte
+	Pebibyte ByteSize = 1024 * Tebibyte
+)
+
+var byteSizeUnits = map[string]ByteSize{
+	"B": Byte,
+
+	"kB": Kilobyte,
+	"KB": Kilobyte,
+	"MB": Megabyte,
+	"GB": Gigabyte,
+	"TB": Terabyte,
+	"PB": Petabyte,
+
+	"KiB": Kibibyte,
+	"MiB": Mebibyte,
+	"GiB": Gibibyte,
+	"TiB": Tebibyte,
+	"PiB": Pebibyte,
+}
+
+// Parse a size written as a number, possibly with a fractional part,
+// followed by an optional unit, e.g. "512MiB" or "1.5 GB". Sizes without
+// unit are counted in bytes.
+func ParseByteSize(s string) (ByteSize, error) {
+	numString := strings.TrimRightFunc(s, func(c rune) bool {
+		return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+	})
+
+	unitString := s[len(numString):]
+	numString = strings.TrimRight(numString, " ")
+
+	unit := Byte
+	if unitString != "" {
+		var found bool
+		unit, found = byteSizeUnits[unitString]
+		if !found {
+			return 0, fmt.Errorf("unknown size unit %q", unitString)
+		}
+	}
+
+	if i, err := strconv.ParseInt(numString, 10, 64); err == nil {
+		if i < 0 {
+			return 0, errors.New("invalid negative size")
+		}
+
+		if i > math.MaxInt64/int64(unit) {
+			return 0, errors.New("size is too large")
+		}
+
+		return ByteSize(i) * unit, nil
+	}
+
+	f, err := strconv.ParseFloat(numString, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	if f < 0.0 {
+		return 0, errors.New("invalid negative size")
+	}
+
+	size := f * float64(unit)
+	if size >= math.MaxInt64 {
+		return 0, errors.New("size is too large")
+	}
+
+	return ByteSize(size), nil
+}
+
+// Format the size using the largest binary unit the size is a multiple of,
+// e.g. "512MiB".
+func (s ByteSize) String() string {
+	units := []struct {
+		size ByteSize
+		name string
+	}{
+		{Pebibyte, "PiB"},
+		{Tebibyte, "TiB"},
+		{Gibibyte, "GiB"},
+		{Mebibyte, "MiB"},
+		{Kibibyte, "KiB"},
+	}
+
+	for _, unit := range units {
+		if s != 0 && s%unit.size == 0 {
+			return strconv.FormatInt(int64(s/unit.size), 10) + unit.name
+		}
+	}
+
+	return strconv.FormatInt(int64(s), 10) + "B"
+}
+
+func (s *ByteSize) ReadBCLValue(v *Value) error {
+	switch content := v.Content.(type) {
+	case int64:
+		if content < 0 {
+			return errors.New("invalid negative size")
+		}
+
+		*s = ByteSize(content)
+
+	case *big.Int:
+		if content.Sign() < 0 {
+			return errors.New("invalid negative size")
+		}
+
+		return NewMaxIntegerValueError(math.MaxInt64)
+
+	case String:
+		if content.Sigil != "" && content.Sigil != "size" {
+			return fmt.Errorf("invalid string sigil %q for size", content.Sigil)
+		}
+
+		size, err := ParseByteSize(content.String)
+		if err != nil {
+			return err
+		}
+
+		*s = size
+
+	default:
+		return NewValueTypeError(v, ValueTypeString, ValueTypeInteger)
+	}
+
+	return nil
+}
+
+// A percentage, e.g. 10 for 10%. Percentages are written as strings with the
+// "pct" sigil, e.g. `~pct"10%"`, or as integers or floats.
+type Percentage float64
+
+// Parse a percentage written as a number followed by an optional '%'
+// character, e.g. "12.5%".
+func ParsePercentage(s string) (Percentage, error) {
+	numString := strings.TrimRight(strings.TrimSuffix(s, "%"), " ")
+
+	f, err := strconv.ParseFloat(numString, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("invalid percentage %q", s)
+	}
+
+	return Percentage(f), nil
+}
+
+func (p Percentage) String() string {
+	return strconv.FormatFloat(float64(p), 'f', -1, 64) + "%"
+}
+
+// Return the percentage as a fraction, e.g. 0.1 for 10%.
+func (p Percentage) Fraction() float64 {
+	return float64(p) / 100.0
+}
+
+func (p *Percentage) ReadBCLValue(v *Value) error {
+	switch content := v.Content.(type) {
+	case int64:
+		*p = Percentage(content)
+
+	case *big.Int:
+		f, _ := new(big.Float).SetInt(content).Float64()
+		*p = Percentage(f)
+
+	case float64:
+		*p = Percentage(content)
+
+	case String:
+		if content.Sigil != "" && content.Sigil != "pct" {
+			return fmt.Errorf("invalid string sigil %q for percentage",
+				content.Sigil)
+		}
+
+		pct, err := ParsePercentage(content.String)
+		if err != nil {
+			return err
+		}
+
+		*p = pct
+
+	default:
+		return NewValueTypeError(v, ValueTypeString, ValueTypeInteger,
+			ValueTypeFloat)
+	}
+
+	return nil
+}
+
+func decodeByteSizeSigil(s string) (any, error) {
+	return ParseByteSize(s)
+}
+
+func decodePercentageSigil(s string) (any, error) {
+	return ParsePercentage(s)
+}
+
+type MinMaxDurationValueError struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+func (err *MinMaxDurationValueError) Error() string {
+	return fmt.Sprintf("duration must be between %v and %v", err.Min, err.Max)
+}
+
+type MinMaxByteSizeValueError struct {
+	Min ByteSize
+	Max ByteSize
+}
+
+func (err *MinMaxByteSizeValueError) Error() string {
+	return fmt.Sprintf("size must be between %v and %v", err.Min, err.Max)
+}
+
+type MinMaxPercentageValueError struct {
+	Min Percentage
+	Max Percentage
+}
+
+func (err *MinMaxPercentageValueError) Error() string {
+	return fmt.Sprintf("percentage must be between %v and %v", err.Min, err.Max)
+}
+
+// Return a validation function for WithValueValidation checking that a
+// duration is between min and max (inclusive).
+func ValidateDurationRange(min, max time.Duration) ValueValidationFunc {
+	return func(v any) error {
+		return validateRange(v, min, max, &MinMaxDurationValueError{Min: min, Max: max})
+	}
+}
+
+// Return a validation function for WithValueValidation checking that a size
+// is between min and max (inclusive).
+func ValidateByteSizeRange(min, max ByteSize) ValueValidationFunc {
+	return func(v any) error {
+		return validateRange(v, min, max, &MinMaxByteSizeValueError{Min: min, Max: max})
+	}
+}
+
+// Return a validation function for WithValueValidation checking that a
+// percentage is between min and max (inclusive).
+func ValidatePercentageRange(min, max Percentage) ValueValidationFunc {
+	return func(v any) error {
+		return validateRange(v, min, max, &MinMaxPercentageValueError{Min: min, Max: max})
+	}
+}
+
+// Check that a value is between min and max (inclusive). Pointers, used for
+// optional values, are dereferenced; nil pointers are always valid.
+func validateRange[T ~int64 | ~float64](v any, min, max T, rangeErr error) error {
+	var x T
+
+	switch tv := v.(type) {
+	case T:
+		x = tv
+	case *T:
+		if tv == nil {
+			return nil
+		}
+		x = *tv
+	default:
+		return fmt.Errorf("invalid value of type %T, expected %T", v, x)
+	}
+
+	if x < min || x > max {
+		return rangeErr
+	}
+
+	return nil
+}
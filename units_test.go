@@ -0,0 +1,276 @@
+package bcl
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		s    string
+		size ByteSize
+	}{
+		{"0", 0},
+		{"42", 42},
+		{"42B", 42},
+		{"1kB", 1000},
+		{"1KB", 1000},
+		{"2KiB", 2048},
+		{"1.5 GB", 1_500_000_000},
+		{"512MiB", 512 * Mebibyte},
+		{"0.5KiB", 512},
+		{"8PiB", 8 * Pebibyte},
+	}
+
+	for _, test := range tests {
+		size, err := ParseByteSize(test.s)
+		if err != nil {
+			t.Errorf("cannot parse %q: %v", test.s, err)
+			continue
+		}
+
+		if size != test.size {
+			t.Errorf("%q was parsed as %d instead of %d", test.s, size,
+				test.size)
+		}
+	}
+}
+
+func TestParseByteSizeInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"KiB",
+		"-1",
+		"-1.5MB",
+		"1 kib",
+		"1XB",
+		"1.2.3",
+		"NaN",
+		"9223372036854775807KB",
+		"10000PiB",
+	}
+
+	for _, s := range tests {
+		if _, err := ParseByteSize(s); err == nil {
+			t.Errorf("parsing %q should have failed", s)
+		}
+	}
+}
+
+func TestByteSizeString(t *testing.T) {
+	tests := []struct {
+		size ByteSize
+		s    string
+	}{
+		{0, "0B"},
+		{1000, "1000B"},
+		{1024, "1KiB"},
+		{1536, "1536B"},
+		{3 * Gibibyte, "3GiB"},
+		{2 * Pebibyte, "2PiB"},
+	}
+
+	for _, test := range tests {
+		if s := test.size.String(); s != test.s {
+			t.Errorf("size %d was formatted as %q instead of %q",
+				int64(test.size), s, test.s)
+		}
+
+		size, err := ParseByteSize(test.s)
+		if err != nil || size != test.size {
+			t.Errorf("%q was parsed back as %d (%v)", test.s, size, err)
+		}
+	}
+}
+
+func TestParsePercentage(t *testing.T) {
+	tests := []struct {
+		s   string
+		pct Percentage
+	}{
+		{"0", 0},
+		{"10%", 10},
+		{"12.5 %", 12.5},
+		{"-5%", -5},
+		{"150", 150},
+	}
+
+	for _, test := range tests {
+		pct, err := ParsePercentage(test.s)
+		if err != nil {
+			t.Errorf("cannot parse %q: %v", test.s, err)
+			continue
+		}
+
+		if pct != test.pct {
+			t.Errorf("%q was parsed as %v instead of %v", test.s, pct,
+				test.pct)
+		}
+	}
+
+	for _, s := range []string{"", "%", "a%", "10%%", "Inf%"} {
+		if _, err := ParsePercentage(s); err == nil {
+			t.Errorf("parsing %q should have failed", s)
+		}
+	}
+
+	if s := Percentage(12.5).String(); s != "12.5%" {
+		t.Errorf("percentage was formatted as %q", s)
+	}
+
+	if f := Percentage(25).Fraction(); f != 0.25 {
+		t.Errorf("fraction is %v", f)
+	}
+}
+
+func TestExtractUnits(t *testing.T) {
+	var values struct {
+		Size       ByteSize
+		SizeInt    ByteSize
+		Pct        Percentage
+		PctFloat   Percentage
+		PctInt     Percentage
+		Duration   time.Duration
+		DurationS  time.Duration
+		DurationF  time.Duration
+		OptSize    *ByteSize
+		OptMissing *ByteSize
+	}
+
+	s := `
+size ~size"4KiB"
+size_int 512
+pct ~pct"10%"
+pct_float 2.5
+pct_int 20
+duration "1h30m"
+duration_s 90
+duration_f 0.5
+opt_size "1MB"
+`
+
+	if err := Unmarshal([]byte(s), "test", &values); err != nil {
+		t.Fatalf("cannot unmarshal document: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		value    any
+		expected any
+	}{
+		{"size", values.Size, 4 * Kibibyte},
+		{"size_int", values.SizeInt, ByteSize(512)},
+		{"pct", values.Pct, Percentage(10)},
+		{"pct_float", values.PctFloat, Percentage(2.5)},
+		{"pct_int", values.PctInt, Percentage(20)},
+		{"duration", values.Duration, 90 * time.Minute},
+		{"duration_s", values.DurationS, 90 * time.Second},
+		{"duration_f", values.DurationF, 500 * time.Millisecond},
+		{"opt_missing", values.OptMissing == nil, true},
+	}
+
+	for _, test := range tests {
+		if test.value != test.expected {
+			t.Errorf("%s was extracted as %v instead of %v", test.name,
+				test.value, test.expected)
+		}
+	}
+
+	if values.OptSize == nil || *values.OptSize != Megabyte {
+		t.Errorf("opt_size was extracted as %v", values.OptSize)
+	}
+}
+
+func TestExtractUnitsInvalid(t *testing.T) {
+	tests := []struct {
+		s    string
+		dest any
+	}{
+		{`-1`, new(ByteSize)},
+		{`99999999999999999999`, new(ByteSize)},
+		{`~pct"10%"`, new(ByteSize)},
+		{`1.5`, new(ByteSize)},
+		{`"1 XB"`, new(ByteSize)},
+		{`~size"1KiB"`, new(Percentage)},
+		{`true`, new(Percentage)},
+		{`"x"`, new(time.Duration)},
+		{`"-1s"`, new(time.Duration)},
+	}
+
+	for _, test := range tests {
+		value, err := ParseValue(test.s)
+		if err != nil {
+			t.Fatalf("cannot parse value %q: %v", test.s, err)
+		}
+
+		if err := value.Extract(test.dest); err == nil {
+			t.Errorf("extracting %s to %T should have failed", test.s,
+				test.dest)
+		}
+	}
+}
+
+func TestValidateUnitRanges(t *testing.T) {
+	size := 2 * Kibibyte
+	var nilSize *ByteSize
+
+	tests := []struct {
+		fn    ValueValidationFunc
+		value any
+		err   error
+	}{
+		{ValidateDurationRange(time.Second, time.Minute), time.Second, nil},
+		{ValidateDurationRange(time.Second, time.Minute), time.Minute, nil},
+		{ValidateDurationRange(time.Second, time.Minute), time.Hour,
+			&MinMaxDurationValueError{}},
+		{ValidateByteSizeRange(Kibibyte, Mebibyte), size, nil},
+		{ValidateByteSizeRange(Kibibyte, Mebibyte), &size, nil},
+		{ValidateByteSizeRange(Kibibyte, Mebibyte), nilSize, nil},
+		{ValidateByteSizeRange(Kibibyte, Mebibyte), Byte,
+			&MinMaxByteSizeValueError{}},
+		{ValidatePercentageRange(0, 100), Percentage(50), nil},
+		{ValidatePercentageRange(0, 100), Percentage(-1),
+			&MinMaxPercentageValueError{}},
+		{ValidatePercentageRange(0, 100), 50.0, errors.New("invalid value")},
+	}
+
+	for i, test := range tests {
+		err := test.fn(test.value)
+
+		switch test.err.(type) {
+		case nil:
+			if err != nil {
+				t.Errorf("test %d: validation of %v failed: %v", i, test.value,
+					err)
+			}
+
+		case *MinMaxDurationValueError:
+			var rerr *MinMaxDurationValueError
+			if !errors.As(err, &rerr) {
+				t.Errorf("test %d: validation of %v returned %v", i,
+					test.value, err)
+			}
+
+		case *MinMaxByteSizeValueError:
+			var rerr *MinMaxByteSizeValueError
+			if !errors.As(err, &rerr) {
+				t.Errorf("test %d: validation of %v returned %v", i,
+					test.value, err)
+			}
+
+		case *MinMaxPercentageValueError:
+			var rerr *MinMaxPercentageValueError
+			if !errors.As(err, &rerr) {
+				t.Errorf("test %d: validation of %v returned %v", i,
+					test.value, err)
+			}
+
+		default:
+			if err == nil {
+				t.Errorf("test %d: validation of %v should have failed", i,
+					test.value)
+			}
+		}
+	}
+}
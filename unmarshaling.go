@@ -0,0 +1,327 @@
+package bcl
+
+import (
+	"encoding"
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+type fieldKind string
+
+const (
+	fieldKindBlock fieldKind = "block"
+	fieldKindEntry fieldKind = "entry"
+	fieldKindName  fieldKind = "name"
+)
+
+type structField struct {
+	Index     []int
+	Name      string
+	Kind      fieldKind
+	Required  bool
+	OmitEmpty bool
+}
+
+var valueStructTypes = []reflect.Type{
+	reflect.TypeFor[String](),
+	reflect.TypeFor[regexp.Regexp](),
+	reflect.TypeFor[url.URL](),
+}
+
+var (
+	elementReaderType   = reflect.TypeFor[ElementReader]()
+	valueReaderType     = reflect.TypeFor[ValueReader]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// Struct fields are mapped to BCL elements using the "bcl" tag. The tag
+// contains the name of the element followed by a comma-separated list of
+// options:
+//
+//   - "block": the field is read from one or more blocks.
+//   - "entry": the field is read from the values of an entry.
+//   - "required": the element must be present.
+//   - "omitempty": the field is not written when it has a zero value.
+//   - "name": the field contains the name of the block (it is not associated
+//     with a child element).
+//
+// If the name is empty, it is derived from the name of the field, e.g.
+// "ListenAddress" is mapped to "listen_address". A tag value of "-" means
+// that the field is ignored.
+//
+// If neither "block" nor "entry" is set, the element type is inferred from
+// the type of the field: structures (and pointers and slices of structures)
+// and maps are read from blocks, everything else from entries. Maps are
+// filled with the children of the block as with Element.Map. Structures and
+// maps can also be read from entries containing a single inline map, e.g.
+// `headers {accept = "text/plain"}`.
+func Unmarshal(data []byte, source string, dest any) error {
+	doc, err := Parse(data, source)
+	if err != nil {
+		return err
+	}
+
+	doc.TopLevel.Decode(dest)
+
+	if errs := doc.ValidationErrors(); errs != nil {
+		return errs
+	}
+
+	return nil
+}
+
+func (elt *Element) Decode(dest any) bool {
+	dv := reflect.ValueOf(dest)
+	if dv.Kind() != reflect.Pointer || dv.Elem().Kind() != reflect.Struct {
+		panic(fmt.Sprintf("cannot decode element to a value of type %T", dest))
+	}
+
+	if elt.CheckTypeBlock() == nil {
+		return false
+	}
+
+	valid := true
+
+	for _, field := range structFields(dv.Elem().Type()) {
+		fv := dv.Elem().FieldByIndex(field.Index)
+
+		if !elt.decodeField(field, fv.Addr().Interface()) {
+			valid = false
+		}
+	}
+
+	return valid
+}
+
+func (elt *Element) decodeField(field *structField, dest any) bool {
+	switch field.Kind {
+	case fieldKindName:
+		var name string
+		if field.Required {
+			name = elt.BlockName()
+		} else {
+			name = elt.Content.(*Block).Name
+		}
+
+		dv := reflect.ValueOf(dest).Elem()
+		if dv.Kind() != reflect.String {
+			panic(fmt.Sprintf("cannot decode block name to a value of type %T",
+				dest))
+		}
+
+		dv.SetString(name)
+		return true
+
+	case fieldKindEntry:
+		var child *Element
+		if field.Required {
+			child = elt.MustFindEntry(field.Name)
+		} else {
+			child = elt.FindEntry(field.Name)
+		}
+
+		if child == nil {
+			return !field.Required
+		}
+
+		return child.Values(dest)
+
+	case fieldKindBlock:
+		blocks := elt.findBlocksOrInlineMaps(field.Name)
+
+		if reflect.TypeOf(dest).Elem().Kind() == reflect.Slice {
+			if len(blocks) == 0 {
+				if field.Required {
+					elt.AddMissingElementError(ref(ElementTypeBlock),
+						[]string{field.Name})
+					return false
+				}
+
+				return true
+			}
+
+			return extractElements(blocks, dest)
+		}
+
+		if len(blocks) == 0 {
+			if field.Required {
+				elt.AddMissingElementError(ref(ElementTypeBlock),
+					[]string{field.Name})
+				return false
+			}
+
+			return true
+		}
+
+		for _, block := range blocks[1:] {
+			block.readStatus = ElementReadStatusIgnored
+		}
+
+		if err := blocks[0].Extract(dest); err != nil {
+			blocks[0].AddValidationError(err)
+			return false
+		}
+
+		return true
+
+	default:
+		panic(fmt.Sprintf("unhandled field kind %q", field.Kind))
+	}
+}
+
+func structFields(t reflect.Type) []*structField {
+	var fields []*structField
+
+	for i := range t.NumField() {
+		sf := t.Field(i)
+
+		tag, hasTag := sf.Tag.Lookup("bcl")
+		if tag == "-" {
+			continue
+		}
+
+		if sf.Anonymous && !hasTag && sf.Type.Kind() == reflect.Struct {
+			for _, field := range structFields(sf.Type) {
+				field.Index = append([]int{i}, field.Index...)
+				fields = append(fields, field)
+			}
+
+			continue
+		}
+
+		if !sf.IsExported() {
+			continue
+		}
+
+		field := parseStructFieldTag(sf, tag)
+		field.Index = []int{i}
+
+		fields = append(fields, field)
+	}
+
+	return fields
+}
+
+func parseStructFieldTag(sf reflect.StructField, tag string) *structField {
+	parts := strings.Split(tag, ",")
+
+	field := structField{
+		Name: parts[0],
+	}
+
+	if field.Name == "" {
+		field.Name = fieldNameToSymbol(sf.Name)
+	}
+
+	for _, option := range parts[1:] {
+		switch option {
+		case "block":
+			field.Kind = fieldKindBlock
+		case "entry":
+			field.Kind = fieldKindEntry
+		case "name":
+			field.Kind = fieldKindName
+		case "required":
+			field.Required = true
+		case "omitempty":
+			field.OmitEmpty = true
+		default:
+			panic(fmt.Sprintf("invalid option %q in bcl tag of field %q",
+				option, sf.Name))
+		}
+	}
+
+	if field.Kind == "" {
+		if isBlockType(sf.Type) {
+			field.Kind = fieldKindBlock
+		} else {
+			field.Kind = fieldKindEntry
+		}
+	}
+
+	return &field
+}
+
+func isBlockType(t reflect.Type) bool {
+	if t.Implements(elementReaderType) ||
+		reflect.PointerTo(t).Implements(elementReaderType) {
+		return true
+	}
+
+	if t.Implements(valueReaderType) ||
+		reflect.PointerTo(t).Implements(valueReaderType) ||
+		reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return false
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice:
+		return isBlockType(t.Elem())
+	case reflect.Map:
+		return true
+	case reflect.Struct:
+		for _, vt := range valueStructTypes {
+			if t == vt {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// Return whether a slice type is extracted from a single value instead of
+// being filled with all values of an entry.
+func isValueSliceType(t reflect.Type) bool {
+	return t.Elem().Kind() == reflect.Uint8 ||
+		reflect.PointerTo(t).Implements(valueReaderType) ||
+		reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+// Return whether a type is a slice extracted from a list value, possibly
+// through pointers.
+func isListSliceType(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	return t.Kind() == reflect.Slice && !isValueSliceType(t)
+}
+
+func fieldNameToSymbol(name string) string {
+	// "ListenAddress" -> "listen_address", "HTTPServer" -> "http_server",
+	// "IPv6" -> "ipv6", "UserIDs" -> "user_ids"; an uppercase letter ends a
+	// sequence of uppercase letters only if it starts a word of at least two
+	// lowercase letters.
+
+	runes := []rune(name)
+
+	var buf strings.Builder
+
+	for i, c := range runes {
+		if unicode.IsUpper(c) {
+			if i > 0 {
+				prev := runes[i-1]
+				startsWord := i+2 < len(runes) &&
+					unicode.IsLower(runes[i+1]) && unicode.IsLower(runes[i+2])
+
+				if unicode.IsLower(prev) || unicode.IsDigit(prev) ||
+					(unicode.IsUpper(prev) && startsWord) {
+					buf.WriteRune('_')
+				}
+			}
+
+			buf.WriteRune(unicode.ToLower(c))
+		} else {
+			buf.WriteRune(c)
+		}
+	}
+
+	return buf.String()
+}
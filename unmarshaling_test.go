@@ -0,0 +1,167 @@
+package bcl
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFieldNameToSymbol(t *testing.T) {
+	tests := []struct {
+		name   string
+		symbol string
+	}{
+		{"Name", "name"},
+		{"ListenAddress", "listen_address"},
+		{"HTTPServer", "http_server"},
+		{"ServerHTTP", "server_http"},
+		{"IPv6", "ipv6"},
+		{"UserIDs", "user_ids"},
+		{"XMLHttpRequest", "xml_http_request"},
+		{"ID", "id"},
+		{"Port2Value", "port2_value"},
+		{"x", "x"},
+	}
+
+	for _, test := range tests {
+		if symbol := fieldNameToSymbol(test.name); symbol != test.symbol {
+			t.Errorf("field name %q was converted to %q but should have been "+
+				"converted to %q", test.name, symbol, test.symbol)
+		}
+	}
+}
+
+type unmarshalingTestUser struct {
+	Name  string   `bcl:",name"`
+	Email string   `bcl:"email,required"`
+	Roles []string `bcl:"roles"`
+}
+
+type unmarshalingTestConfig struct {
+	LogLevel      string                 `bcl:"log_level,required"`
+	ListenAddress string                 `bcl:""`
+	Debug         bool                   `bcl:"debug"`
+	Workers       int                    `bcl:"workers"`
+	Ratio         float64                `bcl:"ratio"`
+	Ignored       string                 `bcl:"-"`
+	Users         []unmarshalingTestUser `bcl:"user"`
+	Admin         *unmarshalingTestUser  `bcl:"admin"`
+	unmarshalingTestEmbedded
+}
+
+type unmarshalingTestEmbedded struct {
+	Extra string `bcl:"extra"`
+}
+
+func TestUnmarshal(t *testing.T) {
+	tests := []struct {
+		s     string
+		value unmarshalingTestConfig
+	}{
+		{
+			`log_level "info"`,
+			unmarshalingTestConfig{
+				LogLevel: "info",
+			},
+		},
+		{
+			`
+log_level "debug"
+listen_address "localhost:80"
+debug true
+workers 4
+ratio 0.5
+extra "x"
+`,
+			unmarshalingTestConfig{
+				LogLevel:                 "debug",
+				ListenAddress:            "localhost:80",
+				Debug:                    true,
+				Workers:                  4,
+				Ratio:                    0.5,
+				unmarshalingTestEmbedded: unmarshalingTestEmbedded{Extra: "x"},
+			},
+		},
+		{
+			`
+log_level "info"
+
+user "bob" {
+  email "bob@example.com"
+  roles "admin" "dev"
+}
+
+user "alice" {
+  email "alice@example.com"
+}
+
+admin "root" {
+  email "root@example.com"
+}
+`,
+			unmarshalingTestConfig{
+				LogLevel: "info",
+				Users: []unmarshalingTestUser{
+					{
+						Name:  "bob",
+						Email: "bob@example.com",
+						Roles: []string{"admin", "dev"},
+					},
+					{
+						Name:  "alice",
+						Email: "alice@example.com",
+					},
+				},
+				Admin: &unmarshalingTestUser{
+					Name:  "root",
+					Email: "root@example.com",
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		var value unmarshalingTestConfig
+
+		if err := Unmarshal([]byte(test.s), "test", &value); err != nil {
+			t.Errorf("cannot unmarshal %q: %v", test.s, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(value, test.value) {
+			t.Errorf("%q was unmarshaled as %#v but should have been "+
+				"unmarshaled as %#v", test.s, value, test.value)
+		}
+	}
+}
+
+func TestUnmarshalInvalid(t *testing.T) {
+	tests := []string{
+		// Syntax error
+		`log_level "info`,
+
+		// Missing required entry
+		`workers 4`,
+
+		// Missing required entry in a block
+		"log_level \"info\"\nuser \"bob\" {}",
+
+		// Invalid value types
+		"log_level 42",
+		"log_level \"info\"\nworkers \"4\"",
+		"log_level \"info\"\ndebug 1",
+
+		// Invalid number of values
+		"log_level \"info\" \"debug\"",
+
+		// Block instead of entry
+		"log_level {}",
+	}
+
+	for _, s := range tests {
+		var value unmarshalingTestConfig
+
+		if err := Unmarshal([]byte(s), "test", &value); err == nil {
+			t.Errorf("unmarshaling %q should have failed", s)
+		}
+	}
+}
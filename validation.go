@@ -4,6 +4,9 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"maps"
+	"math/big"
+	"slices"
 	"strconv"
 	"strings"
 )
@@ -14,8 +17,8 @@ type ValidationError struct {
 }
 
 type ValidationErrors struct {
-	Errs  []ValidationError
-	Lines []string
+	Errs []ValidationError
+	SourceLines
 }
 
 func (errs *ValidationErrors) Error() string {
@@ -27,13 +30,29 @@ func (errs *ValidationErrors) Error() string {
 		buf.WriteByte('\n')
 
 		if err.Location != nil {
-			err.Location.PrintSource(&buf, errs.Lines, "      ")
+			lines := errs.spanLines(err.Location)
+			err.Location.PrintSource(&buf, lines, "      ")
 		}
 	}
 
 	return strings.TrimRight(buf.String(), "\n")
 }
 
+func (doc *Document) resetValidationErrors() {
+	var reset func(*Element)
+	reset = func(elt *Element) {
+		elt.validationErrors = nil
+
+		if block, ok := elt.Content.(*Block); ok {
+			for _, child := range block.Elements {
+				reset(child)
+			}
+		}
+	}
+
+	reset(doc.TopLevel)
+}
+
 func (doc *Document) ValidationErrors() *ValidationErrors {
 	var errs []ValidationError
 
@@ -53,6 +72,11 @@ func (doc *Document) ValidationErrors() *ValidationErrors {
 				verr.Location = &invalidValueErr.Value.Location
 			}
 
+			var unknownMapKeyErr *UnknownMapKeyError
+			if errors.As(eltErr, &unknownMapKeyErr) {
+				verr.Location = &unknownMapKeyErr.Location
+			}
+
 			errs = append(errs, verr)
 		}
 
@@ -87,13 +111,16 @@ func (doc *Document) ValidationErrors() *ValidationErrors {
 	}
 
 	return &ValidationErrors{
-		Errs:  errs,
-		Lines: doc.lines,
+		Errs:        errs,
+		SourceLines: doc.sourceLines,
 	}
 }
 
 func (elt *Element) AddValidationError(err error) error {
-	elt.validationErrors = append(elt.validationErrors, err)
+	if !errors.Is(err, ErrInvalidElement) {
+		elt.validationErrors = append(elt.validationErrors, err)
+	}
+
 	return err
 }
 
@@ -168,6 +195,39 @@ func (elt *Element) AddMissingBlockNameError() error {
 	return elt.AddValidationError(&MissingBlockNameError{})
 }
 
+type UnexpectedBlockNameError struct {
+}
+
+func (err *UnexpectedBlockNameError) Error() string {
+	return "block must not have a name"
+}
+
+func (elt *Element) AddUnexpectedBlockNameError() error {
+	return elt.AddValidationError(&UnexpectedBlockNameError{})
+}
+
+type DuplicateKeyError struct {
+	Key             string
+	PreviousElement *Element
+}
+
+func (err *DuplicateKeyError) Error() string {
+	line := err.PreviousElement.Location.Start.Line
+	if line == 0 {
+		return fmt.Sprintf("duplicate key %q", err.Key)
+	}
+
+	return fmt.Sprintf("duplicate key %q, previous element found line %d",
+		err.Key, line)
+}
+
+func (elt *Element) AddDuplicateKeyError(key string, prevElt *Element) error {
+	return elt.AddValidationError(&DuplicateKeyError{
+		Key:             key,
+		PreviousElement: prevElt,
+	})
+}
+
 type ElementConflictError struct {
 	ElementType  *ElementType
 	ElementNames []string
@@ -291,12 +351,34 @@ func (err *InvalidValueError) Error() string {
 }
 
 func (elt *Element) AddInvalidValueError(v *Value, err error) error {
+	// Errors of list elements already refer to the element concerned
+	if _, ok := err.(*InvalidValueError); ok {
+		return elt.AddValidationError(err)
+	}
+
 	return elt.AddValidationError(&InvalidValueError{
 		Value: v,
 		Err:   err,
 	})
 }
 
+type UnknownMapKeyError struct {
+	Key      string
+	Location Span
+}
+
+func (err *UnknownMapKeyError) Error() string {
+	return fmt.Sprintf("unknown map key %q", err.Key)
+}
+
+type MissingMapKeyError struct {
+	Key string
+}
+
+func (err *MissingMapKeyError) Error() string {
+	return fmt.Sprintf("map must contain a key named %q", err.Key)
+}
+
 type InvalidValueTypeError struct {
 	Type          ValueType
 	ExpectedTypes []ValueType
@@ -307,13 +389,28 @@ func NewValueTypeError(v *Value, expectedTypes ...ValueType) *InvalidValueTypeEr
 }
 
 func (err *InvalidValueTypeError) Error() string {
+	formatType := func(t ValueType) string {
+		if t == ValueTypeNull {
+			return "null"
+		}
+
+		return WordWithArticle(string(t))
+	}
+
 	etWithArticles := make([]string, len(err.ExpectedTypes))
 	for i, et := range err.ExpectedTypes {
-		etWithArticles[i] = WordWithArticle(string(et))
+		etWithArticles[i] = formatType(et)
 	}
 
 	return fmt.Sprintf("value is %s but should be %s",
-		WordWithArticle(string(err.Type)), WordsEnumerationOr(etWithArticles))
+		formatType(err.Type), WordsEnumerationOr(etWithArticles))
+}
+
+type NullValueError struct {
+}
+
+func (err *NullValueError) Error() string {
+	return "value must not be null"
 }
 
 type InvalidValueContentError struct {
@@ -329,14 +426,19 @@ func NewValueContentError(v *Value, expectedContents ...any) *InvalidValueConten
 }
 
 func (err *InvalidValueContentError) Error() string {
-	formatContent := func(content any) string {
+	var formatContent func(any) string
+	formatContent = func(content any) string {
 		switch c := content.(type) {
+		case nil:
+			return "null"
 		case bool:
 			return strconv.FormatBool(c)
 		case int:
 			return strconv.Itoa(c)
 		case int64:
 			return strconv.FormatInt(c, 10)
+		case *big.Int:
+			return c.String()
 		case float64:
 			return strconv.FormatFloat(c, 'f', -1, 64)
 		case string:
@@ -345,6 +447,31 @@ func (err *InvalidValueContentError) Error() string {
 			return strconv.Quote(c.String)
 		case Symbol:
 			return strconv.Quote(string(c))
+		case List:
+			elts := make([]string, len(c))
+			for i, v := range c {
+				elts[i] = formatContent(v.Content)
+			}
+			return "[" + strings.Join(elts, " ") + "]"
+		case []any:
+			elts := make([]string, len(c))
+			for i, v := range c {
+				elts[i] = formatContent(v)
+			}
+			return "[" + strings.Join(elts, " ") + "]"
+		case Map:
+			elts := make([]string, len(c))
+			for i, entry := range c {
+				elts[i] = entry.Key + " = " + formatContent(entry.Value.Content)
+			}
+			return "{" + strings.Join(elts, ", ") + "}"
+		case map[string]any:
+			keys := slices.Sorted(maps.Keys(c))
+			elts := make([]string, len(keys))
+			for i, key := range keys {
+				elts[i] = key + " = " + formatContent(c[key])
+			}
+			return "{" + strings.Join(elts, ", ") + "}"
 		default:
 			panic(fmt.Sprintf("unhandled value content %#v (%T)",
 				content, content))
@@ -386,3 +513,32 @@ type MinMaxIntegerValueError struct {
 func (err *MinMaxIntegerValueError) Error() string {
 	return fmt.Sprintf("integer must be between %d and %d", err.Min, err.Max)
 }
+
+type MinFloatValueError struct {
+	Min float64
+}
+
+func (err *MinFloatValueError) Error() string {
+	return fmt.Sprintf("number must be greater or equal to %s",
+		strconv.FormatFloat(err.Min, 'f', -1, 64))
+}
+
+type MaxFloatValueError struct {
+	Max float64
+}
+
+func (err *MaxFloatValueError) Error() string {
+	return fmt.Sprintf("number must be lower or equal to %s",
+		strconv.FormatFloat(err.Max, 'f', -1, 64))
+}
+
+type MinMaxFloatValueError struct {
+	Min float64
+	Max float64
+}
+
+func (err *MinMaxFloatValueError) Error() string {
+	return fmt.Sprintf("number must be between %s and %s",
+		strconv.FormatFloat(err.Min, 'f', -1, 64),
+		strconv.FormatFloat(err.Max, 'f', -1, 64))
+}
@@ -1,11 +1,16 @@
 package bcl
 
 import (
+	"encoding"
 	"errors"
 	"fmt"
 	"math"
+	"math/big"
+	"net/url"
+	"os"
 	"reflect"
 	"regexp"
+	"strconv"
 	"time"
 )
 
@@ -20,6 +25,63 @@ func (v *Value) Extract(dest any) error {
 		return vr.ReadBCLValue(v)
 	}
 
+	// Null values can be extracted to pointers, slices, maps and interfaces,
+	// which are set to nil; other destinations produce type errors below.
+	if vt == ValueTypeNull {
+		dv := reflect.ValueOf(dest)
+		if dv.Kind() == reflect.Pointer && !dv.IsNil() {
+			switch dv.Elem().Kind() {
+			case reflect.Pointer, reflect.Slice, reflect.Map, reflect.Interface:
+				dv.Elem().SetZero()
+				return nil
+			}
+		}
+	}
+
+	if ok, err := v.extractSigilString(dest); ok {
+		return err
+	}
+
+	// Big integers implement encoding.TextUnmarshaler but can also be read
+	// from integer values.
+	if bi, ok := dest.(*big.Int); ok {
+		switch content := v.Content.(type) {
+		case int64:
+			bi.SetInt64(content)
+		case *big.Int:
+			bi.Set(content)
+		case String:
+			if _, ok := bi.SetString(content.String, 0); !ok {
+				return fmt.Errorf("invalid integer %q", content.String)
+			}
+		default:
+			return NewValueTypeError(v, ValueTypeInteger, ValueTypeString)
+		}
+
+		return nil
+	}
+
+	// Types such as time.Time (RFC 3339), net.IP or netip.Addr are decoded
+	// from their textual representation.
+	if tu, ok := dest.(encoding.TextUnmarshaler); ok {
+		var s string
+
+		switch vt {
+		case ValueTypeString:
+			s = v.Content.(String).String
+		case ValueTypeSymbol:
+			s = string(v.Content.(Symbol))
+		default:
+			return NewValueTypeError(v, ValueTypeString, ValueTypeSymbol)
+		}
+
+		if err := tu.UnmarshalText([]byte(s)); err != nil {
+			return fmt.Errorf("invalid value %q: %w", s, err)
+		}
+
+		return nil
+	}
+
 	switch ptr := dest.(type) {
 	case *bool:
 		switch vt {
@@ -48,25 +110,98 @@ func (v *Value) Extract(dest any) error {
 		}
 
 	case *int:
-		switch vt {
-		case ValueTypeInteger:
-			i := v.Content.(int64)
-			min := int64(math.MinInt)
-			max := int64(math.MaxInt)
-			if i < min || i > max {
-				return NewMinMaxIntegerValueError(min, max)
-			}
-			*ptr = int(i)
-		default:
-			return NewValueTypeError(v, ValueTypeInteger)
+		i, err := v.extractInteger(math.MinInt, math.MaxInt)
+		if err != nil {
+			return err
+		}
+		*ptr = int(i)
+
+	case *int8:
+		i, err := v.extractInteger(math.MinInt8, math.MaxInt8)
+		if err != nil {
+			return err
+		}
+		*ptr = int8(i)
+
+	case *int16:
+		i, err := v.extractInteger(math.MinInt16, math.MaxInt16)
+		if err != nil {
+			return err
+		}
+		*ptr = int16(i)
+
+	case *int32:
+		i, err := v.extractInteger(math.MinInt32, math.MaxInt32)
+		if err != nil {
+			return err
 		}
+		*ptr = int32(i)
 
 	case *int64:
+		i, err := v.extractInteger(math.MinInt64, math.MaxInt64)
+		if err != nil {
+			return err
+		}
+		*ptr = i
+
+	case *uint:
+		i, err := v.extractInteger(0, int64(min(uint64(math.MaxUint),
+			math.MaxInt64)))
+		if err != nil {
+			return err
+		}
+		*ptr = uint(i)
+
+	case *uint8:
+		i, err := v.extractInteger(0, math.MaxUint8)
+		if err != nil {
+			return err
+		}
+		*ptr = uint8(i)
+
+	case *uint16:
+		i, err := v.extractInteger(0, math.MaxUint16)
+		if err != nil {
+			return err
+		}
+		*ptr = uint16(i)
+
+	case *uint32:
+		i, err := v.extractInteger(0, math.MaxUint32)
+		if err != nil {
+			return err
+		}
+		*ptr = uint32(i)
+
+	case *uint64:
+		if bi, ok := v.Content.(*big.Int); ok && bi.IsUint64() {
+			*ptr = bi.Uint64()
+			break
+		}
+
+		i, err := v.extractInteger(0, math.MaxInt64)
+		if err != nil {
+			return err
+		}
+		*ptr = uint64(i)
+
+	case *float32:
 		switch vt {
+		case ValueTypeFloat:
+			f := v.Content.(float64)
+			if math.Abs(f) > math.MaxFloat32 && !math.IsInf(f, 0) {
+				return NewMinMaxFloatValueError(-math.MaxFloat32,
+					math.MaxFloat32)
+			}
+			*ptr = float32(f)
 		case ValueTypeInteger:
-			*ptr = v.Content.(int64)
+			i, err := v.extractInteger(-1<<24, 1<<24)
+			if err != nil {
+				return err
+			}
+			*ptr = float32(i)
 		default:
-			return NewValueTypeError(v, ValueTypeInteger)
+			return NewValueTypeError(v, ValueTypeFloat, ValueTypeInteger)
 		}
 
 	case *float64:
@@ -74,11 +209,9 @@ func (v *Value) Extract(dest any) error {
 		case ValueTypeFloat:
 			*ptr = v.Content.(float64)
 		case ValueTypeInteger:
-			i := v.Content.(int64)
-			min := int64(-1) << 53
-			max := int64(1) << 53
-			if i < min || i > max {
-				return NewMinMaxIntegerValueError(min, max)
+			i, err := v.extractInteger(-1<<53, 1<<53)
+			if err != nil {
+				return err
 			}
 			*ptr = float64(i)
 		default:
@@ -88,7 +221,10 @@ func (v *Value) Extract(dest any) error {
 	case *time.Duration:
 		switch vt {
 		case ValueTypeInteger:
-			i := v.Content.(int64)
+			i, err := v.extractInteger(math.MinInt64, math.MaxInt64)
+			if err != nil {
+				return err
+			}
 			if i < 0 {
 				return errors.New("invalid negative duration")
 			}
@@ -99,6 +235,56 @@ func (v *Value) Extract(dest any) error {
 				return errors.New("invalid negative duration")
 			}
 			*ptr = time.Duration(f * float64(time.Second))
+		case ValueTypeString:
+			d, err := time.ParseDuration(v.Content.(String).String)
+			if err != nil {
+				return fmt.Errorf("invalid duration: %w", err)
+			}
+			if d < 0 {
+				return errors.New("invalid negative duration")
+			}
+			*ptr = d
+		default:
+			return NewValueTypeError(v, ValueTypeInteger, ValueTypeFloat,
+				ValueTypeString)
+		}
+
+	case *url.URL:
+		switch vt {
+		case ValueTypeString:
+			u, err := url.Parse(v.Content.(String).String)
+			if err != nil {
+				return fmt.Errorf("invalid URL: %w", err)
+			}
+			*ptr = *u
+		default:
+			return NewValueTypeError(v, ValueTypeString)
+		}
+
+	case *os.FileMode:
+		switch vt {
+		case ValueTypeInteger:
+			i, err := v.extractInteger(0, int64(os.ModePerm))
+			if err != nil {
+				return err
+			}
+			*ptr = os.FileMode(i)
+		case ValueTypeString:
+			// Modes are usually written in octal, e.g. "0644"
+			s := v.Content.(String).String
+			i, err := strconv.ParseUint(s, 8, 32)
+			if err != nil || i > uint64(os.ModePerm) {
+				return fmt.Errorf("invalid file mode %q", s)
+			}
+			*ptr = os.FileMode(i)
+		default:
+			return NewValueTypeError(v, ValueTypeString, ValueTypeInteger)
+		}
+
+	case *[]byte:
+		switch vt {
+		case ValueTypeString:
+			*ptr = []byte(v.Content.(String).String)
 		default:
 			return NewValueTypeError(v, ValueTypeString)
 		}
@@ -132,6 +318,19 @@ func (v *Value) Extract(dest any) error {
 		// 2 is handled here: we allocate a new value and call Extract again.
 
 		dv := reflect.ValueOf(dest)
+		if dv.Kind() == reflect.Pointer && dv.Elem().Kind() == reflect.Slice {
+			return v.extractList(dv.Elem())
+		}
+
+		if dv.Kind() == reflect.Pointer && dv.Elem().Kind() == reflect.Map &&
+			dv.Elem().Type().Key().Kind() == reflect.String {
+			return v.extractMap(dv.Elem())
+		}
+
+		if dv.Kind() == reflect.Pointer && dv.Elem().Kind() == reflect.Struct {
+			return v.extractStruct(dv.Elem())
+		}
+
 		if dv.Kind() == reflect.Pointer && dv.Elem().Kind() == reflect.Pointer {
 			dest2 := reflect.New(dv.Elem().Type().Elem())
 
@@ -143,13 +342,124 @@ func (v *Value) Extract(dest any) error {
 			return nil
 		}
 
-		panic(fmt.Sprintf("cannot extract value to destination of type %T",
-			dest))
+		return fmt.Errorf("cannot extract value to destination of type %T",
+			dest)
+	}
+
+	return nil
+}
+
+// Extract a list to a slice, each element of the list being extracted to the
+// element of the slice at the same position.
+func (v *Value) extractList(dest reflect.Value) error {
+	list, ok := v.Content.(List)
+	if !ok {
+		return NewValueTypeError(v, ValueTypeList)
+	}
+
+	slice := reflect.MakeSlice(dest.Type(), len(list), len(list))
+
+	for i, child := range list {
+		if err := child.extractChild(slice.Index(i)); err != nil {
+			return err
+		}
 	}
 
+	dest.Set(slice)
 	return nil
 }
 
+// Extract a map to a Go map with string keys.
+func (v *Value) extractMap(dest reflect.Value) error {
+	m, ok := v.Content.(Map)
+	if !ok {
+		return NewValueTypeError(v, ValueTypeMap)
+	}
+
+	mapType := dest.Type()
+	m2 := reflect.MakeMapWithSize(mapType, len(m))
+
+	for _, entry := range m {
+		value := reflect.New(mapType.Elem()).Elem()
+		if err := entry.Value.extractChild(value); err != nil {
+			return err
+		}
+
+		m2.SetMapIndex(reflect.ValueOf(entry.Key).Convert(mapType.Key()), value)
+	}
+
+	dest.Set(m2)
+	return nil
+}
+
+// Extract a map to a structure, keys being matched with struct fields as
+// entry names are in Element.Decode.
+func (v *Value) extractStruct(dest reflect.Value) error {
+	m, ok := v.Content.(Map)
+	if !ok {
+		return NewValueTypeError(v, ValueTypeMap)
+	}
+
+	fields := make(map[string]*structField)
+	for _, field := range structFields(dest.Type()) {
+		if field.Kind != fieldKindName {
+			fields[field.Name] = field
+		}
+	}
+
+	for _, entry := range m {
+		field := fields[entry.Key]
+		if field == nil {
+			return &UnknownMapKeyError{
+				Key:      entry.Key,
+				Location: entry.KeyLocation,
+			}
+		}
+
+		delete(fields, entry.Key)
+
+		if err := entry.Value.extractChild(dest.FieldByIndex(field.Index)); err != nil {
+			return err
+		}
+	}
+
+	for _, field := range structFields(dest.Type()) {
+		if field.Required && fields[field.Name] != nil {
+			return &MissingMapKeyError{Key: field.Name}
+		}
+	}
+
+	return nil
+}
+
+// Extract an element of a list or a map. Errors refer to the element which
+// could not be extracted and not to the value containing it.
+func (v *Value) extractChild(dest reflect.Value) error {
+	if err := v.Extract(dest.Addr().Interface()); err != nil {
+		switch err.(type) {
+		case *InvalidValueError, *UnknownMapKeyError:
+			return err
+		}
+
+		return &InvalidValueError{Value: v, Err: err}
+	}
+
+	return nil
+}
+
+func (v *Value) extractInteger(min, max int64) (int64, error) {
+	if v.Type() != ValueTypeInteger {
+		return 0, NewValueTypeError(v, ValueTypeInteger)
+	}
+
+	i, ok := v.Content.(int64)
+	if !ok || i < min || i > max {
+		return 0, NewMinMaxIntegerValueError(min, max)
+	}
+
+	return i, nil
+}
+
 func NewMinIntegerValueError(min int64) *MinIntegerValueError {
 	return &MinIntegerValueError{Min: min}
 }
@@ -162,6 +472,18 @@ func NewMinMaxIntegerValueError(min, max int64) *MinMaxIntegerValueError {
 	return &MinMaxIntegerValueError{Min: min, Max: max}
 }
 
+func NewMinFloatValueError(min float64) *MinFloatValueError {
+	return &MinFloatValueError{Min: min}
+}
+
+func NewMaxFloatValueError(max float64) *MaxFloatValueError {
+	return &MaxFloatValueError{Max: max}
+}
+
+func NewMinMaxFloatValueError(min, max float64) *MinMaxFloatValueError {
+	return &MinMaxFloatValueError{Min: min, Max: max}
+}
+
 type ValueValidationFunc func(any) error
 
 type ValidatableValue struct {
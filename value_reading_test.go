@@ -0,0 +1,267 @@
+package bcl
+
+import (
+	"math"
+	"math/big"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExtractStdlibTypes(t *testing.T) {
+	tests := []struct {
+		s        string
+		dest     any
+		expected any
+	}{
+		{`-128`, new(int8), int8(-128)},
+		{`32767`, new(int16), int16(32767)},
+		{`-5`, new(int32), int32(-5)},
+		{`0`, new(uint), uint(0)},
+		{`255`, new(uint8), uint8(255)},
+		{`65535`, new(uint16), uint16(65535)},
+		{`4294967295`, new(uint32), uint32(math.MaxUint32)},
+		{`42`, new(uint64), uint64(42)},
+		{`1.5`, new(float32), float32(1.5)},
+		{`16777216`, new(float32), float32(1 << 24)},
+		{`"http://a/b?c=d"`, new(url.URL), url.URL{Scheme: "http", Host: "a",
+			Path: "/b", RawQuery: "c=d"}},
+		{`420`, new(os.FileMode), os.FileMode(0644)},
+		{`"0755"`, new(os.FileMode), os.FileMode(0755)},
+		{`"abc"`, new([]byte), []byte("abc")},
+		{`"2024-01-02T03:04:05Z"`, new(time.Time),
+			time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{`"10.0.0.1"`, new(net.IP), net.ParseIP("10.0.0.1")},
+		{`"::1"`, new(netip.Addr), netip.MustParseAddr("::1")},
+		{`"10.0.0.0/8"`, new(netip.Prefix), netip.MustParsePrefix("10.0.0.0/8")},
+		{`"99999999999999999999"`, new(big.Int), func() big.Int {
+			var i big.Int
+			i.SetString("99999999999999999999", 10)
+			return i
+		}()},
+		{`99999999999999999999`, new(big.Int), func() big.Int {
+			var i big.Int
+			i.SetString("99999999999999999999", 10)
+			return i
+		}()},
+		{`12`, new(big.Int), *big.NewInt(12)},
+	}
+
+	for _, test := range tests {
+		value, err := ParseValue(test.s)
+		if err != nil {
+			t.Fatalf("cannot parse value %q: %v", test.s, err)
+		}
+
+		if err := value.Extract(test.dest); err != nil {
+			t.Errorf("cannot extract %s to %T: %v", test.s, test.dest, err)
+			continue
+		}
+
+		dest := reflect.ValueOf(test.dest).Elem().Interface()
+
+		equal := reflect.DeepEqual(dest, test.expected)
+		if bi, ok := dest.(big.Int); ok {
+			expected := test.expected.(big.Int)
+			equal = bi.Cmp(&expected) == 0
+		}
+
+		if !equal {
+			t.Errorf("%s was extracted to %T as %v instead of %v",
+				test.s, test.dest, dest, test.expected)
+		}
+	}
+}
+
+func TestExtractStdlibTypesInvalid(t *testing.T) {
+	tests := []struct {
+		s    string
+		dest any
+	}{
+		{`128`, new(int8)},
+		{`-32769`, new(int16)},
+		{`2147483648`, new(int32)},
+		{`-1`, new(uint)},
+		{`256`, new(uint8)},
+		{`65536`, new(uint16)},
+		{`4294967296`, new(uint32)},
+		{`-1`, new(uint64)},
+		{`1e39`, new(float32)},
+		{`16777217`, new(float32)},
+		{`"a"`, new(float32)},
+		{`1`, new(url.URL)},
+		{`"%"`, new(url.URL)},
+		{`1024`, new(os.FileMode)},
+		{`"0999"`, new(os.FileMode)},
+		{`1.0`, new(os.FileMode)},
+		{`1`, new([]byte)},
+		{`"2024-01-02"`, new(time.Time)},
+		{`42`, new(time.Time)},
+		{`"10.0.0.256"`, new(netip.Addr)},
+		{`"x"`, new(big.Int)},
+		{`1.5`, new(big.Int)},
+	}
+
+	for _, test := range tests {
+		value, err := ParseValue(test.s)
+		if err != nil {
+			t.Fatalf("cannot parse value %q: %v", test.s, err)
+		}
+
+		if err := value.Extract(test.dest); err == nil {
+			t.Errorf("extracting %s to %T should have failed", test.s,
+				test.dest)
+		}
+	}
+}
+
+func TestUnmarshalStdlibTypes(t *testing.T) {
+	var config struct {
+		Addresses []netip.Addr            `bcl:"addresses"`
+		Address   net.IP                  `bcl:"address"`
+		Key       []byte                  `bcl:"key"`
+		Start     *time.Time              `bcl:"start"`
+		URL       url.URL                 `bcl:"url"`
+		Mode      os.FileMode             `bcl:"mode"`
+		Prefixes  map[string]netip.Prefix `bcl:"prefixes"`
+	}
+
+	s := `
+addresses "10.0.0.1" "::1"
+address "192.168.0.1"
+key "secret"
+start "2024-01-02T03:04:05Z"
+url "https://example.com"
+mode "0600"
+prefixes {a = "10.0.0.0/8"}
+`
+
+	if err := Unmarshal([]byte(s), "test", &config); err != nil {
+		t.Fatalf("cannot unmarshal document: %v", err)
+	}
+
+	addrs := []netip.Addr{netip.MustParseAddr("10.0.0.1"),
+		netip.MustParseAddr("::1")}
+
+	if !reflect.DeepEqual(config.Addresses, addrs) {
+		t.Errorf("addresses were unmarshaled as %v", config.Addresses)
+	}
+
+	if !config.Address.Equal(net.ParseIP("192.168.0.1")) {
+		t.Errorf("address was unmarshaled as %v", config.Address)
+	}
+
+	if string(config.Key) != "secret" {
+		t.Errorf("key was unmarshaled as %q", config.Key)
+	}
+
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	if config.Start == nil || !config.Start.Equal(start) {
+		t.Errorf("start was unmarshaled as %v", config.Start)
+	}
+
+	if config.URL.Host != "example.com" {
+		t.Errorf("url was unmarshaled as %v", config.URL)
+	}
+
+	if config.Mode != 0600 {
+		t.Errorf("mode was unmarshaled as %v", config.Mode)
+	}
+
+	if p := config.Prefixes["a"]; p != netip.MustParsePrefix("10.0.0.0/8") {
+		t.Errorf("prefix was unmarshaled as %v", p)
+	}
+}
+
+func TestExtractNull(t *testing.T) {
+	i := 1
+
+	tests := []any{
+		&[]*int{&i}[0],
+		&[][]int{{1, 2}}[0],
+		&[]map[string]int{{"a": 1}}[0],
+		&[]any{"a"}[0],
+	}
+
+	value, err := ParseValue("null")
+	if err != nil {
+		t.Fatalf("cannot parse value: %v", err)
+	}
+
+	for _, dest := range tests {
+		if err := value.Extract(dest); err != nil {
+			t.Errorf("cannot extract null to %T: %v", dest, err)
+			continue
+		}
+
+		if dv := reflect.ValueOf(dest).Elem(); !dv.IsNil() {
+			t.Errorf("null was extracted to %T as %v", dest, dv.Interface())
+		}
+	}
+}
+
+func TestExtractNullInvalid(t *testing.T) {
+	tests := []struct {
+		dest any
+		err  string
+	}{
+		{new(int), "value is null but should be an integer"},
+		{new(string), "value is null but should be"},
+		{new(bool), "value is null but should be a bool"},
+		{new(float64), "value is null but should be"},
+	}
+
+	value, err := ParseValue("null")
+	if err != nil {
+		t.Fatalf("cannot parse value: %v", err)
+	}
+
+	for _, test := range tests {
+		err := value.Extract(test.dest)
+		if err == nil {
+			t.Errorf("extracting null to %T should have failed", test.dest)
+		} else if !strings.Contains(err.Error(), test.err) {
+			t.Errorf("extracting null to %T failed with error %q which does "+
+				"not contain %q", test.dest, err, test.err)
+		}
+	}
+}
+
+func TestValueNull(t *testing.T) {
+	value, err := ParseValue("null")
+	if err != nil {
+		t.Fatalf("cannot parse value: %v", err)
+	}
+
+	if vt := value.Type(); vt != ValueTypeNull {
+		t.Errorf("null value has type %q", vt)
+	}
+
+	if !value.IsNull() {
+		t.Errorf("null value is not null")
+	}
+
+	if !value.Equal(&Value{}) {
+		t.Errorf("null value is not equal to a value with nil content")
+	}
+
+	if value.Equal(&Value{Content: int64(0)}) {
+		t.Errorf("null value is equal to 0")
+	}
+
+	if err := value.IsOneOf(1, nil); err != nil {
+		t.Errorf("null value is not one of 1 and null: %v", err)
+	}
+
+	err = value.IsOneOf(1, "a")
+	if err == nil {
+		t.Errorf("null value is one of 1 and \"a\"")
+	} else if s := err.Error(); !strings.Contains(s, "null") {
+		t.Errorf("content error %q does not mention null", s)
+	}
+}
@@ -2,35 +2,59 @@ package bcl
 
 import (
 	"fmt"
+	"math/big"
 )
 
 type ValueType string
 
 const (
+	ValueTypeNull    ValueType = "null"
 	ValueTypeSymbol  ValueType = "symbol"
 	ValueTypeBool    ValueType = "bool"
 	ValueTypeString  ValueType = "string"
 	ValueTypeInteger ValueType = "integer"
 	ValueTypeFloat   ValueType = "float"
+	ValueTypeList    ValueType = "list"
+	ValueTypeMap     ValueType = "map"
 )
 
+// Integers are stored as int64 values, except for integers which do not fit
+// in 64 bits which are stored as *big.Int values.
 type Value struct {
 	Location Span
-	Content  any // either Symbol, bool, String, int64 or float64
+	Content  any // either nil, Symbol, bool, String, int64, *big.Int, float64, List or Map
+
+	// Comments on the lines preceding a list element and comment at the end
+	// of its line, and comments following the last element of a list or the
+	// last entry of a map.
+	LeadingComments []*Comment
+	TrailingComment *Comment
+	EndComments     []*Comment
+
+	radix         int         // radix of integer literals, used when printing
+	stringStyle   stringStyle // style of string literals, used when printing
+	layout        valueLayout // layout of lists and maps, used when printing
+	interpolation *valueInterpolation
 }
 
 func (v *Value) Type() (t ValueType) {
 	switch v.Content.(type) {
+	case nil:
+		t = ValueTypeNull
 	case Symbol:
 		t = ValueTypeSymbol
 	case bool:
 		t = ValueTypeBool
 	case String:
 		t = ValueTypeString
-	case int64:
+	case int64, *big.Int:
 		t = ValueTypeInteger
 	case float64:
 		t = ValueTypeFloat
+	case List:
+		t = ValueTypeList
+	case Map:
+		t = ValueTypeMap
 
 	default:
 		panic(fmt.Sprintf("unhandled value %#v (%T)", v.Content, v.Content))
@@ -39,6 +63,38 @@ func (v *Value) Type() (t ValueType) {
 	return
 }
 
+// Return whether the value is null, i.e. whether its content is nil.
+func (v *Value) IsNull() bool {
+	return v.Content == nil
+}
+
+// Return whether the value contains comments, i.e. whether it is a list or a
+// map containing comments, directly or in one of its elements.
+func (v *Value) containsComments() bool {
+	switch content := v.Content.(type) {
+	case List:
+		for _, child := range content {
+			if len(child.LeadingComments) > 0 || child.TrailingComment != nil ||
+				child.containsComments() {
+				return true
+			}
+		}
+
+	case Map:
+		for _, entry := range content {
+			if len(entry.LeadingComments) > 0 || entry.TrailingComment != nil ||
+				entry.Value.containsComments() {
+				return true
+			}
+		}
+
+	default:
+		return false
+	}
+
+	return len(v.EndComments) > 0
+}
+
 type Symbol string
 
 type String struct {
@@ -46,12 +102,81 @@ type String struct {
 	Sigil  string
 }
 
+// Strings are written either between double quotes, between backquotes for
+// raw strings whose content is not processed, or between triple quotes for
+// multi-line strings, e.g.:
+//
+//	query """
+//	  SELECT * FROM users
+//	    WHERE name = "bob"
+//	  """
+type stringStyle int
+
+const (
+	stringStyleQuoted stringStyle = iota
+	stringStyleRaw
+	stringStyleMultiLine
+)
+
+// Lists and maps read from a document are printed on a single line or on
+// multiple lines as they were written. Other lists are printed on a single
+// line, and other maps on a single line if they are short enough. Lists and
+// maps containing comments are always printed on multiple lines, one element
+// or entry per line.
+type valueLayout int
+
+const (
+	valueLayoutAuto valueLayout = iota
+	valueLayoutInline
+	valueLayoutMultiLine
+)
+
+// A list of values, e.g. `[1 2 3]`. Lists can contain values of different
+// types, including other lists.
+type List []*Value
+
+// An inline map, e.g. `{name = "foo", port = 80}`. Keys are symbols or
+// strings, and entries are separated by commas or by line ends. Entries are
+// stored in the order they were written.
+//
+// A map written right after the name of an entry is only read as a map if it
+// contains at least one entry; `name {}` is an empty block. An empty map can
+// be written `{,}` to be read as a value anywhere.
+type Map []*MapEntry
+
+type MapEntry struct {
+	Key         string
+	KeyLocation Span
+	Value       *Value
+
+	LeadingComments []*Comment
+	TrailingComment *Comment
+}
+
+// Return the value associated with a key, or nil if the map does not contain
+// this key.
+func (m Map) Get(key string) *Value {
+	for _, entry := range m {
+		if entry.Key == key {
+			return entry.Value
+		}
+	}
+
+	return nil
+}
+
 func (v *Value) IsOneOf(contents ...any) error {
 	valid := false
 	t := v.Type()
 
 	for _, content := range contents {
 		switch c := content.(type) {
+		case nil:
+			if t == ValueTypeNull {
+				valid = true
+				break
+			}
+
 		case bool:
 			if t == ValueTypeBool && v.Content.(bool) == c {
 				valid = true
@@ -70,13 +195,14 @@ func (v *Value) IsOneOf(contents ...any) error {
 			}
 
 		case int:
-			if t == ValueTypeInteger && v.Content.(int64) == int64(c) {
+			if t == ValueTypeInteger &&
+				integerContentEqual(v.Content, int64(c)) {
 				valid = true
 				break
 			}
 
-		case int64:
-			if t == ValueTypeInteger && v.Content.(int64) == c {
+		case int64, *big.Int:
+			if t == ValueTypeInteger && integerContentEqual(v.Content, c) {
 				valid = true
 				break
 			}
@@ -86,6 +212,18 @@ func (v *Value) IsOneOf(contents ...any) error {
 				valid = true
 				break
 			}
+
+		case []any:
+			if t == ValueTypeList && v.Content.(List).isOneOf(c) {
+				valid = true
+				break
+			}
+
+		case map[string]any:
+			if t == ValueTypeMap && v.Content.(Map).isOneOf(c) {
+				valid = true
+				break
+			}
 		}
 	}
 
@@ -105,6 +243,8 @@ func (v1 *Value) Equal(v2 *Value) bool {
 	eq := false
 
 	switch t {
+	case ValueTypeNull:
+		eq = true
 	case ValueTypeSymbol:
 		eq = v1.Content.(Symbol) == v2.Content.(Symbol)
 	case ValueTypeBool:
@@ -114,12 +254,82 @@ func (v1 *Value) Equal(v2 *Value) bool {
 		s2 := v2.Content.(String)
 		eq = s1.Sigil == s2.Sigil && s1.String == s2.String
 	case ValueTypeInteger:
-		eq = v1.Content.(int64) == v2.Content.(int64)
+		eq = integerContentEqual(v1.Content, v2.Content)
 	case ValueTypeFloat:
 		eq = v1.Content.(float64) == v2.Content.(float64)
+	case ValueTypeList:
+		l1 := v1.Content.(List)
+		l2 := v2.Content.(List)
+		eq = len(l1) == len(l2)
+		for i := 0; eq && i < len(l1); i++ {
+			eq = l1[i].Equal(l2[i])
+		}
+	case ValueTypeMap:
+		// Key order is not significant
+		m1 := v1.Content.(Map)
+		m2 := v2.Content.(Map)
+		eq = len(m1) == len(m2)
+		for i := 0; eq && i < len(m1); i++ {
+			value2 := m2.Get(m1[i].Key)
+			eq = value2 != nil && m1[i].Value.Equal(value2)
+		}
 	default:
 		panic(fmt.Sprintf("unhandled value type %q", t))
 	}
 
 	return eq
 }
+
+// Return whether each element of the list matches the content at the same
+// position, contents being of the form accepted by Value.IsOneOf.
+func (l List) isOneOf(contents []any) bool {
+	if len(l) != len(contents) {
+		return false
+	}
+
+	for i, v := range l {
+		if v.IsOneOf(contents[i]) != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Return whether the map contains the same keys as a map of contents and
+// whether each value matches the content associated with its key.
+func (m Map) isOneOf(contents map[string]any) bool {
+	if len(m) != len(contents) {
+		return false
+	}
+
+	for _, entry := range m {
+		content, found := contents[entry.Key]
+		if !found || entry.Value.IsOneOf(content) != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+func integerContentEqual(c1, c2 any) bool {
+	i1, ok1 := c1.(int64)
+	i2, ok2 := c2.(int64)
+	if ok1 && ok2 {
+		return i1 == i2
+	}
+
+	return integerContentBigInt(c1).Cmp(integerContentBigInt(c2)) == 0
+}
+
+func integerContentBigInt(c any) *big.Int {
+	switch i := c.(type) {
+	case int64:
+		return big.NewInt(i)
+	case *big.Int:
+		return i
+	default:
+		panic(fmt.Sprintf("unhandled integer %#v (%T)", c, c))
+	}
+}
@@ -0,0 +1,221 @@
+package bcl
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func ExportYAML(doc *Document) ([]byte, []ConversionWarning, error) {
+	var c converter
+
+	node := yamlNode(c.exportDocument(doc))
+
+	var buf bytes.Buffer
+
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+
+	if err := encoder.Encode(node); err != nil {
+		return nil, nil, err
+	}
+
+	if err := encoder.Close(); err != nil {
+		return nil, nil, err
+	}
+
+	return buf.Bytes(), c.Warnings, nil
+}
+
+func ImportYAML(data []byte, source string) (*Document, []ConversionWarning, error) {
+	var root yaml.Node
+
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, nil, err
+	}
+
+	var c converter
+	var m convMap
+
+	if root.Kind != 0 {
+		value, err := c.yamlValue(&root, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		switch v := value.(type) {
+		case convMap:
+			m = v
+		case nil:
+		default:
+			return nil, nil, errors.New("top-level YAML value is not a mapping")
+		}
+	}
+
+	return c.importDocument(m, source), c.Warnings, nil
+}
+
+func yamlNode(v any) *yaml.Node {
+	var node yaml.Node
+
+	switch v := v.(type) {
+	case convMap:
+		node.Kind = yaml.MappingNode
+		node.Tag = "!!map"
+
+		for _, item := range v {
+			key := yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: item.Key}
+			node.Content = append(node.Content, &key, yamlNode(item.Value))
+		}
+
+	case []any:
+		node.Kind = yaml.SequenceNode
+		node.Tag = "!!seq"
+
+		for _, child := range v {
+			node.Content = append(node.Content, yamlNode(child))
+		}
+
+		if len(v) == 0 {
+			node.Style = yaml.FlowStyle
+		}
+
+	case nil:
+		node.Kind = yaml.ScalarNode
+		node.Tag = "!!null"
+		node.Value = "null"
+
+	case bool:
+		node.Kind = yaml.ScalarNode
+		node.Tag = "!!bool"
+		node.Value = strconv.FormatBool(v)
+
+	case int64:
+		node.Kind = yaml.ScalarNode
+		node.Tag = "!!int"
+		node.Value = strconv.FormatInt(v, 10)
+
+	case float64:
+		node.Kind = yaml.ScalarNode
+		node.Tag = "!!float"
+
+		switch {
+		case math.IsInf(v, 1):
+			node.Value = ".inf"
+		case math.IsInf(v, -1):
+			node.Value = "-.inf"
+		case math.IsNaN(v):
+			node.Value = ".nan"
+		default:
+			// Keep a fractional part so that the value is read back as a
+			// float and not as an integer.
+			node.Value = strconv.FormatFloat(v, 'g', -1, 64)
+			if !strings.ContainsAny(node.Value, ".eE") {
+				node.Value += ".0"
+			}
+		}
+
+	case string:
+		// Let the encoder select the right style (quoting strings which would
+		// otherwise be read as other types of scalars).
+		if err := node.Encode(v); err != nil {
+			panic(fmt.Sprintf("cannot encode string %q: %v", v, err))
+		}
+
+	default:
+		panic(fmt.Sprintf("unhandled value %#v (%T)", v, v))
+	}
+
+	return &node
+}
+
+func (c *converter) yamlValue(node *yaml.Node, path []string) (any, error) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil, nil
+		}
+
+		return c.yamlValue(node.Content[0], path)
+
+	case yaml.AliasNode:
+		return c.yamlValue(node.Alias, path)
+
+	case yaml.MappingNode:
+		var m convMap
+
+		for i := 0; i < len(node.Content)-1; i += 2 {
+			keyNode := node.Content[i]
+			valueNode := node.Content[i+1]
+
+			if keyNode.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("line %d: invalid non-scalar mapping key",
+					keyNode.Line)
+			}
+
+			if keyNode.Tag == "!!merge" {
+				c.warn(path, "merge key ignored")
+				continue
+			}
+
+			key := keyNode.Value
+
+			value, err := c.yamlValue(valueNode, childPath(path, key))
+			if err != nil {
+				return nil, err
+			}
+
+			m = append(m, convMapItem{Key: key, Value: value})
+		}
+
+		if m == nil {
+			m = convMap{}
+		}
+
+		return m, nil
+
+	case yaml.SequenceNode:
+		seq := make([]any, len(node.Content))
+
+		for i, child := range node.Content {
+			value, err := c.yamlValue(child, path)
+			if err != nil {
+				return nil, err
+			}
+
+			seq[i] = value
+		}
+
+		return seq, nil
+
+	case yaml.ScalarNode:
+		var value any
+		if err := node.Decode(&value); err != nil {
+			return nil, fmt.Errorf("line %d: %w", node.Line, err)
+		}
+
+		switch v := value.(type) {
+		case nil, bool, float64, string, time.Time:
+			return v, nil
+		case int:
+			return int64(v), nil
+		case int64:
+			return v, nil
+		case uint64:
+			return new(big.Int).SetUint64(v), nil
+		default:
+			c.warn(path, "value %q converted to a string", node.Value)
+			return node.Value, nil
+		}
+
+	default:
+		return nil, fmt.Errorf("line %d: unhandled YAML node", node.Line)
+	}
+}